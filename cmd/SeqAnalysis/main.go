@@ -2,11 +2,15 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
+	"slices"
+	"strings"
 	"time"
 
 	util "SeqAnalysis/pkg/seqAnalysis"
@@ -36,7 +40,7 @@ var (
 	outputDir = flag.String(
 		"o",
 		"",
-		"output directory, default is sub directory of CWD: [BaseName]+.分析",
+		"output directory, default is sub directory of CWD: [BaseName]+.分析 (.analysis for -locale en)",
 	)
 	thread = flag.Int(
 		"t",
@@ -71,7 +75,12 @@ var (
 	plot = flag.Bool(
 		"plot",
 		false,
-		"plot",
+		"plot ErrRate.pdf and histogram.pdf",
+	)
+	plotR = flag.Bool(
+		"plotR",
+		false,
+		"plot with Rscript bin/plot.R instead of native go, need -plot",
 	)
 	lessMem = flag.Bool(
 		"lessMem",
@@ -83,6 +92,66 @@ var (
 		100000,
 		"line limit",
 	)
+	parquet = flag.Bool(
+		"parquet",
+		false,
+		"export all distinct sequences of samples with count, class and alignments to sequences.parquet",
+	)
+	pool = flag.Bool(
+		"pool",
+		false,
+		"merge reads of each 平行 group into virtual sample [平行].pooled with Stats sheet and steps, summary to pooled.txt",
+	)
+	analysis = flag.String(
+		"analysis",
+		strings.Join(util.DefaultAnalyses, ","),
+		"batch analyses of summary, comma separated of "+strings.Join(util.Analyses, ",")+", all or none, context is added if -contextModel set",
+	)
+	contextLen = flag.Int(
+		"contextLen",
+		util.MaxContextLength,
+		"upstream nt of context error model of context.txt, 0-4",
+	)
+	contextModel = flag.String(
+		"contextModel",
+		"",
+		"context error model json accumulated across batches, this batch is added to it and context.txt reports it",
+	)
+	motifK = flag.Int(
+		"motifK",
+		util.DefaultMotifK,
+		"k of upstream/downstream motif of del1/del3 breakpoints in breakpoint.txt, 1-6",
+	)
+	truncK = flag.Int(
+		"truncK",
+		util.TruncationK,
+		"max k of n-k products in truncation.txt",
+	)
+	iupacIndex = flag.Bool(
+		"iupac",
+		util.IUPACIndex,
+		"match 靶标序列 and 后靶标 with IUPAC codes, N as any base, default match as is",
+	)
+	unmatched = flag.Int(
+		"unmatched",
+		0,
+		"top N prefixes of reads matching no 靶标序列 of each fastq to unmatched.txt, annotated with nearest 靶标序列 and adapters, 0 for off",
+	)
+	contaminationPct = flag.Float64(
+		"contaminationPct",
+		util.ContaminationPct,
+		"flag contamination in contamination.txt if reads exactly matching 合成序列 of other design >= this % of analyzed reads",
+	)
+	swapRatio = flag.Float64(
+		"swapRatio",
+		util.SwapRatio,
+		"flag swap in contamination.txt if reads of other design >= swapRatio × reads of own design and >= -contaminationPct",
+	)
+	xlsxSplit = flag.Int(
+		"xlsxSplit",
+		4,
+		"max continuation sheets [sheet]_2... of single sample xlsx beyond 1048576 rows, rows beyond spill to [id].[sheet].txt",
+	)
 	debug = flag.Bool(
 		"debug",
 		false,
@@ -113,6 +182,61 @@ var (
 		"",
 		"可选参数：样品名称后缀列，若指定则将该列值拼接到样品名称后",
 	)
+	etcDir = flag.String(
+		"etc",
+		"",
+		"config dir, files not found in it fallback to etc/ beside executable or embedded",
+	)
+	config = flag.String(
+		"config",
+		"",
+		"run configuration file in YAML/JSON/TOML (by .toml extension), keys are flag names, optional inline samples table, flags override it",
+	)
+	validate = flag.Bool(
+		"validate",
+		false,
+		"only validate input and report all problems, analysis always validate input first",
+	)
+	fqTemplate = flag.String(
+		"fq-template",
+		"",
+		"fastq template of samples without 路径, e.g. {root}/{batch}/{id}_R{read}.fastq.gz, support glob and {column} of input",
+	)
+	fqRoot = flag.String(
+		"fq-root",
+		"",
+		"fastq root dir, {root} of -fq-template, auto-discovery [id]*_R1*.fastq.gz etc. under it if no -fq-template",
+	)
+	batchName = flag.String(
+		"batch",
+		"",
+		"batch name, {batch} of -fq-template",
+	)
+	locale = flag.String(
+		"locale",
+		"zh",
+		"language of output headers, sheet names and report text: zh or en, input accepts both Chinese and English columns",
+	)
+	ciMethod = flag.String(
+		"ci",
+		util.CIWilson,
+		"interval of proportions: wilson or cp (Clopper-Pearson), 95% level, AverageYieldAccuracy by bootstrap",
+	)
+	bootstrap = flag.Int(
+		"bootstrap",
+		1000,
+		"resamples of bootstrap interval of AverageYieldAccuracy",
+	)
+	qcExit = flag.String(
+		"qcExit",
+		"",
+		"exit with code 2 if any sample reach QC level of etc/qc_rules.txt: WARN or FAIL, empty for never",
+	)
+	qcMinStepReads = flag.Int(
+		"qcMinStepReads",
+		util.QCMinStepReads,
+		"ignore steps with reads < qcMinStepReads in QC metric MaxStepErrorRate",
+	)
 )
 
 // embed etc
@@ -124,6 +248,57 @@ func main() {
 	t0 := time.Now()
 	flag.Parse()
 
+	var runConfig = &util.RunConfig{}
+	if *config != "" {
+		runConfig = simpleUtil.HandleError(util.LoadRunConfig(*config))
+		simpleUtil.CheckErr(applyRunConfig(runConfig))
+	}
+
+	if !slices.Contains(util.Locales, *locale) {
+		slog.Error("unsupported locale", "locale", *locale, "supported", util.Locales)
+		os.Exit(1)
+	}
+	util.Locale = *locale
+
+	if !slices.Contains([]string{util.CIWilson, util.CIClopperPearson}, *ciMethod) {
+		slog.Error("unsupported ci", "ci", *ciMethod, "supported", []string{util.CIWilson, util.CIClopperPearson})
+		os.Exit(1)
+	}
+	util.CIMethod = *ciMethod
+	util.BootstrapN = *bootstrap
+
+	if *qcExit != "" && !slices.Contains([]string{util.QCWarn, util.QCFail}, strings.ToUpper(*qcExit)) {
+		slog.Error("unsupported qcExit", "qcExit", *qcExit, "supported", []string{util.QCWarn, util.QCFail})
+		os.Exit(1)
+	}
+
+	if *contextLen < 0 || *contextLen > util.MaxContextLength {
+		slog.Error("unsupported contextLen", "contextLen", *contextLen, "max", util.MaxContextLength)
+		os.Exit(1)
+	}
+
+	if *motifK < 1 || *motifK > util.MaxMotifK {
+		slog.Error("unsupported motifK", "motifK", *motifK, "max", util.MaxMotifK)
+		os.Exit(1)
+	}
+
+	var analyses, err = util.ParseAnalyses(*analysis)
+	if err != nil {
+		slog.Error("unsupported analysis", "err", err)
+		os.Exit(1)
+	}
+	if *contextModel != "" {
+		analyses[util.AnalysisContext] = true
+	}
+
+	if *fqTemplate != "" || *fqRoot != "" {
+		util.FastqLocate = &util.FastqLocator{
+			Root:     *fqRoot,
+			Batch:    *batchName,
+			Template: *fqTemplate,
+		}
+	}
+
 	if !*debug {
 		*cpuProfile = ""
 		*memProfile = ""
@@ -139,10 +314,17 @@ func main() {
 	}
 
 	if *outputDir == "" {
-		*outputDir = filepath.Base(simpleUtil.HandleError(os.Getwd())) + ".分析"
+		*outputDir = filepath.Base(simpleUtil.HandleError(os.Getwd())) + util.L(".分析")
 	}
 
 	util.Short = *short
+	util.SheetSplit = *xlsxSplit
+	util.TruncationK = max(1, *truncK)
+	util.UnmatchedTop = *unmatched
+	util.IUPACIndex = *iupacIndex
+	util.ContaminationPct = *contaminationPct
+	util.SwapRatio = *swapRatio
+	util.QCMinStepReads = *qcMinStepReads
 
 	var batch = util.Batch{
 		OutputPrefix: *outputDir,
@@ -156,6 +338,10 @@ func main() {
 		LessMem:   *lessMem,
 		Zip:       *zip,
 		Plot:      *plot,
+		PlotR:     *plotR,
+		Parquet:   *parquet,
+		Pool:      *pool,
+		Analyses:  analyses,
 
 		Sheets:           make(map[string]string),
 		SeqInfoMap:       make(map[string]*util.SeqInfo),
@@ -164,7 +350,38 @@ func main() {
 
 	batch.NoTail = *noTail
 	batch.SuffixCol = *suffixCol
-	batch.BatchRun(*input, *fqDir, exPath, etcEMFS, *thread)
+	batch.EtcDir = *etcDir
+	batch.QCExit = *qcExit
+	batch.ContextLength = *contextLen
+	batch.ContextModelPath = *contextModel
+	batch.MotifK = *motifK
+	batch.Samples = runConfig.Samples
+	batch.RunConfig = &util.RunConfig{
+		Options: effectiveOptions(),
+		Samples: runConfig.Samples,
+	}
+
+	if *validate {
+		if *fqDir != "" {
+			if err := os.Chdir(*fqDir); err != nil {
+				slog.Error("Chdir", "dir", *fqDir, "err", err)
+				os.Exit(1)
+			}
+		}
+		if err := batch.Validate(*input, *fqDir); err != nil {
+			slog.Error("Validate", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := batch.BatchRun(*input, *fqDir, exPath, etcEMFS, *thread); err != nil {
+		slog.Error("BatchRun", "err", err)
+		if errors.Is(err, util.ErrQCFailed) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
 
 	if *memProfile != "" {
 		var LogMemProfile = osUtil.Create(*memProfile)
@@ -175,3 +392,35 @@ func main() {
 	slog.Info("Done", "time", time.Since(t0))
 
 }
+
+// applyRunConfig set flags from run config, flags set in command line override it
+func applyRunConfig(runConfig *util.RunConfig) error {
+	var set = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for name, value := range runConfig.Options {
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown option in config: %s", name)
+		}
+		if set[name] {
+			continue
+		}
+		if err := flag.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("bad option in config: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// effectiveOptions values of all flags after apply run config
+func effectiveOptions() map[string]any {
+	var options = make(map[string]any)
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		options[f.Name] = f.Value.(flag.Getter).Get()
+	})
+	return options
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	util "SeqAnalysis/pkg/seqAnalysis"
+
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+var (
+	input = flag.String(
+		"i",
+		"",
+		"one.step.error.rate.txt files or result dirs of SeqAnalysis, comma separated",
+	)
+	model = flag.String(
+		"m",
+		"",
+		"context model json, load if exists, inputs are added and saved back",
+	)
+	output = flag.String(
+		"o",
+		"context.txt",
+		"report of contexts",
+	)
+	contextLen = flag.Int(
+		"k",
+		util.MaxContextLength,
+		"upstream nt of context for new model, 0-4",
+	)
+	iqr = flag.Bool(
+		"iqr",
+		false,
+		"filter outlier steps of each context by 1.5 IQR of each input, as loadErrRate",
+	)
+	alpha = flag.Float64(
+		"alpha",
+		util.CompareAlpha,
+		"hot context if BH adjusted P < alpha",
+	)
+)
+
+func main() {
+	flag.Parse()
+	if *input == "" && *model == "" {
+		flag.PrintDefaults()
+		log.Fatal("-i or -m required!")
+	}
+
+	var contextModel *util.ContextModel
+	if *model != "" && osUtil.FileExists(*model) {
+		contextModel = simpleUtil.HandleError(util.LoadContextModel(*model))
+		slog.Info("load context model", "model", *model, "contextLength", contextModel.ContextLength, "sources", len(contextModel.Sources))
+	} else {
+		contextModel = simpleUtil.HandleError(util.NewContextModel(*contextLen))
+	}
+
+	for _, path := range strings.Split(*input, ",") {
+		if path == "" {
+			continue
+		}
+		var records []*util.ErrRateRecord
+		for _, file := range simpleUtil.HandleError(util.ErrRateFiles(path)) {
+			records = append(records, simpleUtil.HandleError(util.LoadErrRateFile(file))...)
+		}
+		var source = simpleUtil.HandleError(filepath.Abs(path))
+		var err = contextModel.AddRecords(source, records, *iqr)
+		if errors.Is(err, util.ErrContextSource) {
+			slog.Warn("skip", "err", err)
+			continue
+		}
+		simpleUtil.CheckErr(err)
+		slog.Info("add", "source", source, "records", len(records))
+	}
+
+	if *model != "" {
+		simpleUtil.CheckErr(contextModel.Save(*model))
+	}
+	util.WriteContextTxt(*output, contextModel.Report(*alpha))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	util "SeqAnalysis/pkg/seqAnalysis"
+
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+var (
+	input = flag.String(
+		"i",
+		"",
+		"candidate sequences, FASTA or TSV with title of 样品名称/id, 合成序列/seq and optional 靶标序列/index",
+	)
+	model = flag.String(
+		"m",
+		"",
+		"context model json of contextModel or SeqAnalysis -contextModel",
+	)
+	coupling = flag.String(
+		"coupling",
+		"",
+		"batch.json of SeqAnalysis, use coupling model if -m not set",
+	)
+	index = flag.String(
+		"index",
+		"",
+		"upstream sequence of candidates without 靶标序列",
+	)
+	output = flag.String(
+		"o",
+		"predict",
+		"output prefix, write [prefix].summary.txt, [prefix].positions.txt and [prefix].products.txt",
+	)
+	homopolymer = flag.Int(
+		"homopolymer",
+		util.DefaultPredictOptions.Homopolymer,
+		"flag homopolymer runs of at least this length",
+	)
+	riskRatio = flag.Float64(
+		"ratio",
+		util.DefaultPredictOptions.RiskRatio,
+		"flag position if step error rate >= ratio × mean step error rate of model",
+	)
+	products = flag.Int(
+		"products",
+		util.DefaultPredictOptions.Products,
+		"number of most common n-1 products of each candidate",
+	)
+	motifs = flag.String(
+		"motifs",
+		"",
+		"extra known bad motifs, comma separated",
+	)
+	known = flag.Bool(
+		"known",
+		util.DefaultPredictOptions.KnownMotifs,
+		"flag built-in known problem 4-mers: "+strings.Join(util.KnownRiskyMotifs, ","),
+	)
+)
+
+func main() {
+	flag.Parse()
+	if *input == "" || (*model == "" && *coupling == "") {
+		flag.PrintDefaults()
+		log.Fatal("-i and -m or -coupling required!")
+	}
+
+	var (
+		stepModel   util.StepErrorModel
+		riskyMotifs []string
+	)
+	if *model != "" {
+		var contextModel = simpleUtil.HandleError(util.LoadContextModel(*model))
+		slog.Info("load context model", "model", *model, "contextLength", contextModel.ContextLength, "sources", len(contextModel.Sources))
+		stepModel = contextModel
+	} else {
+		var couplingModel = simpleUtil.HandleError(util.LoadCouplingModel(*coupling))
+		slog.Info("load coupling model", "batch", *coupling, "observations", couplingModel.Observations)
+		stepModel = couplingModel
+	}
+	riskyMotifs = stepModel.RiskyMotifs()
+	slog.Info("model", "globalErrorRate", stepModel.GlobalErrorRate(), "riskyMotifs", len(riskyMotifs))
+
+	var opts = util.PredictOptions{
+		Homopolymer: *homopolymer,
+		RiskRatio:   *riskRatio,
+		Products:    *products,
+		KnownMotifs: *known,
+	}
+	for _, motif := range strings.Split(*motifs, ",") {
+		if motif = strings.TrimSpace(motif); motif != "" {
+			opts.Motifs = append(opts.Motifs, motif)
+		}
+	}
+
+	var file = simpleUtil.HandleError(os.Open(*input))
+	var candidates = simpleUtil.HandleError(util.LoadCandidates(file))
+	defer simpleUtil.DeferClose(file)
+
+	var predictions []*util.Prediction
+	for _, c := range candidates {
+		if c.Upstream == "" {
+			c.Upstream = strings.ToUpper(*index)
+		}
+		predictions = append(predictions, util.Predict(c, stepModel, opts, riskyMotifs))
+	}
+	util.WritePredictions(*output, predictions)
+	slog.Info("predict", "candidates", len(predictions), "output", *output)
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	util "SeqAnalysis/pkg/seqAnalysis"
 	"SeqAnalysis/pkg/wechatwork" // 替换为你的模块名
 
 	"github.com/liserjrqlxue/goUtil/osUtil"
@@ -37,6 +38,12 @@ const (
 	maxConcurrent = 8 // 最大并发数
 )
 
+// 各批次类型默认原始数据目录模板，{batch} 为批次名称
+var rawDataTemplates = map[BatchType]string{
+	BatchTypeNovo: "/data2/wangyaoshen/novo-medical-customer-tj/CYB24030020/{batch}/Rawdata",
+	BatchTypeG99:  "/data2/wangyaoshen/Sequencing_data/G99/R21007100240139/{batch}/L01",
+}
+
 type FileResult struct {
 	FileName string
 	Success  bool
@@ -44,7 +51,8 @@ type FileResult struct {
 }
 
 var (
-	suffixCol string
+	suffixCol         string
+	seqAnalysisConfig string
 )
 
 func main() {
@@ -52,10 +60,15 @@ func main() {
 	var dirPath string
 	var batch string
 	var webhookKey string
+	var rawTemplate string
+	var seqAnalysisPath string
 	flag.StringVar(&dirPath, "d", ".", "指定要处理的目录")
 	flag.StringVar(&batch, "batch", "", "批次名称（如果不提供，将从Path.txt文件中解析）")
 	flag.StringVar(&webhookKey, "webhook", "", "企业微信Webhook Key（可选）")
 	flag.StringVar(&suffixCol, "suffix-col", "", "可选参数：样品名称后缀列，若指定则将该列值拼接到样品名称后")
+	flag.StringVar(&seqAnalysisConfig, "config", "", "可选参数：SeqAnalysis 运行配置文件(YAML/JSON/TOML)，指定时替代默认参数 -lessMem -plot -rc -zip")
+	flag.StringVar(&rawTemplate, "raw", "", "可选参数：原始数据目录模板，如 /data/{batch}/L01，{batch} 为批次名称，默认按批次类型")
+	flag.StringVar(&seqAnalysisPath, "seqAnalysis", "/data2/wangyaoshen/src/SeqAnalysis/cmd/SeqAnalysis/SeqAnalysis", "SeqAnalysis 程序路径")
 	flag.Parse()
 
 	// 初始化企业微信通知
@@ -117,21 +130,19 @@ func main() {
 	// 获取目录名
 	dirName := filepath.Base(currentDir)
 
-	// 根据批次类型构建不同的路径
-	var rawDataPath string
-	switch batchInfo.Type {
-	case BatchTypeNovo:
-		rawDataPath = fmt.Sprintf("/data2/wangyaoshen/novo-medical-customer-tj/CYB24030020/%s/Rawdata", batchInfo.Name)
-	case BatchTypeG99:
-		rawDataPath = fmt.Sprintf("/data2/wangyaoshen/Sequencing_data/G99/R21007100240139/%s/L01", batchInfo.Name)
-	default:
-		msg := fmt.Sprintf("未知的批次类型: %v", batchInfo.Type)
-		fmt.Println(msg)
-		sendErrorNotification(notifier, msg)
-		os.Exit(1)
+	// 根据批次类型或 -raw 模板构建路径
+	if rawTemplate == "" {
+		var ok bool
+		rawTemplate, ok = rawDataTemplates[batchInfo.Type]
+		if !ok {
+			msg := fmt.Sprintf("未知的批次类型: %v", batchInfo.Type)
+			fmt.Println(msg)
+			sendErrorNotification(notifier, msg)
+			os.Exit(1)
+		}
 	}
-
-	seqAnalysisPath := "/data2/wangyaoshen/src/SeqAnalysis/cmd/SeqAnalysis/SeqAnalysis"
+	rawDataPath := util.ExpandTemplate(rawTemplate, map[string]string{"batch": batchInfo.Name})
+	fmt.Printf("原始数据目录: %s\n", rawDataPath)
 
 	// 查找所有非merged的.xlsx文件
 	xlsxFiles, err := findXLSXFiles(currentDir)
@@ -519,9 +530,11 @@ func runSeqAnalysis(mergedFile, seqAnalysisPath, dirName string) error {
 		"-plot",
 		"-rc",
 		"-zip",
-		"-i", mergedFile,
-		"-o", outputDir,
 	}
+	if seqAnalysisConfig != "" {
+		args = []string{"-config", seqAnalysisConfig}
+	}
+	args = append(args, "-i", mergedFile, "-o", outputDir)
 	if suffixCol != "" {
 		args = append(args, "-suffix-col", suffixCol)
 	}
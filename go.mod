@@ -3,16 +3,31 @@ module SeqAnalysis
 go 1.25.0
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/cloudflare/ahocorasick v0.0.0-20240916140611-054963ec9396
 	github.com/go-echarts/go-echarts/v2 v2.2.6
 	github.com/klauspost/pgzip v1.2.6
 	github.com/liserjrqlxue/DNA v0.1.16
 	github.com/liserjrqlxue/goUtil v0.2.7
+	github.com/parquet-go/parquet-go v0.32.0
 	github.com/xuri/excelize/v2 v2.11.0
+	golang.org/x/exp v0.0.0-20230801115018-d63ba01acd4b
+	gonum.org/v1/gonum v0.14.0
 	gonum.org/v1/plot v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/tiendc/go-deepcopy v1.7.2 // indirect
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/parquet-go/bitpack v1.0.0 // indirect
+	github.com/parquet-go/jsonlite v1.0.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	github.com/tiendc/go-deepcopy v1.7.2 // indirect
+	github.com/twpayne/go-geom v1.6.1 // indirect
+	golang.org/x/sys v0.46.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
 
 require (
 	git.sr.ht/~sbinet/gg v0.5.0 // indirect
@@ -23,7 +38,7 @@ require (
 	github.com/go-latex/latex v0.0.0-20230307184459-12ec69307ad9 // indirect
 	github.com/go-pdf/fpdf v0.8.0 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/klauspost/compress v1.17.1 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/richardlehane/mscfb v1.0.7 // indirect
 	github.com/richardlehane/msoleps v1.0.6 // indirect
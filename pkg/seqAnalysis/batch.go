@@ -2,7 +2,9 @@ package seqAnalysis
 
 import (
 	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -10,6 +12,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,6 +28,47 @@ var (
 	isXlsx = regexp.MustCompile(`\.xlsx$`)
 )
 
+// optional batch analyses of Summary
+const (
+	AnalysisCompare       = "compare"
+	AnalysisCoupling      = "coupling"
+	AnalysisContext       = "context"
+	AnalysisBreakpoint    = "breakpoint"
+	AnalysisTruncation    = "truncation"
+	AnalysisContamination = "contamination"
+	AnalysisGel           = "gel"
+)
+
+// Analyses all optional batch analyses
+var Analyses = []string{
+	AnalysisCompare, AnalysisCoupling, AnalysisContext, AnalysisBreakpoint,
+	AnalysisTruncation, AnalysisContamination, AnalysisGel,
+}
+
+// DefaultAnalyses analyses of in-memory statistics,
+// context/breakpoint re-read per-sample files and breakpoint/gel plot figures, run only if set
+var DefaultAnalyses = []string{AnalysisCompare, AnalysisCoupling, AnalysisTruncation, AnalysisContamination}
+
+// ParseAnalyses comma separated analyses, all for all Analyses, empty or none for none
+func ParseAnalyses(s string) (map[string]bool, error) {
+	var analyses = make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "" || name == "none":
+		case name == "all":
+			for _, a := range Analyses {
+				analyses[a] = true
+			}
+		case slices.Contains(Analyses, name):
+			analyses[name] = true
+		default:
+			return nil, fmt.Errorf("unknown analysis %q, supported: all,none,%s", name, strings.Join(Analyses, ","))
+		}
+	}
+	return analyses, nil
+}
+
 type Batch struct {
 	OutputPrefix string
 	BasePrefix   string
@@ -35,7 +81,13 @@ type Batch struct {
 	LessMem   bool
 	Zip       bool
 	Plot      bool
+	PlotR     bool
 	NoTail    bool
+	Parquet   bool
+	// Pool merge reads of 平行 group into virtual sample [平行].pooled
+	Pool bool
+	// Analyses optional batch analyses of Summary to run, see ParseAnalyses
+	Analyses map[string]bool
 
 	TitleTar     []string
 	TitleStats   []string
@@ -45,32 +97,147 @@ type Batch struct {
 
 	InputInfo        []map[string]string
 	StatisticalField []map[string]string
+	// QCRules rules of qc_rules.txt
+	QCRules []*QCRule
+	// QCExit BatchRun return ErrQCFailed if any sample reach the level, WARN or FAIL, empty for never
+	QCExit string
 
 	SeqInfoMap       map[string]*SeqInfo
 	ParallelStatsMap map[string]*ParallelTest
 	FqSet            map[string][]*SeqInfo
+	// SeqExport sequences.parquet, nil if not Parquet
+	SeqExport *SeqExport
+	// Comparisons between 平行 groups
+	Comparisons []*Comparison
+	// Pools of 平行 groups, nil if not Pool
+	Pools map[string]*Pool
+	// Coupling model of steps of all samples, nil if failed
+	Coupling *CouplingModel
+	// ContextLength upstream nt of context error model
+	ContextLength int
+	// ContextModelPath context model json accumulated across batches, empty for this batch only
+	ContextModelPath string
+	// MotifK k of breakpoint motif of breakpoint.txt
+	MotifK int
+	// Contamination reads of each sample matching 合成序列 of each design
+	Contamination *ContaminationMatrix
+	// Unmatched collectors of reads not matching any 靶标序列 of each fastq, nil if UnmatchedTop is 0
+	Unmatched map[string]*UnmatchedCollector
 
 	SuffixCol string
+
+	// EtcDir config dir, files not found in it fallback to etc/ of exPath or embedded
+	EtcDir string
+	// Samples inline sample table of RunConfig, override input
+	Samples   []map[string]string
+	RunConfig *RunConfig
+	RunInfo   *RunInfo
+}
+
+// etcNames candidate names of config file, localized name first
+func etcNames(name string) []string {
+	if localized := LocaleFile(name); localized != "" {
+		return []string{localized, name}
+	}
+	return []string{name}
+}
+
+// etcSource path of config file name, embed:etc/[name] for embedded,
+// search EtcDir, etc/ of cfgPath then embedded, localized name first in each
+func (batch *Batch) etcSource(name, cfgPath string, cfgFS embed.FS) string {
+	var names = etcNames(name)
+	for _, dir := range []string{batch.EtcDir, filepath.Join(cfgPath, "etc")} {
+		if dir == "" {
+			continue
+		}
+		for _, n := range names {
+			var path = filepath.Join(dir, n)
+			if osUtil.FileExists(path) {
+				return path
+			}
+		}
+	}
+	for _, n := range names {
+		if _, err := fs.Stat(cfgFS, "etc/"+n); err == nil {
+			return "embed:etc/" + n
+		}
+	}
+	return "embed:etc/" + name
+}
+
+// openEtc open config file name from EtcDir first
+func (batch *Batch) openEtc(name, cfgPath string, cfgFS embed.FS) fs.File {
+	var source = batch.etcSource(name, cfgPath, cfgFS)
+	if strings.HasPrefix(source, "embed:") {
+		return simpleUtil.HandleError(cfgFS.Open(strings.TrimPrefix(source, "embed:")))
+	}
+	return osUtil.Open(source)
+}
+
+// EtcChecksums checksums of config files
+func (batch *Batch) EtcChecksums(cfgPath string, cfgFS embed.FS) {
+	for _, name := range EtcFiles {
+		var file = batch.openEtc(name, cfgPath, cfgFS)
+		var checksum = simpleUtil.HandleError(Checksum(batch.etcSource(name, cfgPath, cfgFS), file))
+		simpleUtil.CheckErr(file.Close())
+		batch.RunInfo.Etc = append(batch.RunInfo.Etc, checksum)
+	}
 }
 
 func (batch *Batch) LoadConfig(cfgPath string, cfgFS embed.FS) {
-	var sheetMap, _ = osUtil.FS2MapArray(osUtil.OpenFS("etc/sheet.txt", cfgPath, cfgFS), "\t", nil)
+	var sheetMap, _ = osUtil.FS2MapArray(batch.openEtc("sheet.txt", cfgPath, cfgFS), "\t", nil)
 	for _, m := range sheetMap {
 		batch.Sheets[m["Name"]] = m["SheetName"]
 		batch.SheetList = append(batch.SheetList, m["SheetName"])
 	}
 
-	batch.TitleTar = osUtil.FS2Array(osUtil.OpenFS("etc/title.Tar.txt", cfgPath, cfgFS))
-	batch.TitleStats = osUtil.FS2Array(osUtil.OpenFS("etc/title.Stats.txt", cfgPath, cfgFS))
-	batch.TitleSummary = osUtil.FS2Array(osUtil.OpenFS("etc/title.Summary.txt", cfgPath, cfgFS))
-	batch.StatisticalField, _ = osUtil.FS2MapArray(osUtil.OpenFS("etc/统计字段.txt", cfgPath, cfgFS), "\t", nil)
+	batch.TitleTar = osUtil.FS2Array(batch.openEtc("title.Tar.txt", cfgPath, cfgFS))
+	batch.TitleStats = osUtil.FS2Array(batch.openEtc("title.Stats.txt", cfgPath, cfgFS))
+	batch.TitleSummary = osUtil.FS2Array(batch.openEtc("title.Summary.txt", cfgPath, cfgFS))
+	batch.StatisticalField, _ = osUtil.FS2MapArray(batch.openEtc("统计字段.txt", cfgPath, cfgFS), "\t", nil)
+
+	var rules = batch.openEtc("qc_rules.txt", cfgPath, cfgFS)
+	batch.QCRules = simpleUtil.HandleError(LoadQCRules(rules))
+	simpleUtil.CheckErr(rules.Close())
+}
+
+// Validate check input before analysis, print all problems to stderr,
+// parsed samples kept in InputInfo and FqSet for LoadInput
+func (batch *Batch) Validate(input, workDir string) error {
+	var problems []*InputProblem
+	if batch.Samples != nil {
+		input = "samples of run config"
+		problems, batch.InputInfo, batch.FqSet = ValidateSamples(batch.Samples, workDir, batch.SuffixCol)
+	} else {
+		problems, batch.InputInfo, batch.FqSet = ValidateInput(input, workDir, batch.SuffixCol)
+	}
+	if FastqLocate != nil {
+		for _, err := range FastqLocate.Errors {
+			problems = append(problems, &InputProblem{Level: LevelWarn, Column: L("路径"), Msg: L("fastq查找不完整: ") + err.Error()})
+		}
+	}
+	WriteInputProblems(os.Stderr, problems)
+	if FastqLocate != nil {
+		WriteFastqMatches(os.Stderr, batch.InputInfo)
+	}
+	if HasInputError(problems) {
+		return fmt.Errorf("input validation failed: %s", input)
+	}
+	return nil
 }
 
-func (batch *Batch) LoadInput(input, workDir string) {
+// LoadInput parse input, skipped if already parsed by Validate
+func (batch *Batch) LoadInput(input, workDir string) (err error) {
+	if batch.InputInfo != nil {
+		return
+	}
 	// parse input
-	var inputInfo, fqSet = ParseInput(input, workDir, batch.SuffixCol)
-	batch.InputInfo = inputInfo
-	batch.FqSet = fqSet
+	if batch.Samples != nil {
+		batch.InputInfo, batch.FqSet = ParseSamples(batch.Samples, workDir, batch.SuffixCol)
+	} else {
+		batch.InputInfo, batch.FqSet, err = ParseInput(input, workDir, batch.SuffixCol)
+	}
+	return
 }
 
 func (batch *Batch) Prepare() {
@@ -78,6 +245,24 @@ func (batch *Batch) Prepare() {
 	simpleUtil.CheckErr(os.MkdirAll(batch.OutputPrefix, 0755))
 }
 
+// WriteRunConfig write effective run configuration next to results
+func (batch *Batch) WriteRunConfig(path string) {
+	if batch.RunConfig == nil {
+		return
+	}
+	simpleUtil.CheckErr(WriteRunConfig(path, batch.RunConfig))
+}
+
+// WriteFastqMatches write fastq located by template or auto-discovery of each sample
+func (batch *Batch) WriteFastqMatches(path string) {
+	if FastqLocate == nil {
+		return
+	}
+	file := osUtil.Create(path)
+	defer simpleUtil.DeferClose(file)
+	WriteFastqMatches(file, batch.InputInfo)
+}
+
 func (batch *Batch) WriteInfoTxt(path string) {
 	file := osUtil.Create(path)
 	defer simpleUtil.DeferClose(file)
@@ -98,27 +283,119 @@ func (batch *Batch) WriteInfoTxt(path string) {
 	}
 }
 
+// OpenSeqExport create sequences.parquet of all distinct sequences of samples if Parquet
+func (batch *Batch) OpenSeqExport() {
+	if batch.Parquet {
+		batch.SeqExport = simpleUtil.HandleError(NewSeqExport(filepath.Join(batch.OutputPrefix, "sequences.parquet")))
+	}
+}
+
+// CloseSeqExport close sequences.parquet after all samples done
+func (batch *Batch) CloseSeqExport() {
+	if batch.SeqExport != nil {
+		simpleUtil.CheckErr(batch.SeqExport.Close())
+		slog.Info("write sequences.parquet", "path", batch.SeqExport.Path)
+	}
+}
+
 func (batch *Batch) BuildSeqInfo() {
 	for _, data := range batch.InputInfo {
-		seqInfo := NewSeqInfo(data, batch.Sheets, batch.SheetList, batch.OutputPrefix, batch.LineLimit, batch.Long, batch.Rev, batch.UseRC, batch.UseKmer, batch.LessMem)
-		seqInfo.NoTail = batch.NoTail
+		seqInfo := NewSeqInfo(data, batch.Sheets, batch.SheetList, batch.OutputPrefix, batch.LineLimit, batch.Long, batch.Rev, batch.UseRC, batch.UseKmer, batch.LessMem, batch.NoTail)
+		seqInfo.Export = batch.SeqExport
 		batch.SeqInfoMap[seqInfo.Name] = seqInfo
 
 		for _, fq := range seqInfo.Fastqs {
 			batch.FqSet[fq] = append(batch.FqSet[fq], seqInfo)
 		}
 	}
+	if batch.Pool {
+		batch.BuildPools()
+	}
+}
+
+// BuildPools Pool of each 平行 group with more than one sample, skip group with different 靶标、合成序列
+func (batch *Batch) BuildPools() {
+	var (
+		groups = make(map[string][]*SeqInfo)
+		ids    []string
+	)
+	for _, data := range batch.InputInfo {
+		var seqInfo = batch.SeqInfoMap[data["id"]]
+		var id = seqInfo.ParallelTestID
+		if id == "" {
+			continue
+		}
+		if _, ok := groups[id]; !ok {
+			ids = append(ids, id)
+		}
+		groups[id] = append(groups[id], seqInfo)
+	}
+	batch.Pools = make(map[string]*Pool)
+	for _, id := range ids {
+		if len(groups[id]) < 2 {
+			continue
+		}
+		var pool, err = NewPool(id, groups[id], batch.OutputPrefix)
+		if err != nil {
+			slog.Warn("skip pool", "err", err)
+			continue
+		}
+		batch.Pools[id] = pool
+		for _, seqInfo := range groups[id] {
+			seqInfo.pool = pool
+		}
+	}
+}
+
+// RunPools write results of Pools, after ConcurrencyRun
+func (batch *Batch) RunPools() error {
+	var ids []string
+	for id := range batch.Pools {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := batch.Pools[id].Run(batch.OutputPrefix, batch.TitleTar, batch.TitleStats); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (batch *Batch) ConcurrencyRun(thread int) {
+// ConcurrencyRun SingleRun of all samples, errors of samples joined
+func (batch *Batch) ConcurrencyRun(thread int) error {
 	// limit goroutine concurrency
 	if thread == 0 {
 		thread = min(len(batch.InputInfo), runtime.GOMAXPROCS(0))
 	}
 
-	go ReadAllFastq(batch.FqSet)
+	var ids []string
+	for _, data := range batch.InputInfo {
+		ids = append(ids, data["id"])
+	}
+	if batch.Analyses[AnalysisContamination] {
+		batch.Contamination = NewContaminationMatrix(ids, batch.SeqInfoMap)
+	}
+
+	if UnmatchedTop > 0 {
+		batch.Unmatched = make(map[string]*UnmatchedCollector)
+		for fq, seqInfos := range batch.FqSet {
+			if c := NewUnmatchedCollector(fq, seqInfos); c != nil && fq != "" {
+				batch.Unmatched[fq] = c
+			}
+		}
+	}
+
+	var readDone = make(chan map[string]*FileChecksum)
+	go func() {
+		readDone <- ReadAllFastq(batch.FqSet, batch.Unmatched)
+	}()
 
-	var wg sync.WaitGroup
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
 	for id := range batch.SeqInfoMap {
 		wg.Add(1)
 		go func(id string) {
@@ -126,55 +403,300 @@ func (batch *Batch) ConcurrencyRun(thread int) {
 				wg.Done()
 			}()
 			slog.Info("SingleRun", "id", id)
-			batch.SeqInfoMap[id].SingleRun(batch.OutputPrefix, batch.TitleTar, batch.TitleStats)
+			if err := batch.SeqInfoMap[id].SingleRun(batch.OutputPrefix, batch.TitleTar, batch.TitleStats); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
 		}(id)
 	}
 
 	// wait goconcurrency thread to finish
 	wg.Wait()
+
+	var checksums = <-readDone
+	var fastqs []string
+	for fq := range checksums {
+		fastqs = append(fastqs, fq)
+	}
+	sort.Strings(fastqs)
+	for _, fq := range fastqs {
+		batch.RunInfo.Fastqs = append(batch.RunInfo.Fastqs, checksums[fq])
+	}
+	return errors.Join(errs...)
+}
+
+// CollectOutputs checksums of output files of each sample, before Summary for RunInfo sheet of summary.xlsx
+func (batch *Batch) CollectOutputs() {
+	var ids []string
+	for _, data := range batch.InputInfo {
+		ids = append(ids, data["id"])
+	}
+	batch.RunInfo.Samples = simpleUtil.HandleError(SampleOutputs(batch.OutputPrefix, ids))
+	batch.RunInfo.EndTime = time.Now()
+}
+
+// CollectBatchOutputs checksums of batch-level output files and zip, after Summary Visual and Compress
+func (batch *Batch) CollectBatchOutputs() {
+	var ids []string
+	for _, data := range batch.InputInfo {
+		ids = append(ids, data["id"])
+	}
+	batch.RunInfo.Outputs = simpleUtil.HandleError(BatchOutputs(batch.OutputPrefix, ids))
+	if batch.Zip {
+		var checksum, err = FileChecksumOf(batch.OutputPrefix + ".result.zip")
+		if err != nil {
+			slog.Error("CollectBatchOutputs", "err", err)
+		} else {
+			batch.RunInfo.Outputs = append(batch.RunInfo.Outputs, checksum)
+		}
+	}
+	batch.RunInfo.EndTime = time.Now()
+}
+
+// WriteRunJSON write run.json to result directory
+func (batch *Batch) WriteRunJSON() {
+	simpleUtil.CheckErr(batch.RunInfo.WriteJSON(filepath.Join(batch.OutputPrefix, "run.json")))
 }
 
 // CalculaterParallelTest calculater parallel test
 func (batch *Batch) CalculaterParallelTest() {
 	// 基于平行的统计
-	for _, seqInfo := range batch.SeqInfoMap {
+	for _, data := range batch.InputInfo {
+		var seqInfo = batch.SeqInfoMap[data["id"]]
 		var id = seqInfo.ParallelTestID
 		var p, ok = batch.ParallelStatsMap[id]
 		if !ok {
-			p = &ParallelTest{}
+			p = &ParallelTest{ID: id}
 			batch.ParallelStatsMap[id] = p
 		}
+		p.Samples = append(p.Samples, seqInfo.Name)
+		p.Depth = append(p.Depth, seqInfo.Stats["AnalyzedReadsNum"])
 		p.YieldCoefficient = append(p.YieldCoefficient, seqInfo.YieldCoefficient)
 		p.AverageYieldAccuracy = append(p.AverageYieldAccuracy, seqInfo.AverageYieldAccuracy)
 	}
-	for _, p := range batch.ParallelStatsMap {
+	for id, p := range batch.ParallelStatsMap {
+		if pool, ok := batch.Pools[id]; ok {
+			p.Pooled = pool.Info
+		}
 		p.Calculater()
 	}
 }
 
-func (batch *Batch) Summary(input string) {
+func (batch *Batch) Summary(input string) error {
+	batch.EvaluateQC()
+
 	// write summary.txt
 	SummaryTxt(batch.OutputPrefix, batch.TitleSummary, batch.InputInfo, batch.SeqInfoMap)
 
 	batch.CalculaterParallelTest()
 
+	// write compare.txt
+	if batch.Analyses[AnalysisCompare] {
+		batch.Comparisons = CompareGroups(batch.SeqInfoMap, batch.ParallelStatsMap)
+		for id, p := range batch.ParallelStatsMap {
+			p.Outliers = Outliers(batch.Comparisons, id)
+		}
+		WriteCompareTxt(batch.OutputPrefix, batch.Comparisons)
+	}
+
+	// write pooled.txt
+	WritePooledTxt(batch.OutputPrefix, batch.ParallelStatsMap)
+
+	var ids []string
+	for _, data := range batch.InputInfo {
+		ids = append(ids, data["id"])
+	}
+
+	// write coupling.txt
+	if batch.Analyses[AnalysisCoupling] {
+		batch.Coupling = FitBatchCoupling(ids, batch.SeqInfoMap)
+		WriteCouplingTxt(batch.OutputPrefix, batch.Coupling)
+	}
+
+	// write context.txt
+	if batch.Analyses[AnalysisContext] {
+		if err := batch.ContextReport(ids); err != nil {
+			return fmt.Errorf("context report: %w", err)
+		}
+	}
+
+	// write breakpoint.txt breakpoint.pdf/png
+	if batch.Analyses[AnalysisBreakpoint] {
+		batch.BreakpointReport(ids)
+	}
+
+	// write truncation.txt capping.txt
+	if batch.Analyses[AnalysisTruncation] {
+		WriteTruncationTxt(batch.OutputPrefix, ids, batch.SeqInfoMap)
+	}
+
+	// write contamination.txt
+	if batch.Contamination != nil {
+		WriteContaminationTxt(batch.OutputPrefix, ids, batch.Contamination)
+	}
+
+	// write gel.png gel.svg
+	if batch.Analyses[AnalysisGel] {
+		if err := WriteGel(batch.OutputPrefix, ids, batch.SeqInfoMap); err != nil {
+			slog.Error("WriteGel", "err", err)
+		}
+	}
+
+	// write unmatched.txt
+	if batch.Unmatched != nil {
+		WriteUnmatchedTxt(batch.OutputPrefix, batch.Unmatched, BatchIndexes(ids, batch.SeqInfoMap))
+	}
+
+	// write batch.json
+	if err := WriteBatchJSON(batch.OutputPrefix, input, batch.InputInfo, batch.SeqInfoMap, batch.ParallelStatsMap, batch.Coupling); err != nil {
+		return fmt.Errorf("write batch.json: %w", err)
+	}
+
 	// write summary.xlsx
-	if isXlsx.MatchString(input) {
+	if batch.Samples == nil && isXlsx.MatchString(input) {
 		// update from input.xlsx
-		Input2summaryXlsx(input, batch.OutputPrefix, batch.BasePrefix, batch.SuffixCol, batch.StatisticalField, batch.SeqInfoMap, batch.ParallelStatsMap)
+		Input2summaryXlsx(input, batch.OutputPrefix, batch.BasePrefix, batch.SuffixCol, batch.StatisticalField, batch.SeqInfoMap, batch.ParallelStatsMap, batch.Comparisons, batch.Coupling, batch.RunInfo)
 	} else {
-		SummaryXlsx(batch.OutputPrefix, batch.BasePrefix, batch.TitleSummary, batch.InputInfo, batch.SeqInfoMap)
+		SummaryXlsx(batch.OutputPrefix, batch.BasePrefix, batch.TitleSummary, batch.InputInfo, batch.SeqInfoMap, batch.ParallelStatsMap, batch.Comparisons, batch.Coupling, batch.RunInfo)
 	}
+	return nil
+}
+
+// ContextReport context error model of one.step.error.rate.txt of samples in ids,
+// added to ContextModelPath if set, hot contexts of the model to context.txt.
+// ErrRates kept by WriteStatsSheet are used, file is read only if missing
+func (batch *Batch) ContextReport(ids []string) error {
+	var model, err = NewContextModel(batch.ContextLength)
+	if err != nil {
+		return err
+	}
+	var records []*ErrRateRecord
+	for _, id := range ids {
+		if info := batch.SeqInfoMap[id]; info != nil && info.ErrRates != nil {
+			records = append(records, info.ErrRates...)
+			continue
+		}
+		var recs, err = LoadErrRateFile(filepath.Join(batch.OutputPrefix, id+".one.step.error.rate.txt"))
+		if err != nil {
+			slog.Warn("ContextReport skip sample", "id", id, "err", err)
+			continue
+		}
+		records = append(records, recs...)
+	}
+	source, err := filepath.Abs(batch.OutputPrefix)
+	if err != nil {
+		return err
+	}
+	if err = model.AddRecords(source, records, false); err != nil {
+		return err
+	}
+
+	if batch.ContextModelPath != "" {
+		var acc = model
+		if osUtil.FileExists(batch.ContextModelPath) {
+			if acc, err = LoadContextModel(batch.ContextModelPath); err != nil {
+				return err
+			}
+			if err = acc.Merge(model); err != nil {
+				slog.Warn("skip adding batch to context model", "model", batch.ContextModelPath, "err", err)
+			}
+		}
+		if err == nil {
+			if err = acc.Save(batch.ContextModelPath); err != nil {
+				return fmt.Errorf("save context model: %w", err)
+			}
+			slog.Info("save context model", "model", batch.ContextModelPath, "sources", len(acc.Sources))
+		}
+		model = acc
+	}
+
+	var reports = model.Report(CompareAlpha)
+	var hot int
+	for _, report := range reports {
+		if report.Hot {
+			hot++
+		}
+	}
+	slog.Info("ContextReport", "contexts", len(reports), "hot", hot)
+	WriteContextTxt(filepath.Join(batch.OutputPrefix, "context.txt"), reports)
+	return nil
+}
+
+// BreakpointReport motif enrichment of del1/del3 breakpoints of samples in ids to breakpoint.txt and breakpoint.pdf/png
+func (batch *Batch) BreakpointReport(ids []string) {
+	var analysis, err = NewBreakpointAnalysis(batch.OutputPrefix, ids, batch.SeqInfoMap, batch.MotifK)
+	if err != nil {
+		slog.Error("BreakpointReport", "err", err)
+		return
+	}
+	var enriched int
+	for _, m := range analysis.Motifs {
+		if m.Enriched {
+			enriched++
+		}
+	}
+	slog.Info("BreakpointReport", "samples", analysis.Samples, "motifs", len(analysis.Motifs), "enriched", enriched)
+	WriteBreakpointTxt(batch.OutputPrefix, analysis)
+	if err = WriteBreakpointFigure(batch.OutputPrefix, analysis); err != nil {
+		slog.Error("WriteBreakpointFigure", "err", err)
+	}
+}
+
+// EvaluateQC QC of all samples by QCRules, skip if no rules
+func (batch *Batch) EvaluateQC() {
+	if len(batch.QCRules) == 0 {
+		return
+	}
+	for _, data := range batch.InputInfo {
+		var info = batch.SeqInfoMap[data["id"]]
+		info.QC = info.EvaluateQC(batch.QCRules)
+		if info.QC.Level != QCPass {
+			slog.Warn("QC", "sample", info.Name, "level", info.QC.Level, "reason", info.QC.Reason())
+		}
+	}
+}
+
+// CheckQC ErrQCFailed with samples reach QCExit
+func (batch *Batch) CheckQC() error {
+	var failed []string
+	for _, data := range batch.InputInfo {
+		var info = batch.SeqInfoMap[data["id"]]
+		if info.QC != nil && QCReached(info.QC.Level, batch.QCExit) {
+			failed = append(failed, info.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %d samples reach %s: %s", ErrQCFailed, len(failed), strings.ToUpper(batch.QCExit), strings.Join(failed, ","))
+	}
+	return nil
 }
 
 func (batch *Batch) Visual(exPath string) error {
+	// offline html report, no R needed
+	err := WriteReportHTML(
+		filepath.Join(batch.OutputPrefix, "report.html"), batch.BasePrefix+" "+L("分析报告"),
+		batch.InputInfo, batch.SeqInfoMap, batch.ParallelStatsMap,
+	)
+	if err != nil {
+		slog.Error("WriteReportHTML error:", "err", err)
+		return err
+	}
+
 	binPath := path.Join(exPath, "bin")
-	if batch.Plot {
-		cmd := exec.Command("Rscript", filepath.Join(binPath, "plot.R"), batch.OutputPrefix)
+	if batch.Plot && !batch.PlotR {
+		// ErrRate.pdf histogram.pdf, no R needed
+		err = WriteFigures(batch.OutputPrefix, batch.InputInfo, batch.SeqInfoMap)
+		if err != nil {
+			slog.Error("WriteFigures error:", "err", err)
+			return err
+		}
+	} else if batch.Plot {
+		cmd := exec.Command("Rscript", filepath.Join(binPath, "plot.R"), batch.OutputPrefix, Locale)
 		cmd.Stderr = os.Stderr
 		cmd.Stdout = os.Stdout
 		slog.Info("Rscript", "cmd", cmd)
-		err := cmd.Run()
+		err = cmd.Run()
 		if err != nil {
 			slog.Error("Rscript error:", "err", err)
 			return err
@@ -206,10 +728,6 @@ func (batch *Batch) Compress() {
 	}
 }
 
-var (
-	NoTail bool
-)
-
 func (batch *Batch) BatchRun(input, workDir, exPath string, etcEMFS embed.FS, thread int) error {
 	now := time.Now()
 
@@ -217,21 +735,56 @@ func (batch *Batch) BatchRun(input, workDir, exPath string, etcEMFS embed.FS, th
 	defer simpleUtil.CheckErr(os.Chdir(cwd))
 	os.Chdir(workDir)
 
+	batch.RunInfo = NewRunInfo()
+	batch.RunInfo.Input = input
+	if batch.RunConfig != nil {
+		batch.RunInfo.Options = batch.RunConfig.Options
+	}
+
+	err := batch.Validate(input, workDir)
+	if err != nil {
+		return err
+	}
 	batch.LoadConfig(exPath, etcEMFS)
-	batch.LoadInput(input, workDir)
+	batch.EtcChecksums(exPath, etcEMFS)
+	err = batch.LoadInput(input, workDir)
+	if err != nil {
+		return err
+	}
 	batch.Prepare()
+	batch.WriteRunConfig(filepath.Join(batch.OutputPrefix, "run.config.yaml"))
 	batch.WriteInfoTxt(filepath.Join(batch.OutputPrefix, "info.txt"))
-	batch.NoTail = NoTail
+	batch.WriteFastqMatches(filepath.Join(batch.OutputPrefix, "fastq.match.txt"))
+	batch.OpenSeqExport()
 	batch.BuildSeqInfo()
-	batch.ConcurrencyRun(thread)
-	batch.Summary(input)
-	err := batch.Visual(exPath)
+	if err = batch.ConcurrencyRun(thread); err != nil {
+		return err
+	}
+	if err = batch.RunPools(); err != nil {
+		return err
+	}
+	batch.CloseSeqExport()
+	batch.CollectOutputs()
+	if err = batch.Summary(input); err != nil {
+		return err
+	}
+	err = batch.Visual(exPath)
 	if err != nil {
+		batch.CollectBatchOutputs()
+		batch.WriteRunJSON()
 		return err
 	}
+	if batch.Zip {
+		// run.json in zip, without checksum of zip itself
+		batch.CollectBatchOutputs()
+		batch.WriteRunJSON()
+	}
 	// Compress-Archive to zip file on windows only when *zip is true
 	batch.Compress()
+	// write run.json last, with all outputs and EndTime
+	batch.CollectBatchOutputs()
+	batch.WriteRunJSON()
 
 	slog.Info("Done", "time", time.Since(now))
-	return nil
+	return batch.CheckQC()
 }
@@ -0,0 +1,33 @@
+package seqAnalysis
+
+import (
+	"testing"
+)
+
+func TestParseAnalyses(t *testing.T) {
+	var tests = []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"none", 0},
+		{"all", len(Analyses)},
+		{" Context, gel ,", 2},
+		{"compare,all", len(Analyses)},
+	}
+	for _, tt := range tests {
+		var analyses, err = ParseAnalyses(tt.s)
+		if err != nil {
+			t.Fatalf("ParseAnalyses(%q) = %v", tt.s, err)
+		}
+		if len(analyses) != tt.want {
+			t.Errorf("ParseAnalyses(%q) = %v, want %d analyses", tt.s, analyses, tt.want)
+		}
+	}
+	if analyses, _ := ParseAnalyses(" Context, gel ,"); !analyses[AnalysisContext] || !analyses[AnalysisGel] {
+		t.Errorf("ParseAnalyses case/space = %v", analyses)
+	}
+	if _, err := ParseAnalyses("compare,foo"); err == nil {
+		t.Errorf("unknown analysis should fail")
+	}
+}
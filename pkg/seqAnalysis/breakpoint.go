@@ -0,0 +1,526 @@
+package seqAnalysis
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// 断点类型，对应 [id].del1.txt 及 [id].del3.txt
+const (
+	BreakpointDel1 = "del1"
+	BreakpointDel3 = "del3"
+)
+
+// BreakpointClasses classes of breakpoint, with min deleted nt
+var BreakpointClasses = []struct {
+	Class string
+	Span  int
+}{
+	{BreakpointDel1, 1},
+	{BreakpointDel3, 3},
+}
+
+// 断点上下游
+const (
+	BreakpointUp   = "up"
+	BreakpointDown = "down"
+)
+
+// DefaultMotifK default k of breakpoint motif
+const DefaultMotifK = 3
+
+// MaxMotifK max k of breakpoint motif
+const MaxMotifK = 6
+
+// BreakpointSite reads of deletion starting at Pos (0-based first deleted base of 合成序列)
+type BreakpointSite struct {
+	Pos   int
+	Count int
+}
+
+// ParseDel1 sites of [id].del1.txt: start, end, count, prev, deleted, next of single deletion
+func ParseDel1(path string) ([]*BreakpointSite, error) {
+	return parseBreakpoint(path, 6, 0, 2)
+}
+
+// ParseDel3 sites of [id].del3.txt: start, count, upper 3nt, down 3nt of continuous ≥3 deletion,
+// lines of count 0 written by WriteUpperDownNIL skipped
+func ParseDel3(path string) ([]*BreakpointSite, error) {
+	return parseBreakpoint(path, 4, 0, 1)
+}
+
+// parseBreakpoint sites of tab separated file with at least nCol columns, Pos and Count at posCol and countCol
+func parseBreakpoint(path string, nCol, posCol, countCol int) (sites []*BreakpointSite, err error) {
+	var file *os.File
+	file, err = os.Open(path)
+	if err != nil {
+		return
+	}
+	defer simpleUtil.DeferClose(file)
+
+	var scanner = bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		var line = scanner.Text()
+		if line == "" {
+			continue
+		}
+		var cols = strings.Split(line, "\t")
+		if len(cols) < nCol {
+			return nil, fmt.Errorf("%s:%d: %d columns, want %d", path, n, len(cols), nCol)
+		}
+		var site = &BreakpointSite{}
+		if site.Pos, err = strconv.Atoi(cols[posCol]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if site.Count, err = strconv.Atoi(cols[countCol]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if site.Count > 0 {
+			sites = append(sites, site)
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// breakpointSample deletion sites of one sample and its upstream padded sequence
+type breakpointSample struct {
+	// pad + 合成序列, 合成序列 starts at len(pad)
+	full string
+	pad  int
+	seq  int
+	// class -> sites
+	sites map[string][]*BreakpointSite
+}
+
+// newBreakpointSample upstream padded by last k nt of 靶标序列, or poly-A if Reverse as one.step.error.rate.txt
+func newBreakpointSample(info *SeqInfo, k int) *breakpointSample {
+	var pad string
+	if info.Reverse {
+		pad = strings.Repeat("A", k)
+	} else {
+		pad = info.IndexSeq[max(0, len(info.IndexSeq)-k):]
+	}
+	return &breakpointSample{
+		full:  pad + string(info.Seq),
+		pad:   len(pad),
+		seq:   len(info.Seq),
+		sites: make(map[string][]*BreakpointSite),
+	}
+}
+
+// kmer of side at pos, false if out of sequence
+func (sample *breakpointSample) kmer(side string, pos, k int) (string, bool) {
+	var start = sample.pad + pos
+	if side == BreakpointUp {
+		start -= k
+	}
+	if start < 0 || start+k > len(sample.full) {
+		return "", false
+	}
+	return sample.full[start : start+k], true
+}
+
+// BreakpointMotif enrichment of k-mer at side of breakpoints of class
+type BreakpointMotif struct {
+	Class string
+	Side  string
+	Kmer  string
+	// 断点数（样品×位置）
+	Sites int
+	// 断点 reads
+	Reads int
+	// 背景位置 k-mer 频率
+	Background float64
+	// 按各样品断点 reads 及背景频率的期望 reads
+	Expected float64
+	Ratio    float64
+	P        float64
+	PAdj     float64
+	Enriched bool
+}
+
+// BreakpointLogo base composition at offsets from breakpoint, offset 0 is first deleted base
+type BreakpointLogo struct {
+	Class   string
+	Offsets []int
+	// [offset][ACGT]
+	Freq       [][4]float64
+	Background [][4]float64
+	// 相对熵 bits
+	Information []float64
+}
+
+// BreakpointAnalysis motif enrichment of deletion breakpoints of samples
+type BreakpointAnalysis struct {
+	K       int
+	Samples int
+	Motifs  []*BreakpointMotif
+	Logos   []*BreakpointLogo
+}
+
+// baseIndex index of ACGT, -1 for others
+func baseIndex(b byte) int {
+	return strings.IndexByte("ACGT", b)
+}
+
+// NewBreakpointAnalysis load del1/del3 of samples in ids from outputDir,
+// k-mers upstream (k nt before) and downstream (k nt from first deleted base) of breakpoints
+// vs k-mers of all positions where deletion of class may start, expected reads of sample = its breakpoint reads × background frequency
+func NewBreakpointAnalysis(outputDir string, ids []string, SeqInfoMap map[string]*SeqInfo, k int) (*BreakpointAnalysis, error) {
+	if k < 1 || k > MaxMotifK {
+		return nil, fmt.Errorf("unsupported motif k %d, 1-%d", k, MaxMotifK)
+	}
+	var (
+		analysis = &BreakpointAnalysis{K: k}
+		samples  []*breakpointSample
+	)
+	for _, id := range ids {
+		var info = SeqInfoMap[id]
+		if info == nil {
+			continue
+		}
+		var sample = newBreakpointSample(info, k)
+		var del1, err1 = ParseDel1(filepath.Join(outputDir, id+".del1.txt"))
+		var del3, err3 = ParseDel3(filepath.Join(outputDir, id+".del3.txt"))
+		if err1 != nil || err3 != nil {
+			return nil, fmt.Errorf("breakpoint of %s: %v %v", id, err1, err3)
+		}
+		sample.sites[BreakpointDel1] = del1
+		sample.sites[BreakpointDel3] = del3
+		samples = append(samples, sample)
+	}
+	analysis.Samples = len(samples)
+
+	for _, c := range BreakpointClasses {
+		for _, side := range []string{BreakpointUp, BreakpointDown} {
+			analysis.Motifs = append(analysis.Motifs, breakpointMotifs(samples, c.Class, c.Span, side, k)...)
+		}
+		analysis.Logos = append(analysis.Logos, breakpointLogo(samples, c.Class, c.Span, k))
+	}
+	return analysis, nil
+}
+
+// BreakpointPermutations permutations of breakpoint reads over positions of each sample for P of breakpoint.txt
+var BreakpointPermutations = 10000
+
+// breakpointPositions reads at each position where deletion of class may start, with k-mer index of side
+type breakpointPositions struct {
+	kmers  []int
+	counts []int
+}
+
+// breakpointMotifs BreakpointMotif of class and side, sorted by Ratio descending.
+// P by permutation of reads of positions within each sample, reads of one site move together,
+// so clustered breakpoints of few sites are not significant as independent reads
+func breakpointMotifs(samples []*breakpointSample, class string, span int, side string, k int) (motifs []*BreakpointMotif) {
+	var (
+		byKmer = make(map[string]int)
+		get    = func(kmer string) *BreakpointMotif {
+			var i, ok = byKmer[kmer]
+			if !ok {
+				i = len(motifs)
+				byKmer[kmer] = i
+				motifs = append(motifs, &BreakpointMotif{Class: class, Side: side, Kmer: kmer})
+			}
+			return motifs[i]
+		}
+		total     int
+		bgCount   = make(map[string]int)
+		bgTotal   int
+		positions []*breakpointPositions
+	)
+	for _, sample := range samples {
+		var (
+			reads int
+			bg    = make(map[string]int)
+			n     = sample.seq - span + 1
+			pos   = &breakpointPositions{}
+			index = make(map[int]int)
+		)
+		for p := 0; p < n; p++ {
+			if kmer, ok := sample.kmer(side, p, k); ok {
+				get(kmer)
+				index[p] = len(pos.kmers)
+				pos.kmers = append(pos.kmers, byKmer[kmer])
+				pos.counts = append(pos.counts, 0)
+				bg[kmer]++
+			}
+		}
+		for _, site := range sample.sites[class] {
+			var i, ok = index[site.Pos]
+			if !ok {
+				continue
+			}
+			var m = motifs[pos.kmers[i]]
+			m.Sites++
+			m.Reads += site.Count
+			pos.counts[i] += site.Count
+			reads += site.Count
+		}
+		for kmer, c := range bg {
+			bgCount[kmer] += c
+			if reads > 0 {
+				get(kmer).Expected += float64(reads) * float64(c) / float64(len(pos.kmers))
+			}
+		}
+		bgTotal += len(pos.kmers)
+		total += reads
+		if reads > 0 {
+			positions = append(positions, pos)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	// 单侧置换检验 P(X ≥ Reads)
+	var (
+		exceed = make([]int, len(motifs))
+		sums   = make([]int, len(motifs))
+		rng    = rand.New(rand.NewSource(1))
+	)
+	for range BreakpointPermutations {
+		clear(sums)
+		for _, pos := range positions {
+			rng.Shuffle(len(pos.counts), func(i, j int) { pos.counts[i], pos.counts[j] = pos.counts[j], pos.counts[i] })
+			for i, c := range pos.counts {
+				sums[pos.kmers[i]] += c
+			}
+		}
+		for i, m := range motifs {
+			if sums[i] >= m.Reads {
+				exceed[i]++
+			}
+		}
+	}
+	// 只出现在无断点 reads 样品中的 k-mer 不检验
+	var tested []*BreakpointMotif
+	for i, m := range motifs {
+		if m.Expected == 0 {
+			continue
+		}
+		m.Background = float64(bgCount[m.Kmer]) / float64(bgTotal)
+		m.Ratio = float64(m.Reads) / m.Expected
+		m.P = float64(exceed[i]+1) / float64(BreakpointPermutations+1)
+		tested = append(tested, m)
+	}
+	motifs = tested
+	sort.Slice(motifs, func(i, j int) bool {
+		if motifs[i].Ratio != motifs[j].Ratio {
+			return motifs[i].Ratio > motifs[j].Ratio
+		}
+		return motifs[i].Kmer < motifs[j].Kmer
+	})
+	var p []float64
+	for _, m := range motifs {
+		p = append(p, m.P)
+	}
+	for i, adj := range BHAdjust(p) {
+		motifs[i].PAdj = adj
+		motifs[i].Enriched = adj < CompareAlpha && motifs[i].Ratio > 1
+	}
+	return
+}
+
+// breakpointLogo base composition of offsets -k..k-1 of breakpoints of class weighted by reads,
+// background of all positions weighted by breakpoint reads of sample
+func breakpointLogo(samples []*breakpointSample, class string, span, k int) *BreakpointLogo {
+	var logo = &BreakpointLogo{
+		Class:       class,
+		Freq:        make([][4]float64, 2*k),
+		Background:  make([][4]float64, 2*k),
+		Information: make([]float64, 2*k),
+	}
+	for o := -k; o < k; o++ {
+		logo.Offsets = append(logo.Offsets, o)
+	}
+	for _, sample := range samples {
+		var reads int
+		for _, site := range sample.sites[class] {
+			for i, o := range logo.Offsets {
+				var j = sample.pad + site.Pos + o
+				if j >= 0 && j < len(sample.full) {
+					if b := baseIndex(sample.full[j]); b >= 0 {
+						logo.Freq[i][b] += float64(site.Count)
+					}
+				}
+			}
+			reads += site.Count
+		}
+		if reads == 0 {
+			continue
+		}
+		var n = sample.seq - span + 1
+		for pos := 0; pos < n; pos++ {
+			for i, o := range logo.Offsets {
+				var j = sample.pad + pos + o
+				if j >= 0 && j < len(sample.full) {
+					if b := baseIndex(sample.full[j]); b >= 0 {
+						logo.Background[i][b] += float64(reads) / float64(n)
+					}
+				}
+			}
+		}
+	}
+	for i := range logo.Offsets {
+		normalize(&logo.Freq[i])
+		normalize(&logo.Background[i])
+		for b := range 4 {
+			if f, q := logo.Freq[i][b], logo.Background[i][b]; f > 0 && q > 0 {
+				logo.Information[i] += f * math.Log2(f/q)
+			}
+		}
+	}
+	return logo
+}
+
+// normalize frequencies sum to 1, keep zeros
+func normalize(v *[4]float64) {
+	var sum float64
+	for _, x := range v {
+		sum += x
+	}
+	if sum > 0 {
+		for i := range v {
+			v[i] /= sum
+		}
+	}
+}
+
+// breakpointTitle titles of breakpoint.txt
+func breakpointTitle() []string {
+	return []string{
+		L("排名"), L("断点类型"), L("方向"), "kmer", L("断点数"), "reads", L("背景频率"), L("期望reads"), L("倍数"), "P", L("校正P"), L("富集"),
+	}
+}
+
+// Row values of BreakpointMotif with rank
+func (m *BreakpointMotif) Row(rank int) []string {
+	var enriched = ""
+	if m.Enriched {
+		enriched = "*"
+	}
+	return []string{
+		strconv.Itoa(rank), m.Class, m.Side, m.Kmer, strconv.Itoa(m.Sites), strconv.Itoa(m.Reads),
+		formatFloat(m.Background), formatFloat(m.Expected), formatFloat(m.Ratio), formatFloat(m.P), formatFloat(m.PAdj), enriched,
+	}
+}
+
+// WriteBreakpointTxt write breakpoint.txt, ranked by Ratio in each class and side
+func WriteBreakpointTxt(resultDir string, analysis *BreakpointAnalysis) {
+	var out = osUtil.Create(filepath.Join(resultDir, "breakpoint.txt"))
+	defer simpleUtil.DeferClose(out)
+
+	fmtUtil.FprintStringArray(out, breakpointTitle(), "\t")
+	var rank int
+	for i, m := range analysis.Motifs {
+		if i == 0 || m.Class != analysis.Motifs[i-1].Class || m.Side != analysis.Motifs[i-1].Side {
+			rank = 0
+		}
+		rank++
+		fmtUtil.FprintStringArray(out, m.Row(rank), "\t")
+	}
+}
+
+// logoColors colors of ACGT as common sequence logo
+var logoColors = [4]color.Color{
+	color.RGBA{R: 0x10, G: 0x96, B: 0x48, A: 255},
+	color.RGBA{R: 0x25, G: 0x5c, B: 0x99, A: 255},
+	color.RGBA{R: 0xf7, G: 0xb3, B: 0x2b, A: 255},
+	color.RGBA{R: 0xd6, G: 0x28, B: 0x39, A: 255},
+}
+
+// logoStacks stacked bases of each offset, height of base = Freq × Information, larger on top
+type logoStacks struct {
+	*BreakpointLogo
+}
+
+// Plot implements plot.Plotter
+func (logo logoStacks) Plot(c draw.Canvas, p *plot.Plot) {
+	var (
+		trX, trY = p.Transforms(&c)
+		style    = p.X.Tick.Label
+	)
+	style.Color = color.White
+	style.XAlign = draw.XCenter
+	style.YAlign = draw.YCenter
+	for i, o := range logo.Offsets {
+		var order = []int{0, 1, 2, 3}
+		sort.SliceStable(order, func(a, b int) bool { return logo.Freq[i][order[a]] < logo.Freq[i][order[b]] })
+		var y float64
+		for _, b := range order {
+			var h = logo.Freq[i][b] * logo.Information[i]
+			if h <= 0 {
+				continue
+			}
+			var (
+				x0 = trX(float64(o) - 0.45)
+				x1 = trX(float64(o) + 0.45)
+				y0 = trY(y)
+				y1 = trY(y + h)
+			)
+			c.FillPolygon(logoColors[b], c.ClipPolygonXY([]vg.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}))
+			if y1-y0 > style.Height("A") {
+				c.FillText(style, vg.Point{X: (x0 + x1) / 2, Y: (y0 + y1) / 2}, "ACGT"[b:b+1])
+			}
+			y += h
+		}
+	}
+}
+
+// DataRange implements plot.DataRanger
+func (logo logoStacks) DataRange() (xmin, xmax, ymin, ymax float64) {
+	xmin, xmax = float64(logo.Offsets[0])-0.5, float64(logo.Offsets[len(logo.Offsets)-1])+0.5
+	for _, v := range logo.Information {
+		ymax = max(ymax, v)
+	}
+	// 无富集时保留坐标轴
+	ymax = max(ymax, 0.1)
+	return
+}
+
+// logoKey legend thumbnail of base color
+type logoKey struct {
+	color.Color
+}
+
+// Thumbnail implements plot.Thumbnailer
+func (key logoKey) Thumbnail(c *draw.Canvas) {
+	c.FillPolygon(key.Color, []vg.Point{c.Min, {X: c.Max.X, Y: c.Min.Y}, c.Max, {X: c.Min.X, Y: c.Max.Y}})
+}
+
+// logoPlot logo of breakpoint, red line between upstream and first deleted base
+func logoPlot(logo *BreakpointLogo) *plot.Plot {
+	var p = newFigurePlot(logo.Class+" breakpoint", "offset from first deleted base", "bits")
+	p.Add(logoStacks{logo}, vLines{-0.5})
+	p.X.Tick.Marker = stepTicks(1)
+	for b := range 4 {
+		p.Legend.Add("ACGT"[b:b+1], logoKey{logoColors[b]})
+	}
+	return p
+}
+
+// WriteBreakpointFigure write breakpoint.pdf/png of logos
+func WriteBreakpointFigure(resultDir string, analysis *BreakpointAnalysis) error {
+	var plots []*plot.Plot
+	for _, logo := range analysis.Logos {
+		plots = append(plots, logoPlot(logo))
+	}
+	return WriteFigure(filepath.Join(resultDir, "breakpoint"), []figurePage{facetPage(plots, 1)})
+}
@@ -0,0 +1,65 @@
+package seqAnalysis
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBreakpointAnalysis(t *testing.T) {
+	var dir = t.TempDir()
+	var write = func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 合成序列 ACGTACGT，单缺失集中在 G（位置 2、6）
+	write("s.del1.txt", "2\t3\t30\tC\tG\tT\n6\t7\t10\tC\tG\t$\n0\t1\t5\t^\tA\tC\n")
+	write("s.del3.txt", "4\t8\tGT\tACG\n0\t0\tGT\tACG\n")
+	var SeqInfoMap = map[string]*SeqInfo{"s": {Name: "s", IndexSeq: "CTGA", Seq: []byte("ACGTACGT")}}
+
+	var analysis, err = NewBreakpointAnalysis(dir, []string{"s"}, SeqInfoMap, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var top = analysis.Motifs[0]
+	// 背景 up 2-mer：GA AC CG GT TA AC CG GT，G 上游 AC 占 2/8
+	if top.Class != BreakpointDel1 || top.Side != BreakpointUp || top.Kmer != "AC" || top.Sites != 2 ||
+		top.Reads != 40 || math.Abs(top.Expected-45*2.0/8) > 1e-12 || top.Background != 0.25 {
+		t.Errorf("top motif = %+v", top)
+	}
+	for _, m := range analysis.Motifs {
+		if m.Class == BreakpointDel1 && m.Side == BreakpointDown && m.Kmer == "GT" && (m.Reads != 40 || m.Sites != 2) {
+			t.Errorf("del1 down GT = %+v", m)
+		}
+		if m.Class == BreakpointDel3 && m.Reads > 0 && (m.Sites != 1 || m.Reads != 8) {
+			t.Errorf("del3 = %+v", m)
+		}
+	}
+	var logo = analysis.Logos[0]
+	// offset 0 为缺失碱基：G 40/45，A 5/45
+	if logo.Offsets[2] != 0 || math.Abs(logo.Freq[2][2]-40.0/45) > 1e-12 || logo.Information[2] <= 0 {
+		t.Errorf("logo = %+v", logo)
+	}
+
+	// 单个高 reads 断点：reads 随位置一起置换，不应富集
+	write("h.del1.txt", "10\t11\t1000\tG\tC\tT\n")
+	write("h.del3.txt", "")
+	SeqInfoMap["h"] = &SeqInfo{Name: "h", IndexSeq: "CTGA", Seq: []byte("ACGTTGCAAGCTTCGAGGATCCATGCAAGTCCGTAGCTAG")}
+	if analysis, err = NewBreakpointAnalysis(dir, []string{"h"}, SeqInfoMap, 2); err != nil {
+		t.Fatal(err)
+	}
+	top = analysis.Motifs[0]
+	if top.Kmer != "AG" || top.Reads != 1000 || top.Enriched || top.P < 0.01 {
+		t.Errorf("single site motif = %+v", top)
+	}
+
+	if _, err = NewBreakpointAnalysis(dir, []string{"s"}, SeqInfoMap, MaxMotifK+1); err == nil {
+		t.Errorf("k > MaxMotifK should fail")
+	}
+	write("s.del1.txt", "2\t3\n")
+	if _, err = NewBreakpointAnalysis(dir, []string{"s"}, SeqInfoMap, 2); err == nil {
+		t.Errorf("invalid del1 should fail")
+	}
+}
@@ -0,0 +1,171 @@
+package seqAnalysis
+
+import (
+	"fmt"
+
+	math2 "github.com/liserjrqlxue/goUtil/math"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+)
+
+// errorClasses 错误分类，Charts 堆叠柱状图的系列
+var errorClasses = []struct {
+	Title string
+	Key   string
+}{
+	{"缺失", "Deletion"},
+	{"插入", "ErrorInsReadsNum"},
+	{"插入+缺失", "ErrorInsDelReadsNum"},
+	{"突变", "ErrorMutReadsNum"},
+	{"其他错误", "ErrorOtherReadsNum"},
+}
+
+// sheetRange absolute reference of sheet!col1row1:col2row2 for chart series
+func sheetRange(sheet string, col1, row1, col2, row2 int) string {
+	return fmt.Sprintf(
+		"'%s'!%s:%s",
+		sheet,
+		simpleUtil.HandleError(excelize.CoordinatesToCellName(col1, row1, true)),
+		simpleUtil.HandleError(excelize.CoordinatesToCellName(col2, row2, true)),
+	)
+}
+
+func chartTitle(title string) excelize.ChartTitle {
+	return excelize.ChartTitle{Paragraph: []excelize.RichTextRun{{Text: title}}}
+}
+
+// AddColorScale 3 色阶 红-黄-绿 of column col from row1 to row2, skip if col < 1
+func AddColorScale(excel *excelize.File, sheet string, col, row1, row2 int) {
+	if col < 1 || row2 < row1 {
+		return
+	}
+	simpleUtil.CheckErr(
+		excel.SetConditionalFormat(
+			sheet,
+			simpleUtil.HandleError(excelize.CoordinatesToCellName(col, row1))+":"+
+				simpleUtil.HandleError(excelize.CoordinatesToCellName(col, row2)),
+			[]excelize.ConditionalFormatOptions{
+				{
+					Type:     "3_color_scale",
+					Criteria: "=",
+					MinType:  "min",
+					MidType:  "percentile",
+					MidValue: "50",
+					MaxType:  "max",
+					MinColor: "#F8696B",
+					MidColor: "#FFEB84",
+					MaxColor: "#63BE7B",
+				},
+			},
+		),
+	)
+}
+
+// AddCharts2Sheet add Charts sheet to summary.xlsx:
+// 收率、单步准确率 柱状图 and 错误分类 堆叠柱状图 of samples in list
+func AddCharts2Sheet(excel *excelize.File, list []string, SeqInfoMap map[string]*SeqInfo) {
+	if len(list) == 0 {
+		return
+	}
+	var (
+		sheetName = "Charts"
+		title     = []any{L("名字"), L("收率"), L("单步准确率")}
+		lastRow   = len(list) + 1
+		// 图表在数据表右侧
+		chartCol = len(errorClasses) + 5
+		width    = uint(max(480, 40*len(list)+200))
+	)
+	for _, class := range errorClasses {
+		title = append(title, L(class.Title))
+	}
+	simpleUtil.HandleError(excel.NewSheet(sheetName))
+	simpleUtil.CheckErr(excel.SetColWidth(sheetName, "A", "A", 20))
+	SetRow(excel, sheetName, 1, 1, title)
+	for i, id := range list {
+		var (
+			info  = SeqInfoMap[id]
+			stats = info.Stats
+			row   = []any{id, info.YieldCoefficient, info.AverageYieldAccuracy}
+		)
+		for _, class := range errorClasses {
+			row = append(row, math2.DivisionInt(stats[class.Key], stats["AnalyzedReadsNum"]))
+		}
+		SetRow(excel, sheetName, 1, i+2, row)
+	}
+	AddColorScale(excel, sheetName, 2, 2, lastRow)
+	AddColorScale(excel, sheetName, 3, 2, lastRow)
+
+	var (
+		categories = sheetRange(sheetName, 1, 2, 1, lastRow)
+		addChart   = func(row int, chart *excelize.Chart) {
+			chart.Dimension = excelize.ChartDimension{Width: width, Height: 300}
+			if chart.Legend.Position == "" {
+				chart.Legend.Position = "bottom"
+			}
+			simpleUtil.CheckErr(
+				excel.AddChart(sheetName, simpleUtil.HandleError(excelize.CoordinatesToCellName(chartCol, row)), chart),
+			)
+		}
+		seriesOf = func(col int) excelize.ChartSeries {
+			return excelize.ChartSeries{
+				Name:       sheetRange(sheetName, col, 1, col, 1),
+				Categories: categories,
+				Values:     sheetRange(sheetName, col, 2, col, lastRow),
+			}
+		}
+		classSeries []excelize.ChartSeries
+	)
+	for i := range errorClasses {
+		classSeries = append(classSeries, seriesOf(4+i))
+	}
+
+	addChart(1, &excelize.Chart{
+		Type:   excelize.Col,
+		Series: []excelize.ChartSeries{seriesOf(2)},
+		Title:  chartTitle(L("收率")),
+		Legend: excelize.ChartLegend{Position: "none"},
+	})
+	addChart(17, &excelize.Chart{
+		Type:   excelize.Col,
+		Series: []excelize.ChartSeries{seriesOf(3)},
+		Title:  chartTitle(L("单步准确率")),
+	})
+	addChart(33, &excelize.Chart{
+		Type:   excelize.ColStacked,
+		Series: classSeries,
+		Title:  chartTitle(L("错误分类")),
+	})
+}
+
+// AddStepChart add 位置错误率 line chart of Del/Ins/Mut columns to Stats sheet,
+// titleRow is row of TitleTar, steps in following rows
+func (seqInfo *SeqInfo) AddStepChart(sheet string, titleRow, nCol int) {
+	var lastRow = titleRow + len(seqInfo.Seq)
+	if len(seqInfo.Seq) == 0 {
+		return
+	}
+	var series []excelize.ChartSeries
+	// C:E Del Ins Mut
+	for col := 3; col <= 5; col++ {
+		series = append(series, excelize.ChartSeries{
+			Name:       sheetRange(sheet, col, titleRow, col, titleRow),
+			Categories: sheetRange(sheet, 1, titleRow+1, 1, lastRow),
+			Values:     sheetRange(sheet, col, titleRow+1, col, lastRow),
+			Marker:     excelize.ChartMarker{Symbol: "none"},
+		})
+	}
+	simpleUtil.CheckErr(
+		seqInfo.xlsx.AddChart(
+			sheet,
+			simpleUtil.HandleError(excelize.CoordinatesToCellName(nCol+2, 2)),
+			&excelize.Chart{
+				Type:      excelize.Line,
+				Series:    series,
+				Title:     chartTitle(L("位置错误率")),
+				Dimension: excelize.ChartDimension{Width: uint(max(640, 12*len(seqInfo.Seq))), Height: 320},
+				Legend:    excelize.ChartLegend{Position: "bottom"},
+				XAxis:     excelize.ChartAxis{Title: chartTitle(L("合成位置"))},
+			},
+		),
+	)
+}
@@ -0,0 +1,204 @@
+package seqAnalysis
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// CI methods of proportions
+const (
+	CIWilson         = "wilson"
+	CIClopperPearson = "cp"
+)
+
+// CIMethod method of proportion intervals, wilson or cp (Clopper–Pearson)
+var CIMethod = CIWilson
+
+// CILevel confidence level of intervals
+var CILevel = 0.95
+
+// BootstrapN resamples of bootstrap interval of AverageYieldAccuracy
+var BootstrapN = 1000
+
+// Interval confidence interval
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// SampleCI confidence intervals of one sample
+type SampleCI struct {
+	Method string  `json:"method"`
+	Level  float64 `json:"level"`
+	// 收率
+	Yield Interval `json:"yield"`
+	// 收率平均准确率，bootstrap 百分位区间
+	AverageYieldAccuracy Interval `json:"averageYieldAccuracy"`
+	// 错误总数及各错误分类占分析reads比例，key 同 Stats
+	Stats map[string]Interval `json:"stats"`
+}
+
+// Wilson score interval of x successes in n trials
+func Wilson(x, n int, level float64) Interval {
+	if n <= 0 {
+		return Interval{0, 1}
+	}
+	var (
+		z      = distuv.UnitNormal.Quantile(1 - (1-level)/2)
+		p      = float64(x) / float64(n)
+		z2n    = z * z / float64(n)
+		center = (p + z2n/2) / (1 + z2n)
+		half   = z * math.Sqrt(p*(1-p)/float64(n)+z2n/float64(4*n)) / (1 + z2n)
+	)
+	return Interval{math.Max(0, center-half), math.Min(1, center+half)}
+}
+
+// ClopperPearson exact interval of x successes in n trials
+func ClopperPearson(x, n int, level float64) Interval {
+	if n <= 0 {
+		return Interval{0, 1}
+	}
+	var (
+		alpha    = 1 - level
+		interval = Interval{0, 1}
+	)
+	if x > 0 {
+		interval.Low = distuv.Beta{Alpha: float64(x), Beta: float64(n - x + 1)}.Quantile(alpha / 2)
+	}
+	if x < n {
+		interval.High = distuv.Beta{Alpha: float64(x + 1), Beta: float64(n - x)}.Quantile(1 - alpha/2)
+	}
+	return interval
+}
+
+// ProportionCI interval of x/n by CIMethod and CILevel
+func ProportionCI(x, n int) Interval {
+	if CIMethod == CIClopperPearson {
+		return ClopperPearson(x, n, CILevel)
+	}
+	return Wilson(x, n, CILevel)
+}
+
+// BootstrapGeoMean percentile interval of geometric mean of step accuracies,
+// n reads enter first step, each read pass step i with accuracies[i], resample reads seed times fixed for reproducibility
+func BootstrapGeoMean(n int, accuracies []float64, level float64, resamples int, seed uint64) Interval {
+	if n <= 0 || len(accuracies) == 0 || resamples <= 0 {
+		return Interval{0, 1}
+	}
+	var (
+		src    = rand.NewSource(seed)
+		length = float64(len(accuracies))
+		means  = make([]float64, resamples)
+	)
+	for b := range means {
+		// 逐步存活 reads 数 ~ Binomial(进入 reads 数, 单步准确率)，等价于按失败位置多项重抽样
+		var alive = n
+		for _, p := range accuracies {
+			if alive == 0 {
+				break
+			}
+			alive = int(distuv.Binomial{N: float64(alive), P: math.Min(1, math.Max(0, p)), Src: src}.Rand())
+		}
+		means[b] = math.Pow(float64(alive)/float64(n), 1/length)
+	}
+	sort.Float64s(means)
+	var alpha = (1 - level) / 2
+	return Interval{quantileSorted(means, alpha), quantileSorted(means, 1-alpha)}
+}
+
+// quantileSorted linear interpolated quantile of sorted values
+func quantileSorted(values []float64, p float64) float64 {
+	var (
+		pos = p * float64(len(values)-1)
+		i   = int(pos)
+	)
+	if i >= len(values)-1 {
+		return values[len(values)-1]
+	}
+	return values[i] + (pos-float64(i))*(values[i+1]-values[i])
+}
+
+// Right reads count of right base of the step, sum of A/C/G/T for N
+func (step *StepStat) Right() int {
+	switch step.Base {
+	case "A":
+		return step.A
+	case "C":
+		return step.C
+	case "G":
+		return step.G
+	case "T":
+		return step.T
+	}
+	return step.A + step.C + step.G + step.T
+}
+
+// ciStatsKeys Stats keys with intervals, 错误总数 and errorClasses
+func ciStatsKeys() []string {
+	var keys = []string{"ErrorReadsNum"}
+	for _, class := range errorClasses {
+		keys = append(keys, class.Key)
+	}
+	return keys
+}
+
+// ConfidenceIntervals SampleCI of seqInfo, after Steps filled by WriteStatsSheet
+func (seqInfo *SeqInfo) ConfidenceIntervals() *SampleCI {
+	var (
+		n  = seqInfo.Stats["AnalyzedReadsNum"]
+		ci = &SampleCI{
+			Method: CIMethod,
+			Level:  CILevel,
+			Yield:  Interval{0, 1},
+			Stats:  make(map[string]Interval),
+		}
+		accuracies []float64
+	)
+	for _, step := range seqInfo.Steps {
+		accuracies = append(accuracies, step.StepAccuracy)
+	}
+	if len(seqInfo.Steps) > 0 {
+		ci.Yield = ProportionCI(seqInfo.Steps[len(seqInfo.Steps)-1].Right(), n)
+	}
+	ci.AverageYieldAccuracy = BootstrapGeoMean(n, accuracies, CILevel, BootstrapN, 1)
+	for _, key := range ciStatsKeys() {
+		ci.Stats[key] = ProportionCI(seqInfo.Stats[key], n)
+	}
+	return ci
+}
+
+// ciTitle titles of CIRow
+func ciTitle() (title []string) {
+	var names = []string{L("收率"), L("单步准确率"), L("错误总数")}
+	for _, class := range errorClasses {
+		names = append(names, L(class.Title))
+	}
+	for _, name := range names {
+		title = append(title, name+L("-CI下限"), name+L("-CI上限"))
+	}
+	return
+}
+
+// CIRow interval columns of summary, same order as ciTitle
+func (seqInfo *SeqInfo) CIRow() (row []any) {
+	var ci = seqInfo.CI
+	if ci == nil {
+		ci = seqInfo.ConfidenceIntervals()
+	}
+	row = append(row, ci.Yield.Low, ci.Yield.High, ci.AverageYieldAccuracy.Low, ci.AverageYieldAccuracy.High)
+	for _, key := range ciStatsKeys() {
+		row = append(row, ci.Stats[key].Low, ci.Stats[key].High)
+	}
+	return
+}
+
+// stepCITitle titles of interval columns of step table
+func stepCITitle() []any {
+	return []any{
+		L("单步准确率") + L("-CI下限"), L("单步准确率") + L("-CI上限"),
+		L("收率") + L("-CI下限"), L("收率") + L("-CI上限"),
+	}
+}
@@ -0,0 +1,37 @@
+package seqAnalysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProportionCI(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		got       Interval
+		low, high float64
+	}{
+		{"wilson", Wilson(5, 10, 0.95), 0.2366, 0.7634},
+		{"wilson 0", Wilson(0, 10, 0.95), 0, 0.2775},
+		{"cp", ClopperPearson(5, 10, 0.95), 0.1871, 0.8129},
+		{"cp n", ClopperPearson(10, 10, 0.95), 0.6915, 1},
+	} {
+		if math.Abs(tc.got.Low-tc.low) > 1e-4 || math.Abs(tc.got.High-tc.high) > 1e-4 {
+			t.Errorf("%s = %+v, want [%v, %v]", tc.name, tc.got, tc.low, tc.high)
+		}
+	}
+}
+
+func TestBootstrapGeoMean(t *testing.T) {
+	var (
+		accuracies = []float64{0.99, 0.98, 0.97, 0.99}
+		point      = math.Pow(0.99*0.98*0.97*0.99, 1.0/4)
+		ci         = BootstrapGeoMean(5000, accuracies, 0.95, 500, 1)
+	)
+	if ci.Low >= point || ci.High <= point || ci.High-ci.Low > 0.01 {
+		t.Errorf("BootstrapGeoMean = %+v, point %v", ci, point)
+	}
+	if again := BootstrapGeoMean(5000, accuracies, 0.95, 500, 1); again != ci {
+		t.Errorf("BootstrapGeoMean not reproducible: %+v != %+v", again, ci)
+	}
+}
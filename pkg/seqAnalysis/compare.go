@@ -0,0 +1,357 @@
+package seqAnalysis
+
+import (
+	"log/slog"
+	"math"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	math2 "github.com/liserjrqlxue/goUtil/math"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// CompareAlpha significance level of group comparison and outlier
+var CompareAlpha = 0.05
+
+// tests of Comparison, BH correction within each test
+const (
+	CompareWelchANOVA = "WelchANOVA"
+	CompareWelchT     = "WelchT"
+	CompareChiSquare  = "ChiSquare"
+	CompareGrubbs     = "Grubbs"
+)
+
+// Comparison one test between 平行 groups, or Grubbs outlier of one replicate (Group2 is sample)
+type Comparison struct {
+	Test   string
+	Metric string
+	Group1 string
+	Group2 string
+	// WelchT: 组均值，ChiSquare: 合并 reads 比例，Grubbs: 样品值及组均值
+	Value1    float64
+	Value2    float64
+	Statistic float64
+	DF        string
+	P         float64
+	// PAdj Benjamini–Hochberg of P within Test
+	PAdj float64
+}
+
+// Significant PAdj < CompareAlpha
+func (c *Comparison) Significant() bool {
+	return c.PAdj < CompareAlpha
+}
+
+// WelchT Welch t-test of x and y, NaN if n < 2 or zero variance
+func WelchT(x, y []float64) (t, df, p float64) {
+	if len(x) < 2 || len(y) < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	var (
+		m1, v1 = stat.MeanVariance(x, nil)
+		m2, v2 = stat.MeanVariance(y, nil)
+		a      = v1 / float64(len(x))
+		b      = v2 / float64(len(y))
+	)
+	if a+b == 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	t = (m1 - m2) / math.Sqrt(a+b)
+	df = (a + b) * (a + b) / (a*a/float64(len(x)-1) + b*b/float64(len(y)-1))
+	p = 2 * distuv.StudentsT{Mu: 0, Sigma: 1, Nu: df}.Survival(math.Abs(t))
+	return
+}
+
+// WelchANOVA Welch one-way ANOVA of groups, groups with n < 2 or zero variance are skipped, NaN if less than 2 groups left
+func WelchANOVA(groups [][]float64) (f, df1, df2, p float64) {
+	var (
+		n, m, w []float64
+		sumW    float64
+	)
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		var mean, variance = stat.MeanVariance(g, nil)
+		if variance == 0 {
+			continue
+		}
+		n = append(n, float64(len(g)))
+		m = append(m, mean)
+		w = append(w, float64(len(g))/variance)
+		sumW += float64(len(g)) / variance
+	}
+	var k = float64(len(w))
+	if k < 2 {
+		return math.NaN(), math.NaN(), math.NaN(), math.NaN()
+	}
+	var mean, a, lambda float64
+	for i := range w {
+		mean += w[i] * m[i] / sumW
+	}
+	for i := range w {
+		a += w[i] * (m[i] - mean) * (m[i] - mean)
+		lambda += (1 - w[i]/sumW) * (1 - w[i]/sumW) / (n[i] - 1)
+	}
+	a /= k - 1
+	f = a / (1 + 2*(k-2)/(k*k-1)*lambda)
+	df1 = k - 1
+	df2 = (k*k - 1) / (3 * lambda)
+	p = distuv.F{D1: df1, D2: df2}.Survival(f)
+	return
+}
+
+// ChiSquare2x2 Pearson chi-square test of x1/n1 vs x2/n2, NaN if any expected count is 0
+func ChiSquare2x2(x1, n1, x2, n2 int) (chi2, p float64) {
+	var (
+		observed = [4]float64{float64(x1), float64(n1 - x1), float64(x2), float64(n2 - x2)}
+		total    = float64(n1 + n2)
+		col      = [2]float64{float64(x1 + x2), float64(n1 + n2 - x1 - x2)}
+		row      = [2]float64{float64(n1), float64(n2)}
+	)
+	for i := range observed {
+		var expected = row[i/2] * col[i%2] / total
+		if expected == 0 || math.IsNaN(expected) {
+			return math.NaN(), math.NaN()
+		}
+		chi2 += (observed[i] - expected) * (observed[i] - expected) / expected
+	}
+	p = distuv.ChiSquared{K: 1}.Survival(chi2)
+	return
+}
+
+// Grubbs two-sided Grubbs test of the value farthest from mean, index of the value, NaN if n < 3 or zero variance
+func Grubbs(x []float64) (i int, g, p float64) {
+	var n = float64(len(x))
+	if len(x) < 3 {
+		return -1, math.NaN(), math.NaN()
+	}
+	var mean, sd = stat.MeanStdDev(x, nil)
+	if sd == 0 {
+		return -1, math.NaN(), math.NaN()
+	}
+	for j, v := range x {
+		if d := math.Abs(v-mean) / sd; d > g {
+			i, g = j, d
+		}
+	}
+	// G 转换为 t 统计量，Bonferroni 近似 p 值
+	var t2 = n * (n - 2) * g * g / ((n-1)*(n-1) - n*g*g)
+	if t2 <= 0 || math.IsInf(t2, 0) {
+		return i, g, 0
+	}
+	p = math.Min(1, 2*n*distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 2}.Survival(math.Sqrt(t2)))
+	return
+}
+
+// BHAdjust Benjamini–Hochberg adjusted p values, NaN kept and not counted
+func BHAdjust(p []float64) []float64 {
+	var (
+		adj   = make([]float64, len(p))
+		index []int
+	)
+	for i, v := range p {
+		adj[i] = math.NaN()
+		if !math.IsNaN(v) {
+			index = append(index, i)
+		}
+	}
+	sort.SliceStable(index, func(a, b int) bool { return p[index[a]] < p[index[b]] })
+	var m, cum = float64(len(index)), 1.0
+	for r := len(index) - 1; r >= 0; r-- {
+		cum = math.Min(cum, p[index[r]]*m/float64(r+1))
+		adj[index[r]] = cum
+	}
+	return adj
+}
+
+// compareMetrics replicate values compared between groups
+var compareMetrics = []struct {
+	Title string
+	Value func(info *SeqInfo) float64
+}{
+	{"收率", func(info *SeqInfo) float64 { return info.YieldCoefficient }},
+	{"单步准确率", func(info *SeqInfo) float64 { return info.AverageYieldAccuracy }},
+}
+
+// CompareGroups compare 平行 groups of ParallelStatsMap:
+// Welch ANOVA and pairwise Welch t-test of replicate 收率/单步准确率,
+// pairwise chi-square of pooled reads of each error class, Grubbs outlier of replicates.
+// samples without 平行 and groups of single replicate are not compared, same as BuildPools
+func CompareGroups(SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest) (comparisons []*Comparison) {
+	var ids []string
+	for id, p := range ParallelStatsMap {
+		if id == "" || len(p.Samples) < 2 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var values = func(id string, value func(info *SeqInfo) float64) (x []float64) {
+		for _, name := range ParallelStatsMap[id].Samples {
+			x = append(x, value(SeqInfoMap[name]))
+		}
+		return
+	}
+	var pooled = func(id, key string) (count, total int) {
+		for _, name := range ParallelStatsMap[id].Samples {
+			count += SeqInfoMap[name].Stats[key]
+			total += SeqInfoMap[name].Stats["AnalyzedReadsNum"]
+		}
+		return
+	}
+
+	for _, metric := range compareMetrics {
+		var groups [][]float64
+		for _, id := range ids {
+			groups = append(groups, values(id, metric.Value))
+		}
+		if len(ids) > 1 {
+			var f, df1, df2, p = WelchANOVA(groups)
+			comparisons = append(comparisons, &Comparison{
+				Test: CompareWelchANOVA, Metric: L(metric.Title), Group1: "*",
+				Value1: math.NaN(), Value2: math.NaN(),
+				Statistic: f, DF: formatFloat(df1) + "," + formatFloat(df2), P: p,
+			})
+		}
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				var t, df, p = WelchT(groups[i], groups[j])
+				comparisons = append(comparisons, &Comparison{
+					Test: CompareWelchT, Metric: L(metric.Title), Group1: ids[i], Group2: ids[j],
+					Value1: stat.Mean(groups[i], nil), Value2: stat.Mean(groups[j], nil),
+					Statistic: t, DF: formatFloat(df), P: p,
+				})
+			}
+		}
+		for k, id := range ids {
+			var index, g, p = Grubbs(groups[k])
+			if index < 0 {
+				continue
+			}
+			comparisons = append(comparisons, &Comparison{
+				Test: CompareGrubbs, Metric: L(metric.Title), Group1: id, Group2: ParallelStatsMap[id].Samples[index],
+				Value1: groups[k][index], Value2: stat.Mean(groups[k], nil),
+				Statistic: g, DF: strconv.Itoa(len(groups[k]) - 2), P: p,
+			})
+		}
+	}
+
+	for _, class := range errorClasses {
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				var (
+					x1, n1  = pooled(ids[i], class.Key)
+					x2, n2  = pooled(ids[j], class.Key)
+					chi2, p = ChiSquare2x2(x1, n1, x2, n2)
+				)
+				comparisons = append(comparisons, &Comparison{
+					Test: CompareChiSquare, Metric: L(class.Title), Group1: ids[i], Group2: ids[j],
+					Value1: math2.DivisionInt(x1, n1), Value2: math2.DivisionInt(x2, n2),
+					Statistic: chi2, DF: "1", P: p,
+				})
+			}
+		}
+	}
+
+	// BH within each test
+	var tests = make(map[string][]*Comparison)
+	for _, c := range comparisons {
+		tests[c.Test] = append(tests[c.Test], c)
+	}
+	for _, list := range tests {
+		var p []float64
+		for _, c := range list {
+			p = append(p, c.P)
+		}
+		for i, adj := range BHAdjust(p) {
+			list[i].PAdj = adj
+		}
+	}
+	return
+}
+
+// compareTitle titles of compare.txt and Compare sheet
+func compareTitle() []string {
+	return []string{
+		L("检验"), L("指标"), L("组1"), L("组2"), L("值1"), L("值2"),
+		L("统计量"), L("自由度"), "P", L("校正P"), L("显著"),
+	}
+}
+
+// formatFloat %g of v, NA for NaN
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return "NA"
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// Row values of Comparison, NaN as NA
+func (c *Comparison) Row() []string {
+	var significant = ""
+	if c.Significant() {
+		significant = "*"
+	}
+	return []string{
+		c.Test, c.Metric, c.Group1, c.Group2, formatFloat(c.Value1), formatFloat(c.Value2),
+		formatFloat(c.Statistic), c.DF, formatFloat(c.P), formatFloat(c.PAdj), significant,
+	}
+}
+
+// WriteCompareTxt write comparisons to resultDir/compare.txt
+func WriteCompareTxt(resultDir string, comparisons []*Comparison) {
+	var file = osUtil.Create(filepath.Join(resultDir, "compare.txt"))
+	defer simpleUtil.DeferClose(file)
+	fmtUtil.FprintStringArray(file, compareTitle(), "\t")
+	for _, c := range comparisons {
+		fmtUtil.FprintStringArray(file, c.Row(), "\t")
+		if c.Test == CompareGrubbs && c.Significant() {
+			slog.Warn("outlier replicate", "group", c.Group1, "sample", c.Group2, "metric", c.Metric, "value", c.Value1, "padj", c.PAdj)
+		}
+	}
+}
+
+// AddCompare2Sheet add Compare sheet of comparisons to summary.xlsx
+func AddCompare2Sheet(excel *excelize.File, comparisons []*Comparison) {
+	if len(comparisons) == 0 {
+		return
+	}
+	var sheetName = "Compare"
+	simpleUtil.HandleError(excel.NewSheet(sheetName))
+	var title []any
+	for _, s := range compareTitle() {
+		title = append(title, s)
+	}
+	SetRow(excel, sheetName, 1, 1, title)
+	for i, c := range comparisons {
+		var row []any
+		for j, v := range c.Row() {
+			// 数值列
+			if f, err := strconv.ParseFloat(v, 64); err == nil && j >= 4 && j != 7 {
+				row = append(row, f)
+			} else {
+				row = append(row, v)
+			}
+		}
+		SetRow(excel, sheetName, 1, i+2, row)
+	}
+}
+
+// Outliers samples of group id marked as Grubbs outlier
+func Outliers(comparisons []*Comparison, id string) (samples []string) {
+	for _, c := range comparisons {
+		if c.Test == CompareGrubbs && c.Group1 == id && c.Significant() && !slices.Contains(samples, c.Group2) {
+			samples = append(samples, c.Group2)
+		}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package seqAnalysis
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestWelchT(t *testing.T) {
+	// R: t.test(c(1,2,3,4), c(2,4,6,8,10))
+	var tt, df, p = WelchT([]float64{1, 2, 3, 4}, []float64{2, 4, 6, 8, 10})
+	if math.Abs(tt+2.2514) > 1e-4 || math.Abs(df-5.5208) > 1e-4 || math.Abs(p-0.06913) > 1e-4 {
+		t.Errorf("WelchT = %v %v %v", tt, df, p)
+	}
+	if _, _, p = WelchT([]float64{1}, []float64{2, 3}); !math.IsNaN(p) {
+		t.Errorf("WelchT of single replicate = %v, want NaN", p)
+	}
+}
+
+func TestWelchANOVA(t *testing.T) {
+	// 两组时等价于 Welch t-test
+	var x, y = []float64{1, 2, 3, 4}, []float64{2, 4, 6, 8, 10}
+	var f, df1, df2, p = WelchANOVA([][]float64{x, y})
+	var tt, df, pt = WelchT(x, y)
+	if math.Abs(f-tt*tt) > 1e-9 || df1 != 1 || math.Abs(df2-df) > 1e-9 || math.Abs(p-pt) > 1e-9 {
+		t.Errorf("WelchANOVA = %v %v %v %v", f, df1, df2, p)
+	}
+}
+
+func TestChiSquare2x2(t *testing.T) {
+	// R: chisq.test(matrix(c(10, 90, 30, 70), 2), correct = FALSE)
+	var chi2, p = ChiSquare2x2(10, 100, 30, 100)
+	if math.Abs(chi2-12.5) > 1e-9 || math.Abs(p-0.0004069) > 1e-6 {
+		t.Errorf("ChiSquare2x2 = %v %v", chi2, p)
+	}
+}
+
+func TestGrubbs(t *testing.T) {
+	var i, g, p = Grubbs([]float64{10, 10.1, 9.9, 10.05, 9.95, 13})
+	if i != 5 || g < 2 || p > 0.05 {
+		t.Errorf("Grubbs = %v %v %v", i, g, p)
+	}
+	if i, _, _ = Grubbs([]float64{1, 2}); i != -1 {
+		t.Errorf("Grubbs of 2 values = %v, want -1", i)
+	}
+}
+
+func TestBHAdjust(t *testing.T) {
+	// R: p.adjust(c(0.01, 0.04, 0.03, 0.2), "BH")
+	var adj = BHAdjust([]float64{0.01, 0.04, math.NaN(), 0.03, 0.2})
+	for i, want := range []float64{0.04, 0.04 * 4 / 3, math.NaN(), 0.04 * 4 / 3, 0.2} {
+		if math.IsNaN(want) != math.IsNaN(adj[i]) || (!math.IsNaN(want) && math.Abs(adj[i]-want) > 1e-9) {
+			t.Errorf("BHAdjust[%d] = %v, want %v", i, adj[i], want)
+		}
+	}
+}
+
+func TestCompareGroupsSkip(t *testing.T) {
+	var SeqInfoMap = make(map[string]*SeqInfo)
+	var ParallelStatsMap = make(map[string]*ParallelTest)
+	for i, group := range []string{"", "", "", "a", "a", "b", "b", "c"} {
+		var name = group + strconv.Itoa(i)
+		SeqInfoMap[name] = &SeqInfo{
+			Name: name, YieldCoefficient: float64(i), AverageYieldAccuracy: 0.9 + float64(i)/100,
+			Stats: map[string]int{"AnalyzedReadsNum": 100},
+		}
+		var p = ParallelStatsMap[group]
+		if p == nil {
+			p = &ParallelTest{ID: group}
+			ParallelStatsMap[group] = p
+		}
+		p.Samples = append(p.Samples, name)
+	}
+	for _, c := range CompareGroups(SeqInfoMap, ParallelStatsMap) {
+		for _, g := range []string{c.Group1, c.Group2} {
+			if g == "" && c.Test != CompareWelchANOVA || g == "c" {
+				t.Errorf("comparison of skipped group: %+v", c)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package seqAnalysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// RunConfig run configuration file in YAML, JSON or TOML
+//
+// top level keys are flag names of SeqAnalysis, e.g. rc, lessMem, lineLimit, suffix-col, etc;
+// samples is optional inline sample table, columns same as input.xlsx
+type RunConfig struct {
+	Options map[string]any      `yaml:",inline"`
+	Samples []map[string]string `yaml:"samples,omitempty"`
+}
+
+// LoadRunConfig load run configuration from file, TOML if extension is .toml, else YAML or JSON
+func LoadRunConfig(path string) (*RunConfig, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		// TOML 转为 YAML 后按同样规则解析，样品表数值列转为字符串
+		var m map[string]any
+		if _, err = toml.Decode(string(data), &m); err != nil {
+			return nil, fmt.Errorf("parse TOML %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(m); err != nil {
+			return nil, err
+		}
+	}
+	var cfg = &RunConfig{}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// WriteRunConfig write effective run configuration to path as YAML
+func WriteRunConfig(path string, cfg *RunConfig) error {
+	var data, err = yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,40 @@
+package seqAnalysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRunConfig(t *testing.T) {
+	var dir = t.TempDir()
+	for name, text := range map[string]string{
+		"run.yaml": "rc: true\nlineLimit: 100\nsuffix-col: 平行\nsamples:\n  - 样品名称: K30\n    平行: 1\n",
+		"run.json": `{"rc": true, "lineLimit": 100, "suffix-col": "平行", "samples": [{"样品名称": "K30", "平行": "1"}]}`,
+		"run.toml": "rc = true\nlineLimit = 100\nsuffix-col = \"平行\"\n\n[[samples]]\n\"样品名称\" = \"K30\"\n\"平行\" = 1\n",
+	} {
+		var path = filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var cfg, err = LoadRunConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Options["rc"] != true || cfg.Options["lineLimit"] != 100 || cfg.Options["suffix-col"] != "平行" ||
+			len(cfg.Samples) != 1 || cfg.Samples[0]["样品名称"] != "K30" || cfg.Samples[0]["平行"] != "1" {
+			t.Errorf("%s: %+v", name, cfg)
+		}
+		if _, ok := cfg.Options["samples"]; ok {
+			t.Errorf("%s: samples in Options", name)
+		}
+	}
+
+	var path = filepath.Join(dir, "bad.toml")
+	if err := os.WriteFile(path, []byte("rc: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRunConfig(path); err == nil {
+		t.Errorf("invalid TOML should fail")
+	}
+}
@@ -0,0 +1,201 @@
+package seqAnalysis
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+// ContaminationPct flag contamination if reads of other design ≥ ContaminationPct % of analyzed reads
+var ContaminationPct = 1.0
+
+// SwapRatio flag swap if reads of other design ≥ SwapRatio × reads of own design and ≥ ContaminationPct %
+var SwapRatio = 10.0
+
+// 污染判定
+const (
+	ContaminationFlag = "contamination"
+	SwapFlag          = "swap"
+)
+
+// Design distinct 合成序列 of batch, samples of same 合成序列 share one design
+type Design struct {
+	// 合成序列，rev 样品还原为正向
+	Seq     string
+	Samples []string
+}
+
+// Name samples of design
+func (design *Design) Name() string {
+	return strings.Join(design.Samples, ",")
+}
+
+// ContaminationMatrix reads of each sample exactly matching 合成序列 of each design
+type ContaminationMatrix struct {
+	Designs []*Design
+	// id -> index of own design
+	Own map[string]int
+	// id -> reads of each design
+	Counts   map[string][]int
+	Analyzed map[string]int
+
+	mu sync.Mutex
+}
+
+// forwardSeq 合成序列 in input orientation
+func forwardSeq(info *SeqInfo) string {
+	if info.Reverse {
+		return string(Reverse(append([]byte{}, info.Seq...)))
+	}
+	return string(info.Seq)
+}
+
+// NewContaminationMatrix designs of samples in ids, set to each sample to Record after CountError4
+func NewContaminationMatrix(ids []string, SeqInfoMap map[string]*SeqInfo) *ContaminationMatrix {
+	var (
+		matrix = &ContaminationMatrix{
+			Own:      make(map[string]int),
+			Counts:   make(map[string][]int),
+			Analyzed: make(map[string]int),
+		}
+		bySeq = make(map[string]int)
+	)
+	for _, id := range ids {
+		var info = SeqInfoMap[id]
+		if info == nil {
+			continue
+		}
+		var seq = forwardSeq(info)
+		var i, ok = bySeq[seq]
+		if !ok {
+			i = len(matrix.Designs)
+			bySeq[seq] = i
+			matrix.Designs = append(matrix.Designs, &Design{Seq: seq})
+		}
+		matrix.Designs[i].Samples = append(matrix.Designs[i].Samples, id)
+		matrix.Own[id] = i
+		info.contamination = matrix
+	}
+	return matrix
+}
+
+// Record reads of HitSeqCount of info matching each design, nil matrix ignored
+func (matrix *ContaminationMatrix) Record(info *SeqInfo) {
+	if matrix == nil {
+		return
+	}
+	var counts = make([]int, len(matrix.Designs))
+	for i, design := range matrix.Designs {
+		var key = design.Seq
+		if info.Reverse {
+			key = string(Reverse([]byte(key)))
+		}
+		counts[i] = info.HitSeqCount[key]
+	}
+	matrix.mu.Lock()
+	matrix.Counts[info.Name] = counts
+	matrix.Analyzed[info.Name] = info.Stats["AnalyzedReadsNum"]
+	matrix.mu.Unlock()
+}
+
+// Pct reads of design i in sample id, % of analyzed reads
+func (matrix *ContaminationMatrix) Pct(id string, i int) float64 {
+	var analyzed = matrix.Analyzed[id]
+	if analyzed == 0 {
+		return 0
+	}
+	return float64(matrix.Counts[id][i]) * 100 / float64(analyzed)
+}
+
+// ContaminationCall most abundant other design of sample and flag
+type ContaminationCall struct {
+	ID     string
+	OwnPct float64
+	// 最高其他设计，-1 为无
+	Other    int
+	OtherPct float64
+	Flag     string
+	// 互换对象样品，双方均判定为 swap 且互为最高其他设计
+	SwapWith string
+}
+
+// Calls ContaminationCall of samples in ids
+func (matrix *ContaminationMatrix) Calls(ids []string) (calls []*ContaminationCall) {
+	var byID = make(map[string]*ContaminationCall)
+	for _, id := range ids {
+		if _, ok := matrix.Counts[id]; !ok {
+			continue
+		}
+		var call = &ContaminationCall{ID: id, OwnPct: matrix.Pct(id, matrix.Own[id]), Other: -1}
+		for i := range matrix.Designs {
+			if i == matrix.Own[id] {
+				continue
+			}
+			if pct := matrix.Pct(id, i); pct > 0 && pct > call.OtherPct {
+				call.Other = i
+				call.OtherPct = pct
+			}
+		}
+		// 其他设计未达 ContaminationPct 时不判定，避免自身 0 reads 时单条其他 reads 即判 swap
+		if call.Other >= 0 && call.OtherPct >= ContaminationPct {
+			if call.OtherPct >= SwapRatio*call.OwnPct {
+				call.Flag = SwapFlag
+			} else {
+				call.Flag = ContaminationFlag
+			}
+		}
+		byID[id] = call
+		calls = append(calls, call)
+	}
+	for _, call := range calls {
+		if call.Flag != SwapFlag {
+			continue
+		}
+		for _, other := range matrix.Designs[call.Other].Samples {
+			var o = byID[other]
+			if o != nil && o.Flag == SwapFlag && o.Other == matrix.Own[call.ID] {
+				call.SwapWith = other
+			}
+		}
+	}
+	return
+}
+
+// contaminationTitle titles of contamination.txt
+func (matrix *ContaminationMatrix) contaminationTitle() []string {
+	var title = []string{L("样品名称"), L("分析reads"), L("自身%"), L("最高其他设计"), L("最高其他%"), L("判定"), L("互换样品")}
+	for _, design := range matrix.Designs {
+		title = append(title, design.Name()+"%")
+	}
+	return title
+}
+
+// WriteContaminationTxt write contamination.txt of samples in ids, % of analyzed reads of sample (row) exactly matching each design (column)
+func WriteContaminationTxt(resultDir string, ids []string, matrix *ContaminationMatrix) {
+	var out = osUtil.Create(filepath.Join(resultDir, "contamination.txt"))
+	defer simpleUtil.DeferClose(out)
+
+	fmtUtil.FprintStringArray(out, matrix.contaminationTitle(), "\t")
+	for _, call := range matrix.Calls(ids) {
+		var other string
+		if call.Other >= 0 {
+			other = matrix.Designs[call.Other].Name()
+		}
+		var row = []string{
+			call.ID, strconv.Itoa(matrix.Analyzed[call.ID]), formatFloat(call.OwnPct), other, formatFloat(call.OtherPct), call.Flag, call.SwapWith,
+		}
+		for i := range matrix.Designs {
+			row = append(row, formatFloat(matrix.Pct(call.ID, i)))
+		}
+		fmtUtil.FprintStringArray(out, row, "\t")
+		if call.Flag != "" {
+			slog.Warn("Contamination", "sample", call.ID, "flag", call.Flag, "ownPct", call.OwnPct, "other", other, "otherPct", call.OtherPct, "swapWith", call.SwapWith)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package seqAnalysis
+
+import "testing"
+
+func TestContaminationMatrix(t *testing.T) {
+	var sample = func(name, seq string, reverse bool, hit map[string]int) *SeqInfo {
+		var analyzed int
+		for _, c := range hit {
+			analyzed += c
+		}
+		var info = &SeqInfo{Name: name, Seq: []byte(seq), Reverse: reverse, HitSeqCount: hit, Stats: map[string]int{"AnalyzedReadsNum": analyzed}}
+		if reverse {
+			info.Seq = Reverse(info.Seq)
+		}
+		return info
+	}
+	var SeqInfoMap = map[string]*SeqInfo{
+		// a b 互换，c e 含 a 设计，d 与 a 同一设计，e 为 rev
+		"a": sample("a", "AAAA", false, map[string]int{"CCCC": 90, "AAAA": 1, "X": 9}),
+		"b": sample("b", "CCCC", false, map[string]int{"AAAA": 80, "CCCC": 2, "AAAC": 18}),
+		"c": sample("c", "GGGG", false, map[string]int{"GGGG": 90, "AAAA": 5, "CCCC": 1, "GGG": 4}),
+		"d": sample("d", "AAAA", false, map[string]int{"AAAA": 100}),
+		"e": sample("e", "ACGT", true, map[string]int{"TGCA": 50, "AAAA": 50}),
+		// 无自身设计 reads，其他设计不足 ContaminationPct
+		"f": sample("f", "TTTT", false, map[string]int{"AAAA": 1, "X": 999}),
+	}
+	var ids = []string{"a", "b", "c", "d", "e", "f"}
+	var matrix = NewContaminationMatrix(ids, SeqInfoMap)
+	if len(matrix.Designs) != 5 || matrix.Designs[0].Name() != "a,d" || matrix.Designs[3].Seq != "ACGT" {
+		t.Fatalf("Designs = %+v", matrix.Designs)
+	}
+	for _, id := range ids {
+		SeqInfoMap[id].contamination.Record(SeqInfoMap[id])
+	}
+	if matrix.Pct("e", 3) != 50 || matrix.Pct("c", 0) != 5 {
+		t.Errorf("Pct = %v %v", matrix.Pct("e", 3), matrix.Pct("c", 0))
+	}
+
+	var calls = matrix.Calls(ids)
+	for i, want := range []struct {
+		flag, swapWith string
+		other          int
+	}{
+		{SwapFlag, "b", 1},
+		{SwapFlag, "a", 0},
+		{ContaminationFlag, "", 0},
+		{"", "", -1},
+		{ContaminationFlag, "", 0},
+		{"", "", 0},
+	} {
+		if calls[i].Flag != want.flag || calls[i].SwapWith != want.swapWith || calls[i].Other != want.other {
+			t.Errorf("call %s = %+v, want %+v", calls[i].ID, calls[i], want)
+		}
+	}
+}
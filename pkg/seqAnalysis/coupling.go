@@ -0,0 +1,506 @@
+package seqAnalysis
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// coupling model terms
+const (
+	CouplingBase     = "base"
+	CouplingNeighbor = "neighbor"
+	CouplingPair     = "pair"
+)
+
+var couplingBases = []byte("ACGT")
+
+// CouplingObs one step of one sample: N reads enter step adding Base after Prev, X of them couple Base
+type CouplingObs struct {
+	Base byte
+	// 前一碱基，0 if unknown
+	Prev byte
+	N, X int
+}
+
+// CouplingEstimate one row of coupling.txt,
+// Estimate is efficiency of base and pair terms, odds ratio of neighbor terms
+type CouplingEstimate struct {
+	Term     string  `json:"term"`
+	Base     string  `json:"base,omitempty"`
+	Prev     string  `json:"prev,omitempty"`
+	Estimate float64 `json:"estimate"`
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+	// base、pair 与其余同类项均值比较，neighbor 与无效应比较，logit 尺度 Wald 检验
+	P float64 `json:"p"`
+	// 显著低于其余同类项
+	Flag  bool `json:"flag"`
+	Steps int  `json:"steps"`
+	Reads int  `json:"reads"`
+	// 估计收敛，完全分离（如全部失败或全部成功）或未收敛时为 false，Estimate Low High P 为 NaN，since 1.6
+	Converged bool `json:"converged"`
+}
+
+// CouplingModel binomial GLM of coupling efficiency over steps of all samples:
+// logit(efficiency) = α[Base] + γ[Prev], γ effect coded (sum to 0) so α is efficiency of base at average neighbor,
+// intervals by Fisher information scaled by Pearson dispersion if > 1
+type CouplingModel struct {
+	Level        float64             `json:"level"`
+	Observations int                 `json:"observations"`
+	LogLik       float64             `json:"logLik"`
+	Dispersion   float64             `json:"dispersion"`
+	Converged    bool                `json:"converged"`
+	Bases        []*CouplingEstimate `json:"bases"`
+	Neighbors    []*CouplingEstimate `json:"neighbors"`
+	Pairs        []*CouplingEstimate `json:"pairs"`
+
+	bases, prevs []byte
+	coef         []float64
+	cov          *mat.SymDense
+}
+
+// coupling fit limits
+const (
+	// CouplingMaxIter max Newton-Raphson iterations
+	CouplingMaxIter = 100
+	// CouplingMaxLogit |logit| beyond which a term is taken as separated, efficiency 1-3e-7
+	CouplingMaxLogit = 15.0
+)
+
+// ErrCouplingData no step to fit or singular information
+var ErrCouplingData = errors.New("not enough steps to fit coupling model")
+
+// CouplingObservations steps of seqInfo as CouplingObs, after WriteStatsSheet;
+// previous base of step 1 is last base of 靶标, A of polyA if Reverse
+func (seqInfo *SeqInfo) CouplingObservations() (obs []*CouplingObs) {
+	var prev byte
+	if seqInfo.Reverse {
+		prev = 'A'
+	} else if n := len(seqInfo.IndexSeq); n > 0 {
+		prev = seqInfo.IndexSeq[n-1]
+	}
+	for _, step := range seqInfo.Steps {
+		var base = step.Base[0]
+		if step.ReadsCount > 0 && strings.IndexByte(string(couplingBases), base) >= 0 {
+			obs = append(obs, &CouplingObs{Base: base, Prev: prev, N: step.ReadsCount, X: step.Right()})
+		}
+		prev = base
+	}
+	return
+}
+
+// logistic 1/(1+exp(-x))
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// softplus log(1+exp(x)) without overflow
+func softplus(x float64) float64 {
+	if x > 0 {
+		return x + math.Log1p(math.Exp(-x))
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+// design row of (base, prev): indicator of base, effect coded prev, zero for unknown prev
+func (model *CouplingModel) design(base, prev byte) []float64 {
+	var (
+		x = make([]float64, len(model.bases)+max(0, len(model.prevs)-1))
+		b = strings.IndexByte(string(model.bases), base)
+		p = strings.IndexByte(string(model.prevs), prev)
+	)
+	x[b] = 1
+	if len(model.prevs) < 2 || p < 0 {
+		return x
+	}
+	if p == len(model.prevs)-1 {
+		for i := len(model.bases); i < len(x); i++ {
+			x[i] = -1
+		}
+	} else {
+		x[len(model.bases)+p] = 1
+	}
+	return x
+}
+
+// linear predictor
+func (model *CouplingModel) eta(x []float64) (eta float64) {
+	for i, v := range x {
+		eta += v * model.coef[i]
+	}
+	return
+}
+
+// wald estimate, interval and two-sided p of contrast c on logit scale
+func (model *CouplingModel) wald(c []float64) (eta, se, p float64) {
+	var v = mat.NewVecDense(len(c), c)
+	eta = model.eta(c)
+	se = math.Sqrt(math.Max(1, model.Dispersion) * mat.Inner(v, model.cov, v))
+	p = 2 * distuv.UnitNormal.Survival(math.Abs(eta)/se)
+	return
+}
+
+// FitCoupling fit CouplingModel by Newton-Raphson with step halving
+func FitCoupling(obs []*CouplingObs, level float64) (*CouplingModel, error) {
+	var model = &CouplingModel{Level: level, Observations: len(obs)}
+	for _, b := range couplingBases {
+		var hasBase, hasPrev bool
+		for _, o := range obs {
+			hasBase = hasBase || o.Base == b
+			hasPrev = hasPrev || o.Prev == b
+		}
+		if hasBase {
+			model.bases = append(model.bases, b)
+		}
+		if hasPrev {
+			model.prevs = append(model.prevs, b)
+		}
+	}
+	if len(model.bases) == 0 {
+		return nil, ErrCouplingData
+	}
+	var (
+		k      = len(model.bases) + max(0, len(model.prevs)-1)
+		rows   = make([][]float64, len(obs))
+		x, n   = make([]float64, len(model.bases)), make([]float64, len(model.bases))
+		logLik = func(coef []float64) (ll float64) {
+			for i, o := range obs {
+				var eta float64
+				for j, v := range rows[i] {
+					eta += v * coef[j]
+				}
+				ll -= float64(o.X)*softplus(-eta) + float64(o.N-o.X)*softplus(eta)
+			}
+			return
+		}
+	)
+	model.coef = make([]float64, k)
+	for i, o := range obs {
+		rows[i] = model.design(o.Base, o.Prev)
+		var b = strings.IndexByte(string(model.bases), o.Base)
+		x[b] += float64(o.X)
+		n[b] += float64(o.N)
+	}
+	// 初值为各碱基合并效率
+	for b := range model.bases {
+		var p = math.Min(math.Max((x[b]+0.5)/(n[b]+1), 1e-6), 1-1e-6)
+		model.coef[b] = math.Log(p / (1 - p))
+	}
+
+	var (
+		info = mat.NewSymDense(k, nil)
+		ll   = logLik(model.coef)
+	)
+	for iter := 0; iter < CouplingMaxIter; iter++ {
+		var grad = mat.NewVecDense(k, nil)
+		info = mat.NewSymDense(k, nil)
+		for i, o := range obs {
+			var (
+				p = logistic(model.eta(rows[i]))
+				w = float64(o.N) * p * (1 - p)
+				r = float64(o.X) - float64(o.N)*p
+			)
+			for a, va := range rows[i] {
+				if va == 0 {
+					continue
+				}
+				grad.SetVec(a, grad.AtVec(a)+r*va)
+				for b := a; b < k; b++ {
+					info.SetSym(a, b, info.At(a, b)+w*va*rows[i][b])
+				}
+			}
+		}
+		var (
+			chol  mat.Cholesky
+			delta mat.VecDense
+		)
+		if !chol.Factorize(info) {
+			return nil, fmt.Errorf("%w: singular information", ErrCouplingData)
+		}
+		simpleUtil.CheckErr(chol.SolveVecTo(&delta, grad))
+
+		// step halving until log likelihood not decrease
+		var (
+			next  = make([]float64, k)
+			scale = 1.0
+			nll   float64
+		)
+		for range 30 {
+			for j := range next {
+				next[j] = model.coef[j] + scale*delta.AtVec(j)
+			}
+			nll = logLik(next)
+			if nll >= ll-1e-9 {
+				break
+			}
+			scale /= 2
+		}
+		// 完全分离时系数发散，截断于 ±2×CouplingMaxLogit 使其余系数可收敛，相关项在 estimates 标记未收敛
+		var change float64
+		for j := range next {
+			next[j] = min(max(next[j], -2*CouplingMaxLogit), 2*CouplingMaxLogit)
+			change = math.Max(change, math.Abs(next[j]-model.coef[j]))
+		}
+		model.coef, ll = next, logLik(next)
+		if change < 1e-8 {
+			model.Converged = true
+			break
+		}
+	}
+	model.LogLik = ll
+
+	var chol mat.Cholesky
+	if !chol.Factorize(info) {
+		return nil, fmt.Errorf("%w: singular information", ErrCouplingData)
+	}
+	model.cov = mat.NewSymDense(k, nil)
+	simpleUtil.CheckErr(chol.InverseTo(model.cov))
+
+	// Pearson dispersion
+	var pearson float64
+	for i, o := range obs {
+		var p = logistic(model.eta(rows[i]))
+		if v := float64(o.N) * p * (1 - p); v > 0 {
+			pearson += math.Pow(float64(o.X)-float64(o.N)*p, 2) / v
+		}
+	}
+	model.Dispersion = 1
+	if len(obs) > k {
+		model.Dispersion = pearson / float64(len(obs)-k)
+	}
+
+	model.estimates(obs)
+	return model, nil
+}
+
+// estimates fill Bases, Neighbors and Pairs
+func (model *CouplingModel) estimates(obs []*CouplingObs) {
+	var (
+		z    = distuv.UnitNormal.Quantile(1 - (1-model.Level)/2)
+		k    = len(model.coef)
+		unit = func(i int) []float64 {
+			var c = make([]float64, k)
+			c[i] = 1
+			return c
+		}
+		// contrast a - mean(others)
+		vsOthers = func(a []float64, others [][]float64) []float64 {
+			var c = append([]float64{}, a...)
+			for _, o := range others {
+				for j := range c {
+					c[j] -= o[j] / float64(len(others))
+				}
+			}
+			return c
+		}
+		// 完全分离的项不作为其他项比较对象
+		separated = func(c []float64) bool {
+			return math.Abs(model.eta(c)) > CouplingMaxLogit
+		}
+		count = func(match func(o *CouplingObs) bool) (steps, reads int) {
+			for _, o := range obs {
+				if match(o) {
+					steps++
+					reads += o.N
+				}
+			}
+			return
+		}
+		// eta se 为 logit 尺度，非有限或超出 CouplingMaxLogit 时未收敛，数值置 NaN
+		newEstimate = func(term string, eta, se, p float64, higher bool, transform func(float64) float64) *CouplingEstimate {
+			if !model.Converged || math.IsNaN(eta+se+p) || math.IsInf(eta+se, 0) || math.Abs(eta) > CouplingMaxLogit {
+				var nan = math.NaN()
+				return &CouplingEstimate{Term: term, Estimate: nan, Low: nan, High: nan, P: nan}
+			}
+			return &CouplingEstimate{
+				Term: term, Estimate: transform(eta), Low: transform(eta - z*se), High: transform(eta + z*se), P: p,
+				Flag: p < CompareAlpha && !higher, Converged: true,
+			}
+		}
+	)
+
+	for i, b := range model.bases {
+		var (
+			c      = unit(i)
+			others [][]float64
+		)
+		for j := range model.bases {
+			if j != i && !separated(unit(j)) {
+				others = append(others, unit(j))
+			}
+		}
+		var (
+			eta, se, _ = model.wald(c)
+			p          = 1.0
+			higher     = true
+		)
+		if len(others) > 0 {
+			var diff float64
+			diff, _, p = model.wald(vsOthers(c, others))
+			higher = diff >= 0
+		}
+		var e = newEstimate(CouplingBase, eta, se, p, higher, logistic)
+		e.Base = string(b)
+		e.Steps, e.Reads = count(func(o *CouplingObs) bool { return o.Base == b })
+		model.Bases = append(model.Bases, e)
+	}
+
+	if len(model.prevs) > 1 {
+		for _, prev := range model.prevs {
+			// γ[prev] = design(any base, prev) - design(any base, unknown)
+			var c = model.design(model.bases[0], prev)
+			c[0] = 0
+			var (
+				eta, se, p = model.wald(c)
+				e          = newEstimate(CouplingNeighbor, eta, se, p, eta >= 0, math.Exp)
+			)
+			e.Prev = string(prev)
+			e.Steps, e.Reads = count(func(o *CouplingObs) bool { return o.Prev == prev })
+			model.Neighbors = append(model.Neighbors, e)
+		}
+	}
+
+	type pair struct{ prev, base byte }
+	var pairs []pair
+	for _, prev := range model.prevs {
+		for _, b := range model.bases {
+			if steps, _ := count(func(o *CouplingObs) bool { return o.Prev == prev && o.Base == b }); steps > 0 {
+				pairs = append(pairs, pair{prev, b})
+			}
+		}
+	}
+	for i, pr := range pairs {
+		var (
+			c      = model.design(pr.base, pr.prev)
+			others [][]float64
+		)
+		for j, o := range pairs {
+			if j != i && !separated(model.design(o.base, o.prev)) {
+				others = append(others, model.design(o.base, o.prev))
+			}
+		}
+		var (
+			eta, se, _ = model.wald(c)
+			p          = 1.0
+			higher     = true
+		)
+		if len(others) > 0 {
+			var diff float64
+			diff, _, p = model.wald(vsOthers(c, others))
+			higher = diff >= 0
+		}
+		var e = newEstimate(CouplingPair, eta, se, p, higher, logistic)
+		e.Base, e.Prev = string(pr.base), string(pr.prev)
+		e.Steps, e.Reads = count(func(o *CouplingObs) bool { return o.Prev == pr.prev && o.Base == pr.base })
+		model.Pairs = append(model.Pairs, e)
+	}
+}
+
+// Efficiency fitted efficiency of adding base after prev, prev 0 for average neighbor, NaN for base not in model
+func (model *CouplingModel) Efficiency(base, prev byte) float64 {
+	if strings.IndexByte(string(model.bases), base) < 0 {
+		return math.NaN()
+	}
+	return logistic(model.eta(model.design(base, prev)))
+}
+
+// Estimates all rows of Bases, Neighbors and Pairs
+func (model *CouplingModel) Estimates() []*CouplingEstimate {
+	var list = append([]*CouplingEstimate{}, model.Bases...)
+	list = append(list, model.Neighbors...)
+	return append(list, model.Pairs...)
+}
+
+// FitBatchCoupling CouplingModel of steps of samples in list, nil if failed
+func FitBatchCoupling(list []string, SeqInfoMap map[string]*SeqInfo) *CouplingModel {
+	var obs []*CouplingObs
+	for _, id := range list {
+		obs = append(obs, SeqInfoMap[id].CouplingObservations()...)
+	}
+	var model, err = FitCoupling(obs, CILevel)
+	if err != nil {
+		slog.Warn("FitCoupling", "err", err)
+		return nil
+	}
+	if !model.Converged {
+		slog.Warn("FitCoupling not converged", "logLik", model.LogLik)
+	}
+	for _, e := range model.Bases {
+		if e.Flag {
+			slog.Warn("low coupling efficiency", "base", e.Base, "efficiency", e.Estimate, "p", e.P)
+		}
+	}
+	return model
+}
+
+// couplingTitle titles of coupling.txt and Coupling sheet
+func couplingTitle() []string {
+	return []string{
+		L("项"), L("碱基"), L("前一碱基"), L("估计值"), L("CI下限"), L("CI上限"), "P", L("偏低"), L("步数"), L("reads数"),
+	}
+}
+
+// Row values of CouplingEstimate, NaN of not converged as NA
+func (e *CouplingEstimate) Row() []any {
+	var flag = ""
+	if e.Flag {
+		flag = "*"
+	}
+	var num = func(v float64) any {
+		if math.IsNaN(v) {
+			return "NA"
+		}
+		return v
+	}
+	return []any{e.Term, e.Base, e.Prev, num(e.Estimate), num(e.Low), num(e.High), num(e.P), flag, e.Steps, e.Reads}
+}
+
+// WriteCouplingTxt write coupling.txt to resultDir, skip if model is nil
+func WriteCouplingTxt(resultDir string, model *CouplingModel) {
+	if model == nil {
+		return
+	}
+	var file = osUtil.Create(filepath.Join(resultDir, "coupling.txt"))
+	defer simpleUtil.DeferClose(file)
+	fmtUtil.FprintStringArray(file, couplingTitle(), "\t")
+	for _, e := range model.Estimates() {
+		var row []string
+		for _, v := range e.Row() {
+			switch v := v.(type) {
+			case float64:
+				row = append(row, formatFloat(v))
+			default:
+				row = append(row, fmt.Sprint(v))
+			}
+		}
+		fmtUtil.FprintStringArray(file, row, "\t")
+	}
+}
+
+// AddCoupling2Sheet add Coupling sheet to summary.xlsx, skip if model is nil
+func AddCoupling2Sheet(excel *excelize.File, model *CouplingModel) {
+	if model == nil {
+		return
+	}
+	var sheetName = "Coupling"
+	simpleUtil.HandleError(excel.NewSheet(sheetName))
+	var title []any
+	for _, s := range couplingTitle() {
+		title = append(title, s)
+	}
+	SetRow(excel, sheetName, 1, 1, title)
+	for i, e := range model.Estimates() {
+		SetRow(excel, sheetName, 1, i+2, e.Row())
+	}
+}
@@ -0,0 +1,115 @@
+package seqAnalysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitCoupling(t *testing.T) {
+	// 无邻位效应，C 偶联效率偏低
+	var (
+		efficiency = map[byte]float64{'A': 0.98, 'C': 0.95, 'G': 0.98, 'T': 0.98}
+		obs        []*CouplingObs
+	)
+	for _, prev := range couplingBases {
+		for _, base := range couplingBases {
+			for _, n := range []int{10000, 20000} {
+				obs = append(obs, &CouplingObs{Base: base, Prev: prev, N: n, X: int(math.Round(float64(n) * efficiency[base]))})
+			}
+		}
+	}
+	var model, err = FitCoupling(obs, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !model.Converged || len(model.Bases) != 4 || len(model.Neighbors) != 4 || len(model.Pairs) != 16 {
+		t.Fatalf("FitCoupling = %+v", model)
+	}
+	for _, e := range model.Bases {
+		var want = efficiency[e.Base[0]]
+		if math.Abs(e.Estimate-want) > 1e-3 || e.Low > e.Estimate || e.High < e.Estimate {
+			t.Errorf("base %s = %v [%v, %v], want %v", e.Base, e.Estimate, e.Low, e.High, want)
+		}
+		if e.Flag != (e.Base == "C") {
+			t.Errorf("base %s flag = %v", e.Base, e.Flag)
+		}
+	}
+	for _, e := range model.Neighbors {
+		if math.Abs(e.Estimate-1) > 1e-2 || e.Flag {
+			t.Errorf("neighbor %s = %v, want 1", e.Prev, e.Estimate)
+		}
+	}
+	if e := model.Efficiency('G', 'A'); math.Abs(e-0.98) > 1e-3 {
+		t.Errorf("Efficiency(G, A) = %v", e)
+	}
+	if e := model.Efficiency('N', 'A'); !math.IsNaN(e) {
+		t.Errorf("Efficiency(N, A) = %v, want NaN", e)
+	}
+	if _, err = FitCoupling(nil, 0.95); err == nil {
+		t.Errorf("FitCoupling without steps should fail")
+	}
+}
+
+func TestCouplingObservations(t *testing.T) {
+	var info = &SeqInfo{
+		IndexSeq: "TTG",
+		Steps: []*StepStat{
+			{Base: "A", ReadsCount: 100, A: 90},
+			{Base: "N", ReadsCount: 90, C: 80},
+			{Base: "C", ReadsCount: 80, C: 70},
+			{Base: "G", ReadsCount: 0},
+		},
+	}
+	var obs = info.CouplingObservations()
+	if len(obs) != 2 {
+		t.Fatalf("CouplingObservations got %d observations, want 2", len(obs))
+	}
+	if *obs[0] != (CouplingObs{'A', 'G', 100, 90}) || *obs[1] != (CouplingObs{'C', 'N', 80, 70}) {
+		t.Errorf("CouplingObservations = %+v %+v", *obs[0], *obs[1])
+	}
+}
+
+func TestFitCouplingSeparation(t *testing.T) {
+	// G 每步全部失败，完全分离
+	var obs []*CouplingObs
+	for _, prev := range couplingBases {
+		for _, base := range couplingBases {
+			var x = 9800
+			if base == 'G' {
+				x = 0
+			}
+			obs = append(obs, &CouplingObs{Base: base, Prev: prev, N: 10000, X: x}, &CouplingObs{Base: base, Prev: prev, N: 20000, X: 2 * x})
+		}
+	}
+	var model, err = FitCoupling(obs, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var separated int
+	for _, e := range model.Estimates() {
+		var values = []float64{e.Estimate, e.Low, e.High, e.P}
+		if !e.Converged {
+			separated++
+			for _, v := range values {
+				if !math.IsNaN(v) {
+					t.Errorf("not converged %+v should be NaN", e)
+				}
+			}
+			continue
+		}
+		for _, v := range values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Errorf("converged %+v should be finite", e)
+			}
+		}
+	}
+	if !model.Converged || model.Bases[2].Base != "G" || model.Bases[2].Converged || !model.Bases[0].Converged || separated != 5 {
+		t.Errorf("separated base G not flagged: %+v, %d not converged", model.Bases[2], separated)
+	}
+	if rate := model.StepErrorRate("C", 'G'); math.IsNaN(rate) || rate <= 0 || rate >= 1 {
+		t.Errorf("StepErrorRate of separated pair = %v", rate)
+	}
+	if err = WriteBatchJSON(t.TempDir(), "input.xlsx", nil, nil, nil, model); err != nil {
+		t.Errorf("WriteBatchJSON with separated coupling = %v", err)
+	}
+}
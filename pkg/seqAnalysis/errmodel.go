@@ -0,0 +1,403 @@
+package seqAnalysis
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// ContextModelVersion version of context model file
+const ContextModelVersion = "1.0"
+
+// MaxContextLength upstream nt recorded in one.step.error.rate.txt
+const MaxContextLength = 4
+
+// ErrContextSource source already added to ContextModel
+var ErrContextSource = errors.New("source already in context model")
+
+// ErrRateRecord one line of [id].one.step.error.rate.txt
+type ErrRateRecord struct {
+	ID string
+	// 合成前 4nt
+	Context string
+	Base    byte
+	Pos     int
+	// 单步错误率，%
+	ErrRate float64
+	// 进入该步 reads 及正确偶联 reads，旧版文件无此两列时为 0
+	Reads int
+	Right int
+	// hasReads 有 Reads Right 两列
+	hasReads bool
+}
+
+// Valid record can be accumulated: ErrRate finite, and Reads > 0 if file has reads columns
+func (record *ErrRateRecord) Valid() bool {
+	if math.IsNaN(record.ErrRate) || math.IsInf(record.ErrRate, 0) {
+		return false
+	}
+	return !record.hasReads || record.Reads > 0
+}
+
+// ParseErrRateRecord parse one line: id context base pos errRate [reads right]
+func ParseErrRateRecord(line string) (*ErrRateRecord, error) {
+	var cols = strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+	if len(cols) < 5 || len(cols[2]) != 1 {
+		return nil, fmt.Errorf("invalid one.step.error.rate line: %q", line)
+	}
+	var (
+		record = &ErrRateRecord{ID: cols[0], Context: strings.ToUpper(cols[1]), Base: strings.ToUpper(cols[2])[0]}
+		err    error
+	)
+	if record.Pos, err = strconv.Atoi(cols[3]); err != nil {
+		return nil, err
+	}
+	if record.ErrRate, err = strconv.ParseFloat(cols[4], 64); err != nil {
+		return nil, err
+	}
+	if len(cols) >= 7 {
+		if record.Reads, err = strconv.Atoi(cols[5]); err != nil {
+			return nil, err
+		}
+		if record.Right, err = strconv.Atoi(cols[6]); err != nil {
+			return nil, err
+		}
+		record.hasReads = true
+	}
+	return record, nil
+}
+
+// LoadErrRateRecords records of one.step.error.rate.txt, skip empty lines
+func LoadErrRateRecords(r io.Reader) (records []*ErrRateRecord, err error) {
+	var scanner = bufio.NewScanner(r)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record *ErrRateRecord
+		record, err = ParseErrRateRecord(scanner.Text())
+		if err != nil {
+			return
+		}
+		records = append(records, record)
+	}
+	err = scanner.Err()
+	return
+}
+
+// LoadErrRateFile records of one.step.error.rate.txt file
+func LoadErrRateFile(path string) ([]*ErrRateRecord, error) {
+	var file, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer simpleUtil.DeferClose(file)
+	return LoadErrRateRecords(file)
+}
+
+// ContextStat accumulated steps of one context
+type ContextStat struct {
+	// 合成前 ContextLength nt
+	Context string `json:"context"`
+	Base    string `json:"base"`
+	Steps   int    `json:"steps"`
+	Reads   int    `json:"reads"`
+	Errors  int    `json:"errors"`
+	// 单步错误率（比例）之和及平方和
+	RateSum   float64 `json:"rateSum"`
+	RateSumSq float64 `json:"rateSumSq"`
+}
+
+// ContextModel 序列上下文错误模型，各上下文单步错误率累计，可跨批次相加
+type ContextModel struct {
+	Version       string                  `json:"version"`
+	ContextLength int                     `json:"contextLength"`
+	Sources       []string                `json:"sources"`
+	Contexts      map[string]*ContextStat `json:"contexts"`
+}
+
+// NewContextModel empty ContextModel of k upstream nt, 0 <= k <= MaxContextLength
+func NewContextModel(k int) (*ContextModel, error) {
+	if k < 0 || k > MaxContextLength {
+		return nil, fmt.Errorf("invalid context length %d, need 0-%d", k, MaxContextLength)
+	}
+	return &ContextModel{
+		Version:       ContextModelVersion,
+		ContextLength: k,
+		Sources:       []string{},
+		Contexts:      make(map[string]*ContextStat),
+	}, nil
+}
+
+// Key key of Contexts: last ContextLength nt of context + base, false if context is shorter
+func (model *ContextModel) Key(context string, base byte) (string, bool) {
+	if len(context) < model.ContextLength {
+		return "", false
+	}
+	return context[len(context)-model.ContextLength:] + string(base), true
+}
+
+// AddRecords accumulate records of source, IQR filter outlier steps of each context of records if iqr,
+// invalid records (steps of 0 reads, NaN error rate) skipped
+func (model *ContextModel) AddRecords(source string, records []*ErrRateRecord, iqr bool) error {
+	if slices.Contains(model.Sources, source) {
+		return fmt.Errorf("%w: %s", ErrContextSource, source)
+	}
+	var groups = make(map[string][]*ErrRateRecord)
+	for _, record := range records {
+		if !record.Valid() {
+			continue
+		}
+		if key, ok := model.Key(record.Context, record.Base); ok {
+			groups[key] = append(groups[key], record)
+		}
+	}
+	for key, group := range groups {
+		if iqr {
+			group = filterRecordOutliers(group)
+		}
+		var stat, ok = model.Contexts[key]
+		if !ok {
+			stat = &ContextStat{Context: key[:model.ContextLength], Base: key[model.ContextLength:]}
+			model.Contexts[key] = stat
+		}
+		for _, record := range group {
+			var rate = record.ErrRate / 100
+			stat.Steps++
+			stat.Reads += record.Reads
+			stat.Errors += record.Reads - record.Right
+			stat.RateSum += rate
+			stat.RateSumSq += rate * rate
+		}
+	}
+	model.Sources = append(model.Sources, source)
+	return nil
+}
+
+// filterRecordOutliers records with ErrRate in [Q1-1.5IQR, Q3+1.5IQR], same quartiles as cmd/loadErrRate, keep all if < 4
+func filterRecordOutliers(records []*ErrRateRecord) []*ErrRateRecord {
+	if len(records) < 4 {
+		return records
+	}
+	var rates []float64
+	for _, record := range records {
+		rates = append(rates, record.ErrRate)
+	}
+	sort.Float64s(rates)
+	var (
+		q1, q3 = rates[len(rates)/4], rates[3*len(rates)/4]
+		low    = q1 - 1.5*(q3-q1)
+		high   = q3 + 1.5*(q3-q1)
+		kept   []*ErrRateRecord
+	)
+	for _, record := range records {
+		if record.ErrRate >= low && record.ErrRate <= high {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// Merge add other of the same ContextLength, sources of other must be new
+func (model *ContextModel) Merge(other *ContextModel) error {
+	if other.ContextLength != model.ContextLength {
+		return fmt.Errorf("context length %d differ from %d", other.ContextLength, model.ContextLength)
+	}
+	for _, source := range other.Sources {
+		if slices.Contains(model.Sources, source) {
+			return fmt.Errorf("%w: %s", ErrContextSource, source)
+		}
+	}
+	for key, o := range other.Contexts {
+		var stat, ok = model.Contexts[key]
+		if !ok {
+			stat = &ContextStat{Context: o.Context, Base: o.Base}
+			model.Contexts[key] = stat
+		}
+		stat.Steps += o.Steps
+		stat.Reads += o.Reads
+		stat.Errors += o.Errors
+		stat.RateSum += o.RateSum
+		stat.RateSumSq += o.RateSumSq
+	}
+	model.Sources = append(model.Sources, other.Sources...)
+	return nil
+}
+
+// LoadContextModel load ContextModel from json file
+func LoadContextModel(path string) (*ContextModel, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var model = &ContextModel{}
+	if err = json.Unmarshal(data, model); err != nil {
+		return nil, fmt.Errorf("invalid context model %s: %w", path, err)
+	}
+	if model.ContextLength < 0 || model.ContextLength > MaxContextLength {
+		return nil, fmt.Errorf("invalid context length %d of %s", model.ContextLength, path)
+	}
+	if model.Contexts == nil {
+		model.Contexts = make(map[string]*ContextStat)
+	}
+	return model, nil
+}
+
+// Save write ContextModel to json file
+func (model *ContextModel) Save(path string) error {
+	return writeJSON(path, model)
+}
+
+// GlobalRate mean of step error rates of all contexts
+func (model *ContextModel) GlobalRate() float64 {
+	var sum, steps float64
+	for _, stat := range model.Contexts {
+		sum += stat.RateSum
+		steps += float64(stat.Steps)
+	}
+	if steps == 0 {
+		return math.NaN()
+	}
+	return sum / steps
+}
+
+// Rate mean step error rate of context + base, false if not in model
+func (model *ContextModel) Rate(context string, base byte) (float64, bool) {
+	var key, ok = model.Key(context, base)
+	if !ok {
+		return math.NaN(), false
+	}
+	var stat = model.Contexts[key]
+	if stat == nil || stat.Steps == 0 {
+		return math.NaN(), false
+	}
+	return stat.RateSum / float64(stat.Steps), true
+}
+
+// ContextReport one row of context.txt
+type ContextReport struct {
+	*ContextStat
+	// reads 合并错误率，旧版文件无 reads 时为 NaN
+	ErrorRate float64
+	// 单步错误率均值及标准差
+	Mean, SD float64
+	// Mean / 全局均值
+	Ratio float64
+	// 单样本 t 检验 vs 全局均值，步数 < 2 或 SD 为 0 时为 NaN
+	T, P, PAdj float64
+	// 显著高于全局均值
+	Hot bool
+}
+
+// Report ContextReport of all contexts sorted by Mean descending, BH adjusted, hot if PAdj < alpha and Mean > global
+func (model *ContextModel) Report(alpha float64) (reports []*ContextReport) {
+	var global = model.GlobalRate()
+	for _, stat := range model.Contexts {
+		var (
+			n      = float64(stat.Steps)
+			mean   = stat.RateSum / n
+			report = &ContextReport{
+				ContextStat: stat,
+				ErrorRate:   math.NaN(),
+				Mean:        mean,
+				SD:          math.NaN(),
+				Ratio:       mean / global,
+				T:           math.NaN(),
+				P:           math.NaN(),
+			}
+		)
+		if stat.Reads > 0 {
+			report.ErrorRate = float64(stat.Errors) / float64(stat.Reads)
+		}
+		if stat.Steps > 1 {
+			report.SD = math.Sqrt(math.Max(0, (stat.RateSumSq-n*mean*mean)/(n-1)))
+			if report.SD > 0 {
+				report.T = (mean - global) / (report.SD / math.Sqrt(n))
+				report.P = 2 * distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 1}.Survival(math.Abs(report.T))
+			}
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Mean != reports[j].Mean {
+			return reports[i].Mean > reports[j].Mean
+		}
+		return reports[i].Context+reports[i].Base < reports[j].Context+reports[j].Base
+	})
+	var p []float64
+	for _, report := range reports {
+		p = append(p, report.P)
+	}
+	for i, adj := range BHAdjust(p) {
+		reports[i].PAdj = adj
+		reports[i].Hot = adj < alpha && reports[i].Mean > global
+	}
+	return
+}
+
+// contextTitle titles of context.txt
+func contextTitle() []string {
+	return []string{
+		L("上下文"), L("碱基"), L("步数"), L("reads数"), L("错误率"),
+		L("单步错误率均值"), L("单步错误率标准差"), L("倍数"), "T", "P", L("校正P"), L("高风险"),
+	}
+}
+
+// Row values of ContextReport
+func (report *ContextReport) Row() []string {
+	var hot = ""
+	if report.Hot {
+		hot = "*"
+	}
+	return []string{
+		report.Context, report.Base, strconv.Itoa(report.Steps), strconv.Itoa(report.Reads), formatFloat(report.ErrorRate),
+		formatFloat(report.Mean), formatFloat(report.SD), formatFloat(report.Ratio),
+		formatFloat(report.T), formatFloat(report.P), formatFloat(report.PAdj), hot,
+	}
+}
+
+// WriteContextTxt write reports to path
+func WriteContextTxt(path string, reports []*ContextReport) {
+	var file = osUtil.Create(path)
+	defer simpleUtil.DeferClose(file)
+	fmtUtil.FprintStringArray(file, contextTitle(), "\t")
+	for _, report := range reports {
+		fmtUtil.FprintStringArray(file, report.Row(), "\t")
+	}
+}
+
+// ErrRateFiles one.step.error.rate.txt files of path,
+// all [id].one.step.error.rate.txt except pooled samples if path is dir
+func ErrRateFiles(path string) (files []string, err error) {
+	var info os.FileInfo
+	info, err = os.Stat(path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var matches []string
+	matches, err = filepath.Glob(filepath.Join(path, "*.one.step.error.rate.txt"))
+	for _, file := range matches {
+		if !strings.HasSuffix(file, PooledSuffix+".one.step.error.rate.txt") {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return
+}
@@ -0,0 +1,116 @@
+package seqAnalysis
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadErrRateRecords(t *testing.T) {
+	var records, err = LoadErrRateRecords(strings.NewReader("s1\tACTG\tA\t1\t1.500000\t200\t197\n\ns1\tCTGA\tC\t2\t2.000000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || *records[0] != (ErrRateRecord{"s1", "ACTG", 'A', 1, 1.5, 200, 197, true}) || records[1].Reads != 0 {
+		t.Errorf("LoadErrRateRecords = %+v %+v", records[0], records[1])
+	}
+	if _, err = LoadErrRateRecords(strings.NewReader("s1\tACTG\tA\tx\t1.5\n")); err == nil {
+		t.Errorf("invalid pos should fail")
+	}
+}
+
+func TestContextModel(t *testing.T) {
+	var model, err = NewContextModel(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewContextModel(5); err == nil {
+		t.Errorf("NewContextModel(5) should fail")
+	}
+	var records []*ErrRateRecord
+	for i, rate := range []float64{9, 10, 11, 10} {
+		// GA>C 高错误率，AT>G 低错误率
+		records = append(records,
+			&ErrRateRecord{ID: "s", Context: "TTGA", Base: 'C', Pos: i, ErrRate: rate, Reads: 100, Right: 100 - int(rate)},
+			&ErrRateRecord{ID: "s", Context: "CCAT", Base: 'G', Pos: i, ErrRate: rate / 10, Reads: 100, Right: 100},
+		)
+	}
+	records = append(records, &ErrRateRecord{ID: "s", Context: "A", Base: 'G', ErrRate: 50})
+	if err = model.AddRecords("a", records, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = model.AddRecords("a", records, false); !errors.Is(err, ErrContextSource) {
+		t.Errorf("AddRecords same source = %v", err)
+	}
+	var stat = model.Contexts["GAC"]
+	if len(model.Contexts) != 2 || stat.Steps != 4 || stat.Reads != 400 || stat.Errors != 40 || math.Abs(stat.RateSum-0.4) > 1e-12 {
+		t.Errorf("Contexts = %+v", model.Contexts)
+	}
+	if rate, ok := model.Rate("TTGA", 'C'); !ok || math.Abs(rate-0.1) > 1e-12 {
+		t.Errorf("Rate = %v %v", rate, ok)
+	}
+
+	// 保存、读取后合并
+	var path = filepath.Join(t.TempDir(), "model.json")
+	if err = model.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	var other, _ = NewContextModel(2)
+	if err = other.AddRecords("b", records[:2], false); err != nil {
+		t.Fatal(err)
+	}
+	var loaded *ContextModel
+	if loaded, err = LoadContextModel(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.Merge(other); err != nil || loaded.Contexts["GAC"].Steps != 5 || len(loaded.Sources) != 2 {
+		t.Errorf("Merge = %v %+v", err, loaded.Contexts["GAC"])
+	}
+	if err = loaded.Merge(other); !errors.Is(err, ErrContextSource) {
+		t.Errorf("Merge same source = %v", err)
+	}
+
+	var reports = model.Report(0.05)
+	// 全局均值 0.055，GAC 均值 0.1 sd 0.008165
+	if reports[0].Context != "GA" || reports[0].Base != "C" || !reports[0].Hot || reports[1].Hot {
+		t.Errorf("Report = %+v %+v", reports[0], reports[1])
+	}
+	if math.Abs(reports[0].T-(0.1-0.055)/(math.Sqrt(2.0/3)/100/2)) > 1e-6 {
+		t.Errorf("Report T = %v", reports[0].T)
+	}
+}
+
+func TestContextModelSkipInvalid(t *testing.T) {
+	var records, err = LoadErrRateRecords(strings.NewReader(
+		"s1\tACTG\tA\t1\t1.000000\t100\t99\n" +
+			"s1\tACTG\tA\t2\tNaN\t0\t0\n" + // 0 reads 的末端步
+			"s1\tACTG\tA\t3\t0.000000\t0\t0\n" +
+			"s1\tACTG\tA\t4\t2.000000\n", // 旧版文件无 reads 列
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model, _ = NewContextModel(2)
+	if err = model.AddRecords("a", records, false); err != nil {
+		t.Fatal(err)
+	}
+	var stat = model.Contexts["TGA"]
+	if stat == nil || stat.Steps != 2 || math.Abs(stat.RateSum-0.03) > 1e-12 {
+		t.Errorf("AddRecords with invalid records = %+v", stat)
+	}
+	if err = model.Save(filepath.Join(t.TempDir(), "model.json")); err != nil {
+		t.Errorf("Save = %v", err)
+	}
+}
+
+func TestFilterRecordOutliers(t *testing.T) {
+	var records []*ErrRateRecord
+	for _, rate := range []float64{1, 1.1, 0.9, 1, 1.2, 20} {
+		records = append(records, &ErrRateRecord{ErrRate: rate})
+	}
+	if kept := filterRecordOutliers(records); len(kept) != 5 {
+		t.Errorf("filterRecordOutliers kept %d, want 5", len(kept))
+	}
+}
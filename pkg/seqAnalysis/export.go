@@ -0,0 +1,125 @@
+package seqAnalysis
+
+import (
+	"os"
+	"sync"
+
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// classification of distinct sequence, class column of sequences.parquet
+const (
+	ClassRight               = "right"
+	ClassDeletion            = "deletion" // 缺失，无法细分，如全缺失
+	ClassDeletionSingle      = "deletion-single"
+	ClassDeletionContinuous2 = "deletion-continuous2"
+	ClassDeletionDiscrete2   = "deletion-discrete2"
+	ClassDeletionContinuous3 = "deletion-continuous3"
+	ClassDeletionDiscrete3   = "deletion-discrete3"
+	ClassInsertion           = "insertion"
+	ClassInsertionDeletion   = "insertion-deletion"
+	ClassMutation            = "mutation"
+	ClassOther               = "other"
+)
+
+// exportBufferSize rows buffered by each sample before written to SeqExport
+const exportBufferSize = 4096
+
+// SeqRecord one row of sequences.parquet, one distinct sequence of one sample,
+// align columns same as BarCode sheet, empty if not aligned
+type SeqRecord struct {
+	Sample         string `parquet:"sample,dict"`
+	Parallel       string `parquet:"parallel,dict"`
+	Target         string `parquet:"target,dict"`
+	Seq            string `parquet:"seq"`
+	Count          int64  `parquet:"count"`
+	Length         int32  `parquet:"length"`
+	Class          string `parquet:"class,dict"`
+	AlignDeletion  string `parquet:"align_deletion"`
+	AlignInsertion string `parquet:"align_insertion"`
+	AlignMutation  string `parquet:"align_mutation"`
+}
+
+// SeqExport sequences.parquet of batch, written by samples concurrently
+type SeqExport struct {
+	Path string
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *parquet.GenericWriter[SeqRecord]
+}
+
+// NewSeqExport create parquet file of path
+func NewSeqExport(path string) (*SeqExport, error) {
+	var file, err = os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SeqExport{
+		Path:   path,
+		file:   file,
+		writer: parquet.NewGenericWriter[SeqRecord](file, parquet.Compression(&zstd.Codec{})),
+	}, nil
+}
+
+// Write append records
+func (export *SeqExport) Write(records []SeqRecord) error {
+	export.mu.Lock()
+	defer export.mu.Unlock()
+	_, err := export.writer.Write(records)
+	return err
+}
+
+// Close write footer and close file
+func (export *SeqExport) Close() error {
+	if err := export.writer.Close(); err != nil {
+		export.file.Close()
+		return err
+	}
+	return export.file.Close()
+}
+
+// exportSeq record distinct sequence key with class and alignments to Export
+func (seqInfo *SeqInfo) exportSeq(key, class string, aligns ...[]byte) {
+	if seqInfo.Export == nil {
+		return
+	}
+	var record = SeqRecord{
+		Sample:   seqInfo.Name,
+		Parallel: seqInfo.ParallelTestID,
+		Target:   string(seqInfo.Seq),
+		Seq:      key,
+		Count:    int64(seqInfo.HitSeqCount[key]),
+		Length:   int32(len(key)),
+		Class:    class,
+	}
+	if key == "X" {
+		// 靶标后直接 polyA
+		record.Length = 0
+	}
+	for i, align := range aligns {
+		switch i {
+		case 0:
+			record.AlignDeletion = string(align)
+		case 1:
+			record.AlignInsertion = string(align)
+		case 2:
+			record.AlignMutation = string(align)
+		}
+	}
+	seqInfo.exportBuffer = append(seqInfo.exportBuffer, record)
+	if len(seqInfo.exportBuffer) >= exportBufferSize {
+		seqInfo.flushExport()
+	}
+}
+
+// flushExport write buffered records to Export
+func (seqInfo *SeqInfo) flushExport() {
+	if seqInfo.Export == nil || len(seqInfo.exportBuffer) == 0 {
+		return
+	}
+	simpleUtil.CheckErr(seqInfo.Export.Write(seqInfo.exportBuffer))
+	seqInfo.exportBuffer = seqInfo.exportBuffer[:0]
+}
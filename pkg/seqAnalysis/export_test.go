@@ -0,0 +1,48 @@
+package seqAnalysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestSeqExport(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "sequences.parquet")
+	var export, err = NewSeqExport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqInfo = &SeqInfo{
+		Name:           "K30",
+		ParallelTestID: "K",
+		Seq:            []byte("ACGT"),
+		HitSeqCount:    map[string]int{"ACGT": 10, "AGT": 3, "X": 1},
+		Export:         export,
+	}
+	seqInfo.exportSeq("ACGT", ClassRight)
+	seqInfo.exportSeq("AGT", ClassDeletionSingle, []byte("A-GT"))
+	seqInfo.exportSeq("X", ClassDeletion, []byte("----"))
+	seqInfo.flushExport()
+	if err = export.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parquet.ReadFile[SeqRecord](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = []SeqRecord{
+		{Sample: "K30", Parallel: "K", Target: "ACGT", Seq: "ACGT", Count: 10, Length: 4, Class: ClassRight},
+		{Sample: "K30", Parallel: "K", Target: "ACGT", Seq: "AGT", Count: 3, Length: 3, Class: ClassDeletionSingle, AlignDeletion: "A-GT"},
+		{Sample: "K30", Parallel: "K", Target: "ACGT", Seq: "X", Count: 1, Length: 0, Class: ClassDeletion, AlignDeletion: "----"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %+v", rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
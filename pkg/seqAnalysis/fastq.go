@@ -0,0 +1,190 @@
+package seqAnalysis
+
+import (
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+)
+
+// DefaultFastqTemplate fastq of headerless txt input without fq columns
+const DefaultFastqTemplate = "00.CleanData/{id}/{id}_{read}.clean.fq.gz"
+
+// FastqLocate locate fastq of samples without 路径, nil for no locating
+var FastqLocate *FastqLocator
+
+var (
+	templateVar = regexp.MustCompile(`\{([^{}]+)\}`)
+	isFastq     = regexp.MustCompile(`\.f(ast)?q(\.gz)?$`)
+)
+
+// FastqLocator locate fastq by Template, or auto-discovery under Root when Template is empty
+type FastqLocator struct {
+	// Root root dir, {root} of Template
+	Root string
+	// Batch batch name, {batch} of Template
+	Batch string
+	// Template e.g. {root}/{batch}/{id}_R{read}.fastq.gz, support glob, {id} is 样品名称, {read} is 1/2, other {column} is value of column
+	Template string
+	// Errors of auto-discovery, files under unreadable dirs not found
+	Errors []error
+
+	files []string
+}
+
+// ExpandTemplate replace {key} of tmpl with vars, keep unknown key as is
+func ExpandTemplate(tmpl string, vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(tmpl, func(s string) string {
+		if v, ok := vars[s[1:len(s)-1]]; ok {
+			return v
+		}
+		return s
+	})
+}
+
+// sampleName 样品名称 of data, id for headerless txt
+func sampleName(data map[string]string) string {
+	if name := data["样品名称"]; name != "" {
+		return name
+	}
+	return data["id"]
+}
+
+// discoverPattern regexp of fastq of id and read, e.g. [id]_S1_L001_R1_001.fastq.gz, [id]_1.fq.gz, [id].R1.fq
+func discoverPattern(id, read string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(id) + `([._-].*)?[._-](R` + read + `(_\d+)?|` + read + `)(\.clean)?\.f(ast)?q(\.gz)?$`)
+}
+
+// discover fastq files under fqDir/Root, relative to fqDir
+func (locator *FastqLocator) discover(fqDir string) []string {
+	if locator.files != nil {
+		return locator.files
+	}
+	locator.files = []string{}
+	var root = locator.Root
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(fqDir, root)
+	}
+	var err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 不可读目录跳过，记录后继续查找其他目录
+			locator.Errors = append(locator.Errors, err)
+			slog.Warn("discover fastq", "path", path, "err", err)
+			return nil
+		}
+		if d.IsDir() || !isFastq.MatchString(d.Name()) {
+			return nil
+		}
+		if fqDir != "" && !filepath.IsAbs(locator.Root) {
+			var rel, err = filepath.Rel(fqDir, path)
+			if err != nil {
+				return err
+			}
+			path = rel
+		}
+		locator.files = append(locator.files, path)
+		return nil
+	})
+	if err != nil {
+		locator.Errors = append(locator.Errors, err)
+		slog.Error("discover fastq", "root", root, "err", err)
+	}
+	sort.Strings(locator.files)
+	return locator.files
+}
+
+// Locate fastq of read (1 or 2) of sample, relative to fqDir
+func (locator *FastqLocator) Locate(data map[string]string, fqDir, read string) []string {
+	var id = sampleName(data)
+	if locator.Template != "" {
+		var vars = map[string]string{
+			"root":  locator.Root,
+			"batch": locator.Batch,
+			"read":  read,
+		}
+		for k, v := range data {
+			vars[k] = v
+		}
+		vars["id"] = id
+		var pattern = ExpandTemplate(locator.Template, vars)
+		if filepath.IsAbs(pattern) {
+			return globFastq("", pattern)
+		}
+		return globFastq(fqDir, pattern)
+	}
+
+	var (
+		reg   = discoverPattern(id, read)
+		files []string
+	)
+	for _, file := range locator.discover(fqDir) {
+		if reg.MatchString(filepath.Base(file)) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// LocateFastq fill empty 路径-R1/路径-R2 by FastqLocate, record match count to fqMatch-R1/fqMatch-R2,
+// file matched by several samples belong to the longest 样品名称
+func LocateFastq(info []map[string]string, fqDir string) {
+	if FastqLocate == nil {
+		return
+	}
+	var owner = make(map[string]string)
+	var matches = make([]map[string][]string, len(info))
+	for i, data := range info {
+		if data["路径-R1"] != "" {
+			continue
+		}
+		matches[i] = make(map[string][]string)
+		for _, read := range []string{"1", "2"} {
+			var files = FastqLocate.Locate(data, fqDir, read)
+			matches[i][read] = files
+			for _, file := range files {
+				if id := sampleName(data); len(id) > len(owner[file]) {
+					owner[file] = id
+				}
+			}
+		}
+	}
+	for i, data := range info {
+		if matches[i] == nil {
+			continue
+		}
+		for _, read := range []string{"1", "2"} {
+			var files []string
+			for _, file := range matches[i][read] {
+				if owner[file] == sampleName(data) {
+					files = append(files, file)
+				}
+			}
+			data["路径-R"+read] = strings.Join(files, ",")
+			data["fqMatch-R"+read] = strconv.Itoa(len(files))
+		}
+	}
+}
+
+// WriteFastqMatches write match count and files of located fastq, return false if no sample located
+func WriteFastqMatches(w io.Writer, info []map[string]string) bool {
+	var located = false
+	for _, data := range info {
+		if _, ok := data["fqMatch-R1"]; !ok {
+			continue
+		}
+		if !located {
+			located = true
+			fmtUtil.Fprintln(w, L("样品")+"\tread\t"+L("匹配数")+"\t"+L("文件"))
+		}
+		for _, read := range []string{"R1", "R2"} {
+			fmtUtil.Fprintf(w, "%s\t%s\t%s\t%s\n", data["id"], read, data["fqMatch-"+read], data["路径-"+read])
+		}
+	}
+	return located
+}
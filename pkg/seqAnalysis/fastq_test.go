@@ -0,0 +1,54 @@
+package seqAnalysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	var got = ExpandTemplate("{root}/{batch}/{id}_R{read}.fastq.gz{x}", map[string]string{"root": "/raw", "batch": "B1", "id": "K30", "read": "1"})
+	if want := "/raw/B1/K30_R1.fastq.gz{x}"; got != want {
+		t.Errorf("ExpandTemplate() = %s, want %s", got, want)
+	}
+}
+
+func TestDiscoverPattern(t *testing.T) {
+	var tests = []struct {
+		name string
+		read string
+		want bool
+	}{
+		{"K30_S10_L001_R1_001.fastq.gz", "1", true},
+		{"K30_S10_L001_R2_001.fastq.gz", "1", false},
+		{"K30_R1.fastq.gz", "1", true},
+		{"K30_2.clean.fq.gz", "2", true},
+		{"K30.R1.fq", "1", true},
+		{"K30-1_S2_L001_R1_001.fastq.gz", "1", true}, // 由 LocateFastq 归属到更长的样品名称
+		{"K301_R1.fastq.gz", "1", false},
+		{"K30_R1.txt", "1", false},
+	}
+	for _, tt := range tests {
+		if got := discoverPattern("K30", tt.read).MatchString(tt.name); got != tt.want {
+			t.Errorf("discoverPattern(K30, %s) match %s = %v, want %v", tt.read, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverError(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "raw"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "raw", "K30_R1.fq.gz"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var locator = &FastqLocator{Root: "raw"}
+	if files := locator.discover(dir); len(files) != 1 || files[0] != filepath.Join("raw", "K30_R1.fq.gz") || len(locator.Errors) != 0 {
+		t.Errorf("discover = %v, errors %v", files, locator.Errors)
+	}
+	locator = &FastqLocator{Root: "missing"}
+	if files := locator.discover(dir); len(files) != 0 || len(locator.Errors) != 1 {
+		t.Errorf("discover of missing root = %v, errors %v", files, locator.Errors)
+	}
+}
@@ -0,0 +1,423 @@
+package seqAnalysis
+
+import (
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+	"gonum.org/v1/plot/vg/vgpdf"
+)
+
+// 同 plot.R 的 pdf(width = 16, height = 9)
+const (
+	figureWidth  = 16 * vg.Inch
+	figureHeight = 9 * vg.Inch
+	figureDPI    = 100
+)
+
+// 长度分布中，合成长度 ±lengthExclude 以外的 reads 另外作图
+const lengthExclude = 5
+
+// figurePage draw one page of figure
+type figurePage func(dc draw.Canvas)
+
+// WriteFigure write pages to prefix.pdf, first page to prefix.png
+func WriteFigure(prefix string, pages []figurePage) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	var pdf = vgpdf.New(figureWidth, figureHeight)
+	for i, page := range pages {
+		if i > 0 {
+			pdf.NextPage()
+		}
+		page(draw.New(pdf))
+	}
+	var err = writeCanvas(prefix+".pdf", pdf)
+	if err != nil {
+		return err
+	}
+
+	var png = vgimg.PngCanvas{Canvas: vgimg.NewWith(vgimg.UseWH(figureWidth, figureHeight), vgimg.UseDPI(figureDPI))}
+	pages[0](draw.New(png))
+	return writeCanvas(prefix+".png", png)
+}
+
+// writeCanvas write pdf/png canvas to path
+func writeCanvas(path string, canvas interface {
+	WriteTo(w io.Writer) (int64, error)
+}) error {
+	var f, err = os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = canvas.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// drawFacets draw plots in rows×cols tiles, nil for empty tile
+func drawFacets(dc draw.Canvas, plots [][]*plot.Plot) {
+	var tiles = draw.Tiles{
+		Rows:      len(plots),
+		Cols:      len(plots[0]),
+		PadX:      vg.Millimeter * 2,
+		PadY:      vg.Millimeter * 2,
+		PadTop:    vg.Millimeter * 2,
+		PadBottom: vg.Millimeter * 2,
+		PadLeft:   vg.Millimeter * 2,
+		PadRight:  vg.Millimeter * 2,
+	}
+	var canvases = plot.Align(plots, tiles, dc)
+	for j := range plots {
+		for i, p := range plots[j] {
+			if p != nil {
+				p.Draw(canvases[j][i])
+			}
+		}
+	}
+}
+
+// facetPage page of plots, ncol plots per row
+func facetPage(plots []*plot.Plot, ncol int) figurePage {
+	return func(dc draw.Canvas) {
+		var grid [][]*plot.Plot
+		for i, p := range plots {
+			if i%ncol == 0 {
+				grid = append(grid, make([]*plot.Plot, ncol))
+			}
+			grid[i/ncol][i%ncol] = p
+		}
+		drawFacets(dc, grid)
+	}
+}
+
+// groupSamples group samples by key in order of first appearance
+func groupSamples(samples []*SeqInfo, key func(info *SeqInfo) string) (keys []string, groups map[string][]*SeqInfo) {
+	groups = make(map[string][]*SeqInfo)
+	for _, info := range samples {
+		var k = key(info)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], info)
+	}
+	return
+}
+
+// sampleLab lab of sample, 样品名称 before first "-", same as plot.R
+func sampleLab(info *SeqInfo) string {
+	return strings.Split(info.Name, "-")[0]
+}
+
+// newFigurePlot plot with title and axis labels, font size as theme(text = element_text(size = 20)) of plot.R
+func newFigurePlot(title, xName, yName string) *plot.Plot {
+	var p = plot.New()
+	p.Title.Text = title
+	p.X.Label.Text = xName
+	p.Y.Label.Text = yName
+	p.Legend.Top = true
+	return p
+}
+
+// baseTicks label each position with synthesized base, add position every 10 bases
+func baseTicks(seq []byte) plot.Ticker {
+	return plot.TickerFunc(func(min, max float64) (ticks []plot.Tick) {
+		for i, b := range seq {
+			var pos = i + 1
+			if float64(pos) < min || float64(pos) > max {
+				continue
+			}
+			var label = string(b)
+			if pos%10 == 0 {
+				label += "\n" + strconv.Itoa(pos)
+			}
+			ticks = append(ticks, plot.Tick{Value: float64(pos), Label: label})
+		}
+		return
+	})
+}
+
+// stepTicks ticks every step, as scale_x_continuous(breaks = seq(0, 100, by = 5)) of length.dist.R
+func stepTicks(step float64) plot.Ticker {
+	return plot.TickerFunc(func(min, max float64) (ticks []plot.Tick) {
+		for v := math.Ceil(min/step) * step; v <= max; v += step {
+			ticks = append(ticks, plot.Tick{Value: v, Label: strconv.FormatFloat(v, 'f', -1, 64)})
+		}
+		return
+	})
+}
+
+// errRatePlot error rate (%) of each position of samples, x labelled by synthesized base of longest 合成序列
+func errRatePlot(title string, samples []*SeqInfo, yMax float64) *plot.Plot {
+	// gonum/plot 内置字体不含中文，坐标轴使用英文
+	var p = newFigurePlot(title, "Synthesis", "error rate (%)")
+	var longest []byte
+	for i, info := range samples {
+		if len(info.Seq) > len(longest) {
+			longest = info.Seq
+		}
+		var pts plotter.XYs
+		for _, step := range info.Steps {
+			pts = append(pts, plotter.XY{X: float64(step.Position), Y: (1 - step.StepAccuracy) * 100})
+		}
+		if len(pts) == 0 {
+			continue
+		}
+		var line, points, err = plotter.NewLinePoints(pts)
+		simpleUtil.CheckErr(err)
+		line.Color = plotutil.Color(i)
+		points.Color = plotutil.Color(i)
+		points.Shape = draw.CircleGlyph{}
+		p.Add(line, points)
+		p.Legend.Add(info.Name, line, points)
+	}
+	p.X.Tick.Marker = baseTicks(longest)
+	p.X.Min = 0.5
+	p.X.Max = float64(len(longest)) + 0.5
+	p.Y.Min = 0
+	if yMax > 0 {
+		p.Y.Max = yMax
+	}
+	return p
+}
+
+// maxErrRate max error rate (%) of samples, for facets sharing y axis
+func maxErrRate(samples []*SeqInfo) (yMax float64) {
+	for _, info := range samples {
+		for _, step := range info.Steps {
+			yMax = max(yMax, (1-step.StepAccuracy)*100)
+		}
+	}
+	return
+}
+
+// errRateFacets one plot per group, shared y unless freeY
+func errRateFacets(keys []string, groups map[string][]*SeqInfo, freeY bool) (plots []*plot.Plot) {
+	var yMax float64
+	for _, k := range keys {
+		yMax = max(yMax, maxErrRate(groups[k]))
+	}
+	for _, k := range keys {
+		if freeY {
+			yMax = 0
+		}
+		plots = append(plots, errRatePlot(k, groups[k], yMax))
+	}
+	return
+}
+
+// ErrRatePages pages of ErrRate.pdf as plot.R:
+// facet by 合成序列 / by lab, shared and free y, then one page per lab and per sample
+func ErrRatePages(samples []*SeqInfo) (pages []figurePage) {
+	if len(samples) == 0 {
+		return
+	}
+	var (
+		seqKeys, seqGroups = groupSamples(samples, func(info *SeqInfo) string { return string(info.Seq) })
+		labKeys, labGroups = groupSamples(samples, sampleLab)
+	)
+	pages = append(
+		pages,
+		facetPage(errRateFacets(seqKeys, seqGroups, false), 1),
+		facetPage(errRateFacets(seqKeys, seqGroups, true), 1),
+		facetPage(errRateFacets(labKeys, labGroups, false), 1),
+		facetPage(errRateFacets(labKeys, labGroups, true), 1),
+	)
+	for _, k := range labKeys {
+		pages = append(pages, facetPage([]*plot.Plot{errRatePlot(k, labGroups[k], 0)}, 1))
+	}
+	for _, info := range samples {
+		pages = append(pages, facetPage([]*plot.Plot{errRatePlot(info.Name, []*SeqInfo{info}, 0)}, 1))
+	}
+	return
+}
+
+// lengthBars weighted histogram with binwidth 1, bar from Base to weight, Base 1 for log scale
+type lengthBars struct {
+	plotter.XYs
+	Base  float64
+	Color color.Color
+}
+
+// Plot implements plot.Plotter
+func (bars *lengthBars) Plot(c draw.Canvas, p *plot.Plot) {
+	var trX, trY = p.Transforms(&c)
+	for _, xy := range bars.XYs {
+		var (
+			x0 = trX(xy.X - 0.5)
+			x1 = trX(xy.X + 0.5)
+			y0 = trY(bars.Base)
+			y1 = trY(xy.Y)
+		)
+		c.FillPolygon(bars.Color, c.ClipPolygonXY([]vg.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}))
+	}
+}
+
+// DataRange implements plot.DataRanger
+func (bars *lengthBars) DataRange() (xmin, xmax, ymin, ymax float64) {
+	xmin, xmax, ymin, ymax = math.Inf(1), math.Inf(-1), bars.Base, bars.Base
+	for _, xy := range bars.XYs {
+		xmin = min(xmin, xy.X-0.5)
+		xmax = max(xmax, xy.X+0.5)
+		ymax = max(ymax, xy.Y)
+	}
+	return
+}
+
+// vLines red vertical lines across plot, as geom_vline of length.dist.R
+type vLines []float64
+
+// Plot implements plot.Plotter
+func (lines vLines) Plot(c draw.Canvas, p *plot.Plot) {
+	var trX, _ = p.Transforms(&c)
+	var style = draw.LineStyle{Color: color.RGBA{R: 255, A: 255}, Width: vg.Points(1)}
+	for _, x := range lines {
+		var X = trX(x)
+		if X >= c.Min.X && X <= c.Max.X {
+			c.StrokeLine2(style, X, c.Min.Y, X, c.Max.Y)
+		}
+	}
+}
+
+// lengthWeights sum of Histogram of samples, exclude lengths within ±lengthExclude of 合成序列 if exclude
+func lengthWeights(samples []*SeqInfo, exclude bool) map[int]int {
+	var weights = make(map[int]int)
+	for _, info := range samples {
+		for k, v := range info.Histogram {
+			if exclude && k >= len(info.Seq)-lengthExclude && k <= len(info.Seq)+lengthExclude {
+				continue
+			}
+			weights[k] += v
+		}
+	}
+	return weights
+}
+
+// lengthPlot histogram of length of samples
+func lengthPlot(title string, samples []*SeqInfo, exclude, logY, guide bool) *plot.Plot {
+	var (
+		p    = newFigurePlot(title, "length", "count")
+		bars = &lengthBars{Color: color.Gray{Y: 89}}
+	)
+	for k, v := range lengthWeights(samples, exclude) {
+		bars.XYs = append(bars.XYs, plotter.XY{X: float64(k), Y: float64(v)})
+	}
+	if logY {
+		bars.Base = 1
+		p.Y.Scale = plot.LogScale{}
+		p.Y.Tick.Marker = plot.LogTicks{}
+	}
+	if len(bars.XYs) == 0 {
+		// 空图，保持坐标轴有效
+		bars.XYs = plotter.XYs{{X: 0, Y: bars.Base}}
+	}
+	p.Add(bars)
+	if guide {
+		p.Add(vLines{50, 60, 70, 80, 90})
+		p.X.Tick.Marker = stepTicks(5)
+	}
+	return p
+}
+
+// lengthGrid one plot per 合成序列 length
+func lengthGrid(samples []*SeqInfo, exclude, logY bool) (plots []*plot.Plot) {
+	var keys, groups = groupSamples(samples, func(info *SeqInfo) string { return strconv.Itoa(len(info.Seq)) })
+	for _, k := range keys {
+		plots = append(plots, lengthPlot(k, groups[k], exclude, logY, true))
+	}
+	return
+}
+
+// lengthWrap one plot per sample
+func lengthWrap(samples []*SeqInfo, exclude, logY bool) (plots []*plot.Plot) {
+	for _, info := range samples {
+		plots = append(plots, lengthPlot(info.Name, []*SeqInfo{info}, exclude, logY, true))
+	}
+	return
+}
+
+// sideBySide page of two facet lists side by side, as plot_grid(a, b, ncol = 2)
+func sideBySide(facets ...[]*plot.Plot) figurePage {
+	return func(dc draw.Canvas) {
+		var grid [][]*plot.Plot
+		for i := range facets[0] {
+			var row []*plot.Plot
+			for _, plots := range facets {
+				row = append(row, plots[i])
+			}
+			grid = append(grid, row)
+		}
+		drawFacets(dc, grid)
+	}
+}
+
+// HistogramPages pages of histogram.pdf as length.dist.R:
+// facet by 合成序列 length, with reads of length out of ±5 of 合成序列, linear and log y,
+// then one facet per sample, then one page per sample
+func HistogramPages(samples []*SeqInfo) (pages []figurePage) {
+	if len(samples) == 0 {
+		return
+	}
+	var (
+		grid        = lengthGrid(samples, false, false)
+		gridLog     = lengthGrid(samples, false, true)
+		gridOut     = lengthGrid(samples, true, false)
+		gridOutLog  = lengthGrid(samples, true, true)
+		wrapColumns = min(3, len(samples))
+	)
+	pages = append(
+		pages,
+		facetPage(grid, 1),
+		facetPage(gridLog, 1),
+		facetPage(gridOut, 1),
+		facetPage(gridOutLog, 1),
+		sideBySide(grid, gridLog),
+		sideBySide(gridOut, gridOutLog),
+		sideBySide(grid, gridLog, gridOut, gridOutLog),
+		facetPage(lengthWrap(samples, false, false), wrapColumns),
+		facetPage(lengthWrap(samples, false, true), wrapColumns),
+		facetPage(lengthWrap(samples, true, false), wrapColumns),
+		facetPage(lengthWrap(samples, true, true), wrapColumns),
+	)
+	for _, info := range samples {
+		var one = []*SeqInfo{info}
+		pages = append(
+			pages,
+			facetPage([]*plot.Plot{
+				lengthPlot(info.Name, one, false, false, false),
+				lengthPlot(info.Name, one, false, true, false),
+				lengthPlot(info.Name, one, true, false, false),
+				lengthPlot(info.Name, one, true, true, false),
+			}, 2),
+		)
+	}
+	return
+}
+
+// WriteFigures write ErrRate.pdf/png and histogram.pdf/png to outputDir, samples in order of inputInfo, no R needed
+func WriteFigures(outputDir string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo) error {
+	var samples []*SeqInfo
+	for _, data := range inputInfo {
+		samples = append(samples, SeqInfoMap[data["id"]])
+	}
+	var err = WriteFigure(filepath.Join(outputDir, "ErrRate"), ErrRatePages(samples))
+	if err != nil {
+		return err
+	}
+	return WriteFigure(filepath.Join(outputDir, "histogram"), HistogramPages(samples))
+}
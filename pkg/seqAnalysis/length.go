@@ -0,0 +1,170 @@
+package seqAnalysis
+
+import (
+	"image/color"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// LengthStats summary of read length Histogram
+type LengthStats struct {
+	// 众数，相同 reads 时取较长
+	Mode int
+	Mean float64
+	// 全长（= 合成序列长度）reads 比例
+	FullLength float64
+	// 短于 n-lengthExclude reads 比例
+	Short float64
+	// 按碱基数的 N50：≥ N50 的 reads 含一半以上碱基
+	N50 int
+}
+
+// NewLengthStats LengthStats of Histogram of reads of 合成序列 length n, zero if no reads
+func NewLengthStats(histogram map[int]int, n int) *LengthStats {
+	var (
+		stats   = &LengthStats{}
+		lengths []int
+		reads   int
+		bases   int
+		modeN   int
+	)
+	for length, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		lengths = append(lengths, length)
+		reads += count
+		bases += length * count
+		if count > modeN || (count == modeN && length > stats.Mode) {
+			stats.Mode, modeN = length, count
+		}
+		if length == n {
+			stats.FullLength += float64(count)
+		}
+		if length < n-lengthExclude {
+			stats.Short += float64(count)
+		}
+	}
+	if reads == 0 {
+		return stats
+	}
+	stats.Mean = float64(bases) / float64(reads)
+	stats.FullLength /= float64(reads)
+	stats.Short /= float64(reads)
+
+	sort.Sort(sort.Reverse(sort.IntSlice(lengths)))
+	var acc int
+	for _, length := range lengths {
+		acc += length * histogram[length]
+		if acc*2 >= bases {
+			stats.N50 = length
+			break
+		}
+	}
+	return stats
+}
+
+// lengthTitle titles of LengthRow
+func lengthTitle() []string {
+	return []string{L("长度众数"), L("平均长度"), L("全长比例"), L("短于n-5比例"), "N50"}
+}
+
+// LengthRow length columns of summary, same order as lengthTitle
+func (seqInfo *SeqInfo) LengthRow() []any {
+	var stats = NewLengthStats(seqInfo.Histogram, len(seqInfo.Seq))
+	return []any{stats.Mode, stats.Mean, stats.FullLength, stats.Short, stats.N50}
+}
+
+// gelLane reads of lengths of one sample
+type gelLane struct {
+	histogram map[int]int
+	full      int
+	max       int
+}
+
+// gelBands lanes of virtual gel, band darkness by sqrt of reads / max reads of lane, red mark at 合成序列 length
+type gelBands []*gelLane
+
+// Plot implements plot.Plotter
+func (lanes gelBands) Plot(c draw.Canvas, p *plot.Plot) {
+	var trX, trY = p.Transforms(&c)
+	for i, lane := range lanes {
+		var (
+			x0 = trX(float64(i) + 0.15)
+			x1 = trX(float64(i) + 0.85)
+		)
+		// 泳道底色
+		c.FillPolygon(color.Gray{Y: 235}, c.ClipPolygonXY([]vg.Point{
+			{X: x0, Y: c.Min.Y}, {X: x1, Y: c.Min.Y}, {X: x1, Y: c.Max.Y}, {X: x0, Y: c.Max.Y},
+		}))
+		for length, count := range lane.histogram {
+			if count == 0 || lane.max == 0 {
+				continue
+			}
+			var (
+				v  = math.Sqrt(float64(count) / float64(lane.max))
+				y0 = trY(float64(length) - 0.4)
+				y1 = trY(float64(length) + 0.4)
+			)
+			c.FillPolygon(color.Gray{Y: uint8(235 * (1 - v))}, c.ClipPolygonXY([]vg.Point{
+				{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1},
+			}))
+		}
+		var y = trY(float64(lane.full))
+		if y >= c.Min.Y && y <= c.Max.Y {
+			c.StrokeLine2(draw.LineStyle{Color: color.RGBA{R: 255, A: 255}, Width: vg.Points(1)}, x0-vg.Points(3), y, x0, y)
+		}
+	}
+}
+
+// DataRange implements plot.DataRanger
+func (lanes gelBands) DataRange() (xmin, xmax, ymin, ymax float64) {
+	xmax = float64(len(lanes))
+	for _, lane := range lanes {
+		ymax = max(ymax, float64(lane.full+lengthExclude))
+	}
+	return
+}
+
+// WriteGel write gel.png and gel.svg of virtual gel, one lane per sample in order of ids, short products at bottom as capillary electrophoresis
+func WriteGel(outputDir string, ids []string, SeqInfoMap map[string]*SeqInfo) error {
+	var (
+		p     = newFigurePlot("virtual gel", "", "length (nt)")
+		lanes gelBands
+		ticks []plot.Tick
+	)
+	for _, id := range ids {
+		var info = SeqInfoMap[id]
+		if info == nil {
+			continue
+		}
+		var lane = &gelLane{histogram: info.Histogram, full: len(info.Seq)}
+		for _, count := range info.Histogram {
+			lane.max = max(lane.max, count)
+		}
+		ticks = append(ticks, plot.Tick{Value: float64(len(lanes)) + 0.5, Label: id})
+		lanes = append(lanes, lane)
+	}
+	if len(lanes) == 0 {
+		return nil
+	}
+	p.Add(lanes)
+	p.X.Tick.Marker = plot.ConstantTicks(ticks)
+	p.X.Tick.Label.Rotation = math.Pi / 2
+	p.X.Tick.Label.XAlign = draw.XRight
+	p.X.Tick.Label.YAlign = draw.YCenter
+	p.Y.Tick.Marker = stepTicks(10)
+
+	var width = max(4*vg.Inch, vg.Length(len(lanes))*vg.Inch/3)
+	for _, ext := range []string{".png", ".svg"} {
+		if err := p.Save(width, 6*vg.Inch, filepath.Join(outputDir, "gel"+ext)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
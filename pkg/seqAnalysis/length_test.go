@@ -0,0 +1,25 @@
+package seqAnalysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewLengthStats(t *testing.T) {
+	var stats = NewLengthStats(map[int]int{30: 6, 29: 2, 20: 2, 10: 0}, 30)
+	if stats.Mode != 30 || math.Abs(stats.Mean-27.8) > 1e-9 || stats.FullLength != 0.6 || stats.Short != 0.2 || stats.N50 != 30 {
+		t.Errorf("NewLengthStats = %+v", stats)
+	}
+	// 众数相同时取较长，N50 按碱基数
+	stats = NewLengthStats(map[int]int{10: 3, 20: 3, 5: 1}, 20)
+	if stats.Mode != 20 || stats.N50 != 20 || stats.FullLength != 3.0/7 {
+		t.Errorf("NewLengthStats tie = %+v", stats)
+	}
+	stats = NewLengthStats(map[int]int{10: 8, 30: 1}, 30)
+	if stats.N50 != 10 {
+		t.Errorf("NewLengthStats N50 = %d, want 10", stats.N50)
+	}
+	if stats = NewLengthStats(nil, 30); *stats != (LengthStats{}) {
+		t.Errorf("NewLengthStats empty = %+v", stats)
+	}
+}
@@ -0,0 +1,278 @@
+package seqAnalysis
+
+import (
+	"strings"
+)
+
+// Locale language of output headers, sheet names and report text, zh (default) or en
+var Locale = "zh"
+
+// Locales supported locales
+var Locales = []string{"zh", "en"}
+
+// en English text of output
+var en = map[string]string{
+	// input columns
+	"样品名称":  "Sample",
+	"靶标序列":  "Index",
+	"合成序列":  "Sequence",
+	"后靶标":   "PostIndex",
+	"路径-R1": "Path-R1",
+	"路径-R2": "Path-R2",
+	"路径":    "Path",
+	"平行":    "Parallel",
+
+	// result
+	"靶标":      "Index",
+	"总数":      "Total",
+	"/个数":     "/Count",
+	"分析reads": "AnalyzedReads",
+	"正确reads": "RightReads",
+	"收率":      "Yield",
+	"平均收率":    "YieldMean",
+	"收率误差":    "YieldSD",
+	"单步准确率":   "StepAccuracy",
+	"平均准确率":   "StepAccuracyMean",
+	"准确率误差":   "StepAccuracySD",
+	"收率平均准确率": "AverageYieldAccuracy",
+	".分析":     ".analysis",
+	"QC说明":    "QCReason",
+	"错误总数":    "ErrorReads",
+	"-CI下限":   "-CILow",
+	"-CI上限":   "-CIHigh",
+
+	// 长度统计
+	"长度众数":    "LengthMode",
+	"平均长度":    "LengthMean",
+	"全长比例":    "FullLengthRatio",
+	"短于n-5比例": "ShorterThanN-5Ratio",
+
+	// pooled.txt
+	"加权平均收率":  "YieldWeightedMean",
+	"加权收率误差":  "YieldWeightedSD",
+	"合并收率":    "PooledYield",
+	"加权平均准确率": "StepAccuracyWeightedMean",
+	"加权准确率误差": "StepAccuracyWeightedSD",
+	"合并单步准确率": "PooledStepAccuracy",
+
+	// coupling.txt
+	"项":    "Term",
+	"碱基":   "Base",
+	"前一碱基": "Prev",
+	"估计值":  "Estimate",
+	"CI下限": "CILow",
+	"CI上限": "CIHigh",
+	"偏低":   "Low",
+	"步数":   "Steps",
+
+	// context.txt
+	"上下文":      "Context",
+	"错误率":      "ErrorRate",
+	"单步错误率均值":  "StepErrorRateMean",
+	"单步错误率标准差": "StepErrorRateSD",
+	"倍数":       "Ratio",
+	"高风险":      "Hot",
+
+	// breakpoint.txt
+	"排名":      "Rank",
+	"断点类型":    "Class",
+	"方向":      "Side",
+	"断点数":     "Sites",
+	"背景频率":    "Background",
+	"期望reads": "ExpectedReads",
+	"富集":      "Enriched",
+
+	// truncation.txt capping.txt
+	"产物":        "Product",
+	"截短reads":   "TruncatedReads",
+	"截短比例":      "TruncatedRatio",
+	"内部缺失reads": "InternalDeletionReads",
+	"内部缺失比例":    "InternalDeletionRatio",
+	"其他reads":   "OtherReads",
+	"其他比例":      "OtherRatio",
+	"加帽效率":      "CappingEfficiency",
+	"批次":        "Batch",
+
+	// contamination.txt
+	"自身%":    "OwnPct",
+	"最高其他设计": "TopOtherDesign",
+	"最高其他%":  "TopOtherPct",
+	"判定":     "Flag",
+	"互换样品":   "SwapWith",
+
+	// unmatched.txt
+	"总reads":   "TotalReads",
+	"未匹配reads": "UnmatchedReads",
+	"前缀":       "Prefix",
+	"占未匹配比例":   "UnmatchedRatio",
+	"最近靶标":     "NearestIndexSample",
+	"编辑距离":     "EditDistance",
+	"反向互补距离":   "RCDistance",
+	"反向互补":     "RC",
+	"接头":       "Adapter",
+
+	// predictYield
+	"预测收率":    "PredictedYield",
+	"最高风险位置":  "RiskiestPosition",
+	"主要失败产物":  "TopFailureProducts",
+	"风险motif": "RiskyMotifs",
+	"累计收率":    "CumulativeYield",
+	"缺失位置":    "DeletionPositions",
+	"产物序列":    "ProductSeq",
+
+	// compare.txt
+	"检验":  "Test",
+	"指标":  "Metric",
+	"组1":  "Group1",
+	"组2":  "Group2",
+	"值1":  "Value1",
+	"值2":  "Value2",
+	"统计量": "Statistic",
+	"自由度": "DF",
+	"校正P": "PAdj",
+	"显著":  "Significant",
+
+	// report.html
+	"分析报告":   "Analysis Report",
+	"单步错误率":  "Step Error Rate",
+	"收率衰减":   "Yield Decay",
+	"长度分布":   "Length Distribution",
+	"缺失分布":   "Deletion Distribution",
+	"插入分布":   "Insertion Distribution",
+	"突变分布":   "Mutation Distribution",
+	"平行组比较":  "Parallel Group Comparison",
+	"合成位置":   "Position",
+	"长度":     "Length",
+	"reads数": "Reads",
+	"比例":     "Ratio",
+
+	// Charts
+	"缺失":    "Deletion",
+	"插入":    "Insertion",
+	"插入+缺失": "InsertionDeletion",
+	"突变":    "Mutation",
+	"其他错误":  "OtherError",
+	"错误分类":  "Error Class",
+	"位置错误率": "Position Error Rate",
+
+	// 单步错误率
+	"单步错误率-横排": "StepErrorRate",
+	"名字":       "Name",
+	"合成前4nt-":  "Upstream4nt-",
+	"合成碱基-":    "Base-",
+	"合成位置-":    "Position-",
+	"单步错误率-":   "StepErrorRate-",
+
+	// RunInfo
+	"版本":   "Version",
+	"提交":   "Commit",
+	"命令行":  "CommandLine",
+	"工作目录": "WorkDir",
+	"主机":   "Host",
+	"开始时间": "StartTime",
+	"结束时间": "EndTime",
+	"输入":   "Input",
+	"参数":   "Option",
+	"值":    "Value",
+	"配置文件": "Config",
+	"样品":   "Sample",
+	"输出文件": "OutputFile",
+
+	// validate
+	"输入检查通过":                  "input check passed",
+	"级别\t行号\t列\t问题":           "Level\tRow\tColumn\tProblem",
+	"无法打开: ":                  "cannot open: ",
+	"缺少 Summary 或 Sheet1 工作表": "missing sheet Summary or Sheet1",
+	"没有样品行":                   "no sample row",
+	"缺少必需列":                   "missing required column",
+	"输入文件不存在: ":               "input not found: ",
+	"没有样品":                    "no sample",
+	"样品名称为空":                  "empty sample name",
+	"样品名称重复: %s (与第%d行)":      "duplicate sample id: %s (same as row %d)",
+	"样品名称含文件名非法字符: %s":        "sample id contains invalid filename character: %s",
+	"序列为空":                    "empty sequence",
+	"含非法字符 %s, 只允许A/C/G/T及IUPAC简并碱基": "invalid character %s, only A/C/G/T and IUPAC codes are allowed",
+	"含简并碱基, 未开启 -iupac 时按原样匹配":       "contains IUPAC codes, matched literally without -iupac",
+	"参数值非法: %v":               "invalid option value: %v",
+	"同一样品重复使用fastq: %s":       "fastq used twice by the same sample: %s",
+	"与其他样品共用fastq且靶标序列相同: %s": "fastq shared with another sample of the same index: %s",
+	"fastq不可读: %v":            "fastq not readable: %v",
+	"未提供fastq":                "no fastq",
+	"fastq被%d个样品共用(行 %s): %s": "fastq shared by %d samples (rows %s): %s",
+	"匹配到%d个fastq, 全部使用: %s":   "%d fastq matched, use all: %s",
+	"未找到匹配的fastq":             "no fastq matched",
+	"fastq查找不完整: ":            "fastq discovery incomplete: ",
+	"匹配数":                     "Matches",
+	"文件":                      "Files",
+	// 单样品 xlsx 拆分
+	"%s 超出单表 %d 行，": "%s exceeds %d rows per sheet, ",
+	"续表: %s":        "continued in sheets: %s",
+	"其余 %d 行见 %s":   "other %d rows in %s",
+	"；":             "; ",
+}
+
+// L translate Chinese text to Locale, keep text without translation
+func L(s string) string {
+	if Locale == "en" {
+		if t, ok := en[s]; ok {
+			return t
+		}
+	}
+	return s
+}
+
+// LocaleFile localized name of etc file, e.g. title.Tar.txt -> title.Tar.en.txt, empty for default locale
+func LocaleFile(name string) string {
+	if Locale == "" || Locale == "zh" {
+		return ""
+	}
+	var i = strings.LastIndex(name, ".")
+	if i < 0 {
+		return name + "." + Locale
+	}
+	return name[:i] + "." + Locale + name[i:]
+}
+
+// InputColumnAlias English aliases of input columns, lower case
+var InputColumnAlias = map[string]string{
+	"sample":             "样品名称",
+	"sample name":        "样品名称",
+	"samplename":         "样品名称",
+	"sample id":          "样品名称",
+	"index":              "靶标序列",
+	"index sequence":     "靶标序列",
+	"target":             "靶标序列",
+	"sequence":           "合成序列",
+	"synthesis sequence": "合成序列",
+	"seq":                "合成序列",
+	"path-r1":            "路径-R1",
+	"path r1":            "路径-R1",
+	"fastq-r1":           "路径-R1",
+	"r1":                 "路径-R1",
+	"path-r2":            "路径-R2",
+	"path r2":            "路径-R2",
+	"fastq-r2":           "路径-R2",
+	"r2":                 "路径-R2",
+	"postindex":          "后靶标",
+	"post index":         "后靶标",
+	"post-index":         "后靶标",
+	"parallel":           "平行",
+	"replicate":          "平行",
+}
+
+// CanonicalColumn Chinese column name of input column alias
+func CanonicalColumn(name string) string {
+	if c, ok := InputColumnAlias[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return c
+	}
+	return name
+}
+
+// CanonicalColumns CanonicalColumn of each title
+func CanonicalColumns(title []string) []string {
+	var columns = make([]string, len(title))
+	for i, v := range title {
+		columns[i] = CanonicalColumn(v)
+	}
+	return columns
+}
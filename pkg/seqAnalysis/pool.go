@@ -0,0 +1,244 @@
+package seqAnalysis
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+)
+
+// PooledSuffix suffix of name of pooled sample of 平行 group
+const PooledSuffix = ".pooled"
+
+// Pool 平行组 reads 合并的虚拟样品，各平行 HitSeqCount、Stats、DistributionNum 相加
+type Pool struct {
+	ID         string
+	Replicates []string
+	Info       *SeqInfo
+
+	mu sync.Mutex
+}
+
+// NewPool pool of replicates, which must have the same 靶标、合成序列 and direction
+func NewPool(id string, replicates []*SeqInfo, outputDir string) (*Pool, error) {
+	if len(replicates) == 0 {
+		return nil, fmt.Errorf("no replicate of %s", id)
+	}
+	var first = replicates[0]
+	var pool = &Pool{
+		ID: id,
+		Info: &SeqInfo{
+			Name:           id + PooledSuffix,
+			ParallelTestID: id,
+			Excel:          filepath.Join(outputDir, id+PooledSuffix+".xlsx"),
+			IndexSeq:       first.IndexSeq,
+			PostSeq:        first.PostSeq,
+			Seq:            first.Seq,
+			Reverse:        first.Reverse,
+			Sheets:         first.Sheets,
+
+			Stats:                    make(map[string]int),
+			HitSeqCount:              make(map[string]int),
+			Histogram:                make(map[int]int),
+			DeletionContinuous3Index: len(first.Seq),
+		},
+	}
+	for _, info := range replicates {
+		if info.IndexSeq != first.IndexSeq || string(info.Seq) != string(first.Seq) || info.Reverse != first.Reverse {
+			return nil, fmt.Errorf("replicate %s of %s differ from %s in index, seq or rev", info.Name, id, first.Name)
+		}
+		pool.Replicates = append(pool.Replicates, info.Name)
+		pool.Info.Fastqs = append(pool.Info.Fastqs, info.Fastqs...)
+	}
+	for j := 0; j < 4; j++ {
+		pool.Info.DistributionNum[j] = make([]int, len(first.Seq))
+		pool.Info.DistributionFreq[j] = make([]float64, len(first.Seq))
+	}
+	return pool, nil
+}
+
+// Merge add counts of replicate info, after CountError4 and before WriteStatsSheet free HitSeqCount
+func (pool *Pool) Merge(info *SeqInfo) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var p = pool.Info
+	for seq, count := range info.HitSeqCount {
+		p.HitSeqCount[seq] += count
+	}
+	for key, count := range info.Stats {
+		p.Stats[key] += count
+	}
+	for j := 0; j < 4; j++ {
+		for i := 0; i < len(p.Seq) && i < len(info.DistributionNum[j]); i++ {
+			p.DistributionNum[j][i] += info.DistributionNum[j][i]
+		}
+	}
+	for length, count := range info.Histogram {
+		p.Histogram[length] += count
+	}
+	p.AllReadsNum += info.AllReadsNum
+	p.IndexReadsNum += info.IndexReadsNum
+	p.IndexPolyAReadsNum += info.IndexPolyAReadsNum
+	p.RightReadsNum += info.RightReadsNum
+	p.ExcludeReadsNum += info.ExcludeReadsNum
+	p.DeletionContinuous3Index = min(p.DeletionContinuous3Index, info.DeletionContinuous3Index)
+}
+
+// Run write Stats sheet, [id].pooled.steps.txt and [id].pooled.result.json of merged counts
+func (pool *Pool) Run(resultDir string, TitleTar, TitleStats []string) error {
+	var p = pool.Info
+	slog.Info("Pool", "id", pool.ID, "replicates", pool.Replicates, "AnalyzedReadsNum", p.Stats["AnalyzedReadsNum"])
+
+	// 派生计数由合并后的计数重新计算
+	p.Stats["AccuRightNum"] = 0
+	p.UpdateDistributionStats()
+
+	p.xlsx = excelize.NewFile()
+	p.Style = map[string]int{
+		"center": simpleUtil.HandleError(p.xlsx.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{Horizontal: "center"}})),
+	}
+	simpleUtil.CheckErr(p.xlsx.SetSheetName("Sheet1", p.Sheets["Stats"]))
+	simpleUtil.CheckErr(p.xlsx.SetColWidth(p.Sheets["Stats"], "M", "R", 12))
+	simpleUtil.CheckErr(p.xlsx.SetColWidth(p.Sheets["Stats"], "S", "S", 14))
+
+	p.WriteStatsSheet(resultDir, TitleTar, TitleStats)
+	slog.Info("save xlsx", slog.Group("seqInfo", "name", p.Name, "path", p.Excel))
+	simpleUtil.CheckErr(p.xlsx.SaveAs(p.Excel))
+	p.xlsx = nil
+	if err := p.WriteResultJSON(resultDir); err != nil {
+		return fmt.Errorf("write result.json of %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// WeightedMeanSD mean and SD of values weighted by depths, SD with reliability weights, 0 for single value,
+// equal weights if depths missing or all 0
+func WeightedMeanSD(values []float64, depths []int) (mean, sd float64) {
+	var weights = make([]float64, len(values))
+	var sumW, sumW2 float64
+	for i := range values {
+		if i < len(depths) {
+			weights[i] = float64(depths[i])
+		}
+		sumW += weights[i]
+	}
+	if sumW == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		sumW = float64(len(weights))
+	}
+	if sumW == 0 {
+		return 0, 0
+	}
+	for i, v := range values {
+		sumW2 += weights[i] * weights[i]
+		mean += weights[i] * v
+	}
+	mean /= sumW
+	var denom = sumW - sumW2/sumW
+	if denom <= 0 {
+		return mean, 0
+	}
+	var ss float64
+	for i, v := range values {
+		ss += weights[i] * (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(ss / denom)
+}
+
+// pooledTitle titles of pooled.txt and Pooled sheet
+func pooledTitle() []string {
+	return []string{
+		L("平行"), L("样品"), L("分析reads"),
+		L("平均收率"), L("收率误差"), L("加权平均收率"), L("加权收率误差"),
+		L("合并收率"), L("合并收率") + L("-CI下限"), L("合并收率") + L("-CI上限"),
+		L("平均准确率"), L("准确率误差"), L("加权平均准确率"), L("加权准确率误差"),
+		L("合并单步准确率"), L("合并单步准确率") + L("-CI下限"), L("合并单步准确率") + L("-CI上限"),
+	}
+}
+
+// pooledIDs ids of groups with pooled sample, sorted
+func pooledIDs(ParallelStatsMap map[string]*ParallelTest) (ids []string) {
+	for id, p := range ParallelStatsMap {
+		if p.Pooled != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return
+}
+
+// PooledRow values of group with pooled sample, same order as pooledTitle
+func (p *ParallelTest) PooledRow() []any {
+	var (
+		info = p.Pooled
+		ci   = info.CI
+	)
+	if ci == nil {
+		ci = info.ConfidenceIntervals()
+	}
+	return []any{
+		p.ID, strings.Join(p.Samples, ","), info.Stats["AnalyzedReadsNum"],
+		p.YieldCoefficientMean, p.YieldCoefficientSD, p.YieldCoefficientWeightedMean, p.YieldCoefficientWeightedSD,
+		info.YieldCoefficient, ci.Yield.Low, ci.Yield.High,
+		p.AverageYieldAccuracyMean, p.AverageYieldAccuracySD, p.AverageYieldAccuracyWeightedMean, p.AverageYieldAccuracyWeightedSD,
+		info.AverageYieldAccuracy, ci.AverageYieldAccuracy.Low, ci.AverageYieldAccuracy.High,
+	}
+}
+
+// WritePooledTxt write pooled.txt of groups with pooled sample, skip if none
+func WritePooledTxt(resultDir string, ParallelStatsMap map[string]*ParallelTest) {
+	var ids = pooledIDs(ParallelStatsMap)
+	if len(ids) == 0 {
+		return
+	}
+	var file = osUtil.Create(filepath.Join(resultDir, "pooled.txt"))
+	defer simpleUtil.DeferClose(file)
+	fmtUtil.FprintStringArray(file, pooledTitle(), "\t")
+	for _, id := range ids {
+		var row []string
+		for _, v := range ParallelStatsMap[id].PooledRow() {
+			switch v := v.(type) {
+			case float64:
+				row = append(row, formatFloat(v))
+			default:
+				row = append(row, fmt.Sprint(v))
+			}
+		}
+		fmtUtil.FprintStringArray(file, row, "\t")
+	}
+}
+
+// AddPooled2Sheet add Pooled sheet of groups with pooled sample to summary.xlsx, link to [id].pooled.xlsx
+func AddPooled2Sheet(excel *excelize.File, ParallelStatsMap map[string]*ParallelTest) {
+	var ids = pooledIDs(ParallelStatsMap)
+	if len(ids) == 0 {
+		return
+	}
+	var sheetName = "Pooled"
+	simpleUtil.HandleError(excel.NewSheet(sheetName))
+	simpleUtil.CheckErr(excel.SetColWidth(sheetName, "A", "B", 20))
+	var title []any
+	for _, s := range pooledTitle() {
+		title = append(title, s)
+	}
+	SetRow(excel, sheetName, 1, 1, title)
+	for i, id := range ids {
+		var (
+			info     = ParallelStatsMap[id].Pooled
+			cellName = simpleUtil.HandleError(excelize.CoordinatesToCellName(1, i+2))
+		)
+		SetRow(excel, sheetName, 1, i+2, ParallelStatsMap[id].PooledRow())
+		simpleUtil.CheckErr(excel.SetCellHyperLink(sheetName, cellName, info.Name+".xlsx", "External"))
+	}
+}
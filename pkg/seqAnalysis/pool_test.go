@@ -0,0 +1,66 @@
+package seqAnalysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedMeanSD(t *testing.T) {
+	// 等权时同样本标准差
+	var mean, sd = WeightedMeanSD([]float64{1, 3}, []int{5, 5})
+	if mean != 2 || math.Abs(sd-math.Sqrt2) > 1e-12 {
+		t.Errorf("WeightedMeanSD equal weights = %v %v", mean, sd)
+	}
+	mean, sd = WeightedMeanSD([]float64{1, 3}, []int{1, 3})
+	if mean != 2.5 || math.Abs(sd-math.Sqrt2) > 1e-12 {
+		t.Errorf("WeightedMeanSD = %v %v", mean, sd)
+	}
+	if mean, sd = WeightedMeanSD([]float64{0.5}, []int{100}); mean != 0.5 || sd != 0 {
+		t.Errorf("WeightedMeanSD single = %v %v", mean, sd)
+	}
+	if mean, sd = WeightedMeanSD([]float64{1, 3}, nil); mean != 2 || math.Abs(sd-math.Sqrt2) > 1e-12 {
+		t.Errorf("WeightedMeanSD without depths = %v %v", mean, sd)
+	}
+}
+
+func TestPoolMerge(t *testing.T) {
+	var replicate = func(name string, seq string, right, analyzed int) *SeqInfo {
+		var info = &SeqInfo{
+			Name:        name,
+			Seq:         []byte(seq),
+			Stats:       map[string]int{"AnalyzedReadsNum": analyzed, "Deletion": analyzed - right},
+			HitSeqCount: map[string]int{seq: right, seq[1:]: analyzed - right},
+			Histogram:   map[int]int{len(seq): right},
+
+			RightReadsNum:            right,
+			DeletionContinuous3Index: len(seq),
+		}
+		for j := range info.DistributionNum {
+			info.DistributionNum[j] = make([]int, len(seq))
+		}
+		info.DistributionNum[0][0] = analyzed - right
+		return info
+	}
+	var (
+		a = replicate("a", "ACGT", 80, 100)
+		b = replicate("b", "ACGT", 30, 50)
+	)
+	if _, err := NewPool("x", []*SeqInfo{a, replicate("c", "ACGA", 1, 1)}, ""); err == nil {
+		t.Errorf("NewPool of different seq should fail")
+	}
+	var pool, err = NewPool("x", []*SeqInfo{a, b}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Merge(a)
+	pool.Merge(b)
+	var p = pool.Info
+	if p.Name != "x"+PooledSuffix || p.Stats["AnalyzedReadsNum"] != 150 || p.RightReadsNum != 110 ||
+		p.HitSeqCount["ACGT"] != 110 || p.HitSeqCount["CGT"] != 40 || p.DistributionNum[0][0] != 40 || p.Histogram[4] != 110 {
+		t.Errorf("Merge = %+v", p)
+	}
+	p.UpdateDistributionStats()
+	if p.Stats["ErrorReadsNum"] != 40 || p.DistributionNum[3][0] != 110 {
+		t.Errorf("UpdateDistributionStats of pool = %v %v", p.Stats, p.DistributionNum)
+	}
+}
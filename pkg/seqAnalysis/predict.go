@@ -0,0 +1,427 @@
+package seqAnalysis
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+// StepErrorModel single step error rate learned from past runs
+type StepErrorModel interface {
+	// StepErrorRate error rate of adding base after upstream, upstream may be shorter than context of model
+	StepErrorRate(upstream string, base byte) float64
+	// GlobalErrorRate mean step error rate of model
+	GlobalErrorRate() float64
+	// RiskyMotifs upstream+base of significantly high error rate
+	RiskyMotifs() []string
+}
+
+// StepErrorRate mean step error rate of longest context of model matching upstream, back off to shorter context
+func (model *ContextModel) StepErrorRate(upstream string, base byte) float64 {
+	for k := min(model.ContextLength, len(upstream)); k >= 0; k-- {
+		var (
+			suffix = upstream[len(upstream)-k:] + string(base)
+			sum    float64
+			steps  int
+		)
+		for key, stat := range model.Contexts {
+			if strings.HasSuffix(key, suffix) {
+				sum += stat.RateSum
+				steps += stat.Steps
+			}
+		}
+		if steps > 0 {
+			return sum / float64(steps)
+		}
+	}
+	return model.GlobalRate()
+}
+
+// GlobalErrorRate GlobalRate of ContextModel
+func (model *ContextModel) GlobalErrorRate() float64 {
+	return model.GlobalRate()
+}
+
+// RiskyMotifs hot contexts of Report
+func (model *ContextModel) RiskyMotifs() (motifs []string) {
+	for _, report := range model.Report(CompareAlpha) {
+		if report.Hot {
+			motifs = append(motifs, report.Context+report.Base)
+		}
+	}
+	return
+}
+
+// StepErrorRate 1 - efficiency of pair of prev and base, base efficiency if pair not fitted or not converged
+func (model *CouplingModel) StepErrorRate(upstream string, base byte) float64 {
+	if n := len(upstream); n > 0 {
+		for _, e := range model.Pairs {
+			if e.Converged && e.Prev == upstream[n-1:] && e.Base == string(base) {
+				return 1 - e.Estimate
+			}
+		}
+	}
+	for _, e := range model.Bases {
+		if e.Converged && e.Base == string(base) {
+			return 1 - e.Estimate
+		}
+	}
+	return model.GlobalErrorRate()
+}
+
+// GlobalErrorRate 1 - reads weighted mean efficiency of bases
+func (model *CouplingModel) GlobalErrorRate() float64 {
+	var sum, reads float64
+	for _, e := range model.Bases {
+		if e.Converged {
+			sum += e.Estimate * float64(e.Reads)
+			reads += float64(e.Reads)
+		}
+	}
+	if reads == 0 {
+		return math.NaN()
+	}
+	return 1 - sum/reads
+}
+
+// RiskyMotifs flagged pairs
+func (model *CouplingModel) RiskyMotifs() (motifs []string) {
+	for _, e := range model.Pairs {
+		if e.Flag {
+			motifs = append(motifs, e.Prev+e.Base)
+		}
+	}
+	return
+}
+
+// LoadCouplingModel coupling of batch.json
+func LoadCouplingModel(path string) (*CouplingModel, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result BatchResult
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid batch.json %s: %w", path, err)
+	}
+	if result.Coupling == nil || len(result.Coupling.Bases) == 0 {
+		return nil, fmt.Errorf("%w: no coupling in %s", ErrCouplingData, path)
+	}
+	// 1.6 前无 converged，估计值均有限
+	if minor, _ := strconv.Atoi(strings.TrimPrefix(result.SchemaVersion, "1.")); minor < 6 {
+		for _, e := range result.Coupling.Estimates() {
+			e.Converged = true
+		}
+	}
+	return result.Coupling, nil
+}
+
+// Candidate candidate synthesis sequence, Upstream is 靶标序列 before it
+type Candidate struct {
+	ID       string
+	Seq      string
+	Upstream string
+}
+
+// LoadCandidates candidates from FASTA, or TSV with title of 样品名称/id, 合成序列/seq and optional 靶标序列/index
+func LoadCandidates(r io.Reader) (candidates []*Candidate, err error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		title   []string
+		fasta   *Candidate
+	)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var line = strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, ">") {
+			fasta = &Candidate{ID: strings.Fields(line[1:] + " ")[0]}
+			candidates = append(candidates, fasta)
+			continue
+		}
+		if fasta != nil {
+			fasta.Seq += strings.ToUpper(strings.TrimSpace(line))
+			continue
+		}
+		var cols = strings.Split(line, "\t")
+		if title == nil {
+			title = CanonicalColumns(cols)
+			continue
+		}
+		var c = &Candidate{}
+		for i, t := range title {
+			if i >= len(cols) {
+				break
+			}
+			switch strings.ToLower(t) {
+			case "样品名称", "id", "name":
+				c.ID = strings.TrimSpace(cols[i])
+			case "合成序列":
+				c.Seq = strings.ToUpper(strings.TrimSpace(cols[i]))
+			case "靶标序列":
+				c.Upstream = strings.ToUpper(strings.TrimSpace(cols[i]))
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	for i, c := range candidates {
+		if c.ID == "" {
+			c.ID = "seq" + strconv.Itoa(i+1)
+		}
+		if c.Seq == "" {
+			return nil, fmt.Errorf("empty sequence of %s", c.ID)
+		}
+	}
+	return
+}
+
+// PredictOptions options of Predict
+type PredictOptions struct {
+	// 同一碱基连续 ≥ Homopolymer nt 标记
+	Homopolymer int
+	// 单步错误率 ≥ RiskRatio × 模型均值 标记高风险位置
+	RiskRatio float64
+	// 输出失败产物数
+	Products int
+	// 额外风险 motif
+	Motifs []string
+	// 标记内置已知风险 motif KnownRiskyMotifs
+	KnownMotifs bool
+}
+
+// DefaultPredictOptions default PredictOptions
+var DefaultPredictOptions = PredictOptions{Homopolymer: 5, RiskRatio: 2, Products: 3, KnownMotifs: true}
+
+// KnownRiskyMotifs built-in known problem 4-mers of oligo synthesis:
+// G4/C4 易形成 G-四链体或聚集、偶联效率低，GC 回文易形成发夹
+var KnownRiskyMotifs = []string{"GGGG", "CCCC", "GCGC", "CGCG", "GGCC", "CCGG"}
+
+// MaxStepErrorRate upper bound of predicted step error rate, keep 1-e and e/(1-e) finite
+const MaxStepErrorRate = 0.999
+
+// PositionRisk predicted step of one position
+type PositionRisk struct {
+	Pos     int
+	Base    string
+	Context string
+	// 单步错误率
+	ErrorRate float64
+	// 单步错误率 / 模型均值
+	Ratio float64
+	// 累计收率
+	Yield float64
+	Risky bool
+}
+
+// FailureProduct product missing one base, deletions in homopolymer give the same product
+type FailureProduct struct {
+	Seq       string
+	Positions []int
+	// 占全部产物比例
+	Fraction float64
+}
+
+// Prediction predicted yield of Candidate
+type Prediction struct {
+	*Candidate
+	Yield        float64
+	StepAccuracy float64
+	Positions    []*PositionRisk
+	Products     []*FailureProduct
+	Motifs       []string
+}
+
+// Predict predict yield, position risks, most common n-1 products and risky motifs of candidate
+func Predict(c *Candidate, model StepErrorModel, opts PredictOptions, riskyMotifs []string) *Prediction {
+	var (
+		prediction = &Prediction{Candidate: c, Yield: 1}
+		full       = c.Upstream + c.Seq
+		global     = model.GlobalErrorRate()
+		products   = make(map[string]*FailureProduct)
+	)
+	for i := range c.Seq {
+		var (
+			end      = len(c.Upstream) + i
+			upstream = full[max(0, end-MaxContextLength):end]
+			rate     = min(max(model.StepErrorRate(upstream, c.Seq[i]), 0), MaxStepErrorRate)
+		)
+		prediction.Yield *= 1 - rate
+		var risk = &PositionRisk{
+			Pos: i + 1, Base: c.Seq[i : i+1], Context: upstream, ErrorRate: rate,
+			Ratio: rate / global, Yield: prediction.Yield,
+		}
+		risk.Risky = risk.Ratio >= opts.RiskRatio
+		prediction.Positions = append(prediction.Positions, risk)
+	}
+	prediction.StepAccuracy = math.Pow(prediction.Yield, 1/float64(max(1, len(c.Seq))))
+
+	// n-1 产物：仅第 i 步失败，占比 = 收率 × e/(1-e)
+	for _, risk := range prediction.Positions {
+		var (
+			i   = risk.Pos - 1
+			seq = c.Seq[:i] + c.Seq[i+1:]
+			p   = products[seq]
+		)
+		if p == nil {
+			p = &FailureProduct{Seq: seq}
+			products[seq] = p
+		}
+		p.Positions = append(p.Positions, risk.Pos)
+		p.Fraction += prediction.Yield * risk.ErrorRate / (1 - risk.ErrorRate)
+	}
+	for _, p := range products {
+		prediction.Products = append(prediction.Products, p)
+	}
+	sort.Slice(prediction.Products, func(i, j int) bool {
+		if prediction.Products[i].Fraction != prediction.Products[j].Fraction {
+			return prediction.Products[i].Fraction > prediction.Products[j].Fraction
+		}
+		return prediction.Products[i].Positions[0] < prediction.Products[j].Positions[0]
+	})
+	prediction.Products = prediction.Products[:min(opts.Products, len(prediction.Products))]
+
+	var motifs = append(append([]string{}, riskyMotifs...), opts.Motifs...)
+	if opts.KnownMotifs {
+		motifs = append(motifs, KnownRiskyMotifs...)
+	}
+	prediction.Motifs = FindMotifs(c, opts.Homopolymer, motifs)
+	return prediction
+}
+
+// FindMotifs homopolymer runs of seq ≥ homopolymer nt as poly[base]([length])@[pos],
+// and motifs ending in seq (may start in upstream) as [motif]@[pos of last base],
+// duplicated motifs and motif matches inside reported homopolymer runs skipped
+func FindMotifs(c *Candidate, homopolymer int, motifs []string) (found []string) {
+	// inRun[i]: c.Seq[i] 属于已报告均聚物
+	var inRun = make([]bool, len(c.Seq))
+	if homopolymer > 1 {
+		for i := 0; i < len(c.Seq); {
+			var j = i
+			for j < len(c.Seq) && c.Seq[j] == c.Seq[i] {
+				j++
+			}
+			if j-i >= homopolymer {
+				found = append(found, fmt.Sprintf("poly%c(%d)@%d", c.Seq[i], j-i, i+1))
+				for k := i; k < j; k++ {
+					inRun[k] = true
+				}
+			}
+			i = j
+		}
+	}
+	var (
+		full = c.Upstream + c.Seq
+		seen = make(map[string]bool)
+	)
+	for _, motif := range motifs {
+		motif = strings.ToUpper(motif)
+		if motif == "" || seen[motif] {
+			continue
+		}
+		seen[motif] = true
+		for i := 0; i+len(motif) <= len(full); i++ {
+			var (
+				last  = i + len(motif) - len(c.Upstream)
+				first = i - len(c.Upstream)
+			)
+			if first >= 0 && inRun[first] && inRun[last-1] && c.Seq[first] == c.Seq[last-1] &&
+				strings.Count(motif, motif[:1]) == len(motif) {
+				continue
+			}
+			if last >= 1 && full[i:i+len(motif)] == motif {
+				found = append(found, fmt.Sprintf("%s@%d", motif, last))
+			}
+		}
+	}
+	return
+}
+
+// predictionTitle titles of [prefix].summary.txt
+func predictionTitle() []string {
+	return []string{
+		L("样品名称"), L("合成序列"), L("长度"), L("预测收率"), L("单步准确率"),
+		L("最高风险位置"), L("主要失败产物"), L("风险motif"),
+	}
+}
+
+// Row values of Prediction
+func (prediction *Prediction) Row() []string {
+	var (
+		worst    *PositionRisk
+		products []string
+	)
+	for _, risk := range prediction.Positions {
+		if worst == nil || risk.ErrorRate > worst.ErrorRate {
+			worst = risk
+		}
+	}
+	for _, p := range prediction.Products {
+		var positions []string
+		for _, pos := range p.Positions {
+			positions = append(positions, strconv.Itoa(pos))
+		}
+		products = append(products, fmt.Sprintf("del%s:%s", strings.Join(positions, "/"), formatFloat(p.Fraction)))
+	}
+	var worstText = ""
+	if worst != nil {
+		worstText = fmt.Sprintf("%d%s:%s", worst.Pos, worst.Base, formatFloat(worst.ErrorRate))
+	}
+	return []string{
+		prediction.ID, prediction.Seq, strconv.Itoa(len(prediction.Seq)),
+		formatFloat(prediction.Yield), formatFloat(prediction.StepAccuracy),
+		worstText, strings.Join(products, ";"), strings.Join(prediction.Motifs, ";"),
+	}
+}
+
+// WritePredictions write [prefix].summary.txt, [prefix].positions.txt and [prefix].products.txt
+func WritePredictions(prefix string, predictions []*Prediction) {
+	var (
+		summary   = osUtil.Create(prefix + ".summary.txt")
+		positions = osUtil.Create(prefix + ".positions.txt")
+		products  = osUtil.Create(prefix + ".products.txt")
+	)
+	defer simpleUtil.DeferClose(summary)
+	defer simpleUtil.DeferClose(positions)
+	defer simpleUtil.DeferClose(products)
+
+	fmtUtil.FprintStringArray(summary, predictionTitle(), "\t")
+	fmtUtil.FprintStringArray(positions, []string{
+		L("样品名称"), L("合成位置"), L("碱基"), L("上下文"), L("单步错误率"), L("倍数"), L("累计收率"), L("高风险"),
+	}, "\t")
+	fmtUtil.FprintStringArray(products, []string{L("样品名称"), L("缺失位置"), L("比例"), L("产物序列")}, "\t")
+	for _, prediction := range predictions {
+		fmtUtil.FprintStringArray(summary, prediction.Row(), "\t")
+		for _, risk := range prediction.Positions {
+			var risky = ""
+			if risk.Risky {
+				risky = "*"
+			}
+			fmtUtil.FprintStringArray(positions, []string{
+				prediction.ID, strconv.Itoa(risk.Pos), risk.Base, risk.Context,
+				formatFloat(risk.ErrorRate), formatFloat(risk.Ratio), formatFloat(risk.Yield), risky,
+			}, "\t")
+		}
+		for _, p := range prediction.Products {
+			var pos []string
+			for _, v := range p.Positions {
+				pos = append(pos, strconv.Itoa(v))
+			}
+			fmtUtil.FprintStringArray(products, []string{prediction.ID, strings.Join(pos, ","), formatFloat(p.Fraction), p.Seq}, "\t")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package seqAnalysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoadCandidates(t *testing.T) {
+	var candidates, err = LoadCandidates(strings.NewReader(">a desc\nacgt\nAA\n>b\nTTT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || candidates[0].ID != "a" || candidates[0].Seq != "ACGTAA" || candidates[1].Seq != "TTT" {
+		t.Errorf("LoadCandidates fasta = %+v", candidates)
+	}
+	candidates, err = LoadCandidates(strings.NewReader("sample\tindex\tseq\nx\tCTGA\tacg\n\tC\tT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || *candidates[0] != (Candidate{"x", "ACG", "CTGA"}) || candidates[1].ID != "seq2" {
+		t.Errorf("LoadCandidates tsv = %+v %+v", candidates[0], candidates[1])
+	}
+	if _, err = LoadCandidates(strings.NewReader("id\tseq\nx\t\n")); err == nil {
+		t.Errorf("empty sequence should fail")
+	}
+}
+
+func TestPredict(t *testing.T) {
+	var model, _ = NewContextModel(1)
+	var records []*ErrRateRecord
+	for i := range 4 {
+		// A>G 10%，其余 1%
+		for _, key := range []string{"AG", "AC", "CA", "GA", "GG", "CC", "AA", "TT"} {
+			var rate = 1.0
+			if key == "AG" {
+				rate = 10
+			}
+			records = append(records, &ErrRateRecord{ID: "s", Context: key[:1], Base: key[1], Pos: i, ErrRate: rate})
+		}
+	}
+	if err := model.AddRecords("a", records, false); err != nil {
+		t.Fatal(err)
+	}
+	// 未见上下文回退到碱基
+	if rate := model.StepErrorRate("T", 'G'); math.Abs(rate-0.055) > 1e-12 {
+		t.Errorf("StepErrorRate backoff = %v", rate)
+	}
+
+	var (
+		c          = &Candidate{ID: "x", Seq: "AGGGGGC", Upstream: "C"}
+		prediction = Predict(c, model, DefaultPredictOptions, []string{"AG"})
+		want       = 0.99 * 0.9 * math.Pow(0.99, 4) * 0.99
+	)
+	if math.Abs(prediction.Yield-want) > 1e-12 || !prediction.Positions[1].Risky || prediction.Positions[2].Risky {
+		t.Errorf("Predict = %v %+v", prediction.Yield, prediction.Positions[1])
+	}
+	// 均聚物内缺失产物相同，合并后比例最高
+	var top = prediction.Products[0]
+	if top.Seq != "AGGGGC" || len(top.Positions) != 5 ||
+		math.Abs(top.Fraction-want*(0.1/0.9+4*0.01/0.99)) > 1e-12 {
+		t.Errorf("Products = %+v", top)
+	}
+	if strings.Join(prediction.Motifs, ";") != "polyG(5)@2;AG@2" {
+		t.Errorf("Motifs = %v", prediction.Motifs)
+	}
+}
+
+// constModel same step error rate of all positions
+type constModel float64
+
+func (m constModel) StepErrorRate(string, byte) float64 { return float64(m) }
+func (m constModel) GlobalErrorRate() float64           { return float64(m) }
+func (m constModel) RiskyMotifs() []string              { return nil }
+
+func TestPredictClamp(t *testing.T) {
+	var prediction = Predict(&Candidate{ID: "x", Seq: "ACGT"}, constModel(1), DefaultPredictOptions, nil)
+	if prediction.Yield <= 0 || math.IsInf(prediction.Products[0].Fraction, 0) || math.IsNaN(prediction.Products[0].Fraction) {
+		t.Errorf("Predict with error rate 1 = %v %+v", prediction.Yield, prediction.Products[0])
+	}
+}
+
+func TestFindMotifsKnown(t *testing.T) {
+	var c = &Candidate{ID: "x", Seq: "TGGGGATTTTTTGCGC", Upstream: "CC"}
+	var found = FindMotifs(c, 5, append([]string{"gggg", "TTTT"}, KnownRiskyMotifs...))
+	if got := strings.Join(found, ";"); got != "polyT(6)@7;GGGG@5;GCGC@16" {
+		t.Errorf("FindMotifs = %s", got)
+	}
+	var opts = DefaultPredictOptions
+	opts.KnownMotifs = false
+	if prediction := Predict(c, constModel(0.01), opts, nil); strings.Join(prediction.Motifs, ";") != "polyT(6)@7" {
+		t.Errorf("Predict without known motifs = %v", prediction.Motifs)
+	}
+}
@@ -0,0 +1,236 @@
+package seqAnalysis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+)
+
+// EtcFiles config files loaded from etc/
+var EtcFiles = []string{"sheet.txt", "title.Tar.txt", "title.Stats.txt", "title.Summary.txt", "统计字段.txt", "qc_rules.txt"}
+
+// FileChecksum size and sha256 of one file
+type FileChecksum struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SampleOutput output files of one sample
+type SampleOutput struct {
+	ID    string          `json:"id"`
+	Files []*FileChecksum `json:"files"`
+}
+
+// RunInfo provenance of one run, write to run.json and RunInfo sheet of summary.xlsx
+type RunInfo struct {
+	Version     string          `json:"version"`
+	Commit      string          `json:"commit"`
+	CommitTime  string          `json:"commitTime,omitempty"`
+	Modified    bool            `json:"modified"`
+	GoVersion   string          `json:"goVersion"`
+	CommandLine []string        `json:"commandLine"`
+	WorkDir     string          `json:"workDir"`
+	Host        string          `json:"host"`
+	StartTime   time.Time       `json:"startTime"`
+	EndTime     time.Time       `json:"endTime"`
+	Options     map[string]any  `json:"options"`
+	Input       string          `json:"input"`
+	Etc         []*FileChecksum `json:"etc"`
+	Fastqs      []*FileChecksum `json:"fastqs"`
+	Samples     []*SampleOutput `json:"samples"`
+	// Outputs batch-level output files: summary, batch.json, report.html, figures and zip
+	Outputs []*FileChecksum `json:"outputs"`
+}
+
+// NewRunInfo create RunInfo with build info, command line and host
+func NewRunInfo() *RunInfo {
+	var runInfo = &RunInfo{
+		Version:     "(unknown)",
+		CommandLine: os.Args,
+		WorkDir:     simpleUtil.HandleError(os.Getwd()),
+		Host:        osUtil.Hostname(),
+		StartTime:   time.Now(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		runInfo.Version = info.Main.Version
+		runInfo.GoVersion = info.GoVersion
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				runInfo.Commit = s.Value
+			case "vcs.time":
+				runInfo.CommitTime = s.Value
+			case "vcs.modified":
+				runInfo.Modified = s.Value == "true"
+			}
+		}
+	}
+	return runInfo
+}
+
+// Checksum return size and sha256 of r
+func Checksum(path string, r io.Reader) (*FileChecksum, error) {
+	var h = sha256.New()
+	var n, err = io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	return &FileChecksum{Path: path, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// FileChecksumOf return size and sha256 of file path
+func FileChecksumOf(path string) (*FileChecksum, error) {
+	var file, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer simpleUtil.DeferClose(file)
+	return Checksum(path, file)
+}
+
+// outputOwners sample id of each file in entries, file matched by several ids belong to the longest one
+func outputOwners(entries []os.DirEntry, ids []string) map[string]string {
+	var owner = make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		var name = entry.Name()
+		for _, id := range ids {
+			if strings.HasPrefix(name, id+".") && len(id) > len(owner[name]) {
+				owner[name] = id
+			}
+		}
+	}
+	return owner
+}
+
+// SampleOutputs list [id].* files of each sample in resultDir with checksums,
+// file matched by several ids belong to the longest one
+func SampleOutputs(resultDir string, ids []string) (outputs []*SampleOutput, err error) {
+	var entries []os.DirEntry
+	entries, err = os.ReadDir(resultDir)
+	if err != nil {
+		return
+	}
+	var owner = outputOwners(entries, ids)
+	for _, id := range ids {
+		var output = &SampleOutput{ID: id}
+		for _, entry := range entries {
+			if owner[entry.Name()] != id {
+				continue
+			}
+			var checksum, err = FileChecksumOf(filepath.Join(resultDir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			checksum.Path = entry.Name()
+			output.Files = append(output.Files, checksum)
+		}
+		outputs = append(outputs, output)
+	}
+	return
+}
+
+// BatchOutputs files in resultDir not belonging to any sample of ids with checksums, run.json excluded
+func BatchOutputs(resultDir string, ids []string) (outputs []*FileChecksum, err error) {
+	var entries []os.DirEntry
+	entries, err = os.ReadDir(resultDir)
+	if err != nil {
+		return
+	}
+	var owner = outputOwners(entries, ids)
+	for _, entry := range entries {
+		var name = entry.Name()
+		if entry.IsDir() || owner[name] != "" || name == "run.json" {
+			continue
+		}
+		var checksum, err = FileChecksumOf(filepath.Join(resultDir, name))
+		if err != nil {
+			return nil, err
+		}
+		checksum.Path = name
+		outputs = append(outputs, checksum)
+	}
+	return
+}
+
+// WriteJSON write RunInfo to path
+func (runInfo *RunInfo) WriteJSON(path string) error {
+	var data, err = json.MarshalIndent(runInfo, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// AddRunInfo2Sheet add RunInfo sheet to summary.xlsx
+func AddRunInfo2Sheet(excel *excelize.File, runInfo *RunInfo) {
+	if runInfo == nil {
+		return
+	}
+	var (
+		sheetName = "RunInfo"
+		rIdx      = 1
+		addRow    = func(row ...any) {
+			SetRow(excel, sheetName, 1, rIdx, row)
+			rIdx++
+		}
+		addFiles = func(title string, files []*FileChecksum) {
+			rIdx++
+			addRow(title, "size", "sha256")
+			for _, f := range files {
+				addRow(f.Path, f.Size, f.SHA256)
+			}
+		}
+	)
+	simpleUtil.HandleError(excel.NewSheet(sheetName))
+	simpleUtil.CheckErr(excel.SetColWidth(sheetName, "A", "A", 40))
+	simpleUtil.CheckErr(excel.SetColWidth(sheetName, "B", "B", 20))
+	simpleUtil.CheckErr(excel.SetColWidth(sheetName, "C", "C", 70))
+
+	addRow(L("版本"), runInfo.Version)
+	addRow(L("提交"), runInfo.Commit, runInfo.CommitTime)
+	addRow("Go", runInfo.GoVersion)
+	addRow(L("命令行"), strings.Join(runInfo.CommandLine, " "))
+	addRow(L("工作目录"), runInfo.WorkDir)
+	addRow(L("主机"), runInfo.Host)
+	addRow(L("开始时间"), runInfo.StartTime.Format(time.RFC3339))
+	addRow(L("结束时间"), runInfo.EndTime.Format(time.RFC3339))
+	addRow(L("输入"), runInfo.Input)
+
+	rIdx++
+	addRow(L("参数"), L("值"))
+	var names []string
+	for name := range runInfo.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addRow(name, fmt.Sprint(runInfo.Options[name]))
+	}
+
+	addFiles(L("配置文件"), runInfo.Etc)
+	addFiles("fastq", runInfo.Fastqs)
+
+	rIdx++
+	addRow(L("样品"), L("输出文件"), "size", "sha256")
+	for _, sample := range runInfo.Samples {
+		for _, f := range sample.Files {
+			addRow(sample.ID, f.Path, f.Size, f.SHA256)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package seqAnalysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatchOutputs(t *testing.T) {
+	var dir = t.TempDir()
+	for _, name := range []string{"A1.stats.txt", "A1.1.stats.txt", "summary.txt", "gel.png", "run.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids = []string{"A1", "A1.1"}
+	var samples, err = SampleOutputs(dir, ids)
+	if err != nil || len(samples) != 2 || len(samples[0].Files) != 1 || len(samples[1].Files) != 1 ||
+		samples[1].Files[0].Path != "A1.1.stats.txt" {
+		t.Fatalf("SampleOutputs = %+v, %v", samples, err)
+	}
+	outputs, err := BatchOutputs(dir, ids)
+	if err != nil || len(outputs) != 2 || outputs[0].Path != "gel.png" || outputs[1].Path != "summary.txt" || outputs[1].Size != 11 {
+		t.Errorf("BatchOutputs = %+v, %v", outputs, err)
+	}
+}
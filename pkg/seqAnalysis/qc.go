@@ -0,0 +1,254 @@
+package seqAnalysis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	math2 "github.com/liserjrqlxue/goUtil/math"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+)
+
+// QC levels, in ascending order of severity
+const (
+	QCPass = "PASS"
+	QCWarn = "WARN"
+	QCFail = "FAIL"
+)
+
+var qcSeverity = map[string]int{QCPass: 0, QCWarn: 1, QCFail: 2}
+
+// ErrQCFailed returned by BatchRun if any sample reach QCExit level
+var ErrQCFailed = errors.New("qc failed")
+
+// QCMinStepReads MaxStepErrorRate 忽略 reads 少于 QCMinStepReads 的位置
+var QCMinStepReads = 100
+
+// qcStatsKeys keys of SeqInfo.Stats usable in rules, also as [key]Ratio = key/AnalyzedReadsNum
+var qcStatsKeys = []string{
+	"AllReadsNum", "IndexReadsNum", "AnalyzedReadsNum", "RightReadsNum", "ErrorReadsNum",
+	"Deletion", "DeletionSingle", "DeletionContinuous2", "DeletionContinuous3", "DeletionDiscrete2", "DeletionDiscrete3",
+	"ErrorInsReadsNum", "ErrorInsDelReadsNum", "ErrorMutReadsNum", "ErrorOtherReadsNum", "ExcludeOtherReadsNum",
+}
+
+// qcDerived derived metrics usable in rules
+var qcDerived = map[string]func(info *SeqInfo) float64{
+	// 收率
+	"Yield": func(info *SeqInfo) float64 { return info.YieldCoefficient },
+	// 收率平均准确率，即 summary 单步准确率
+	"StepAccuracy": func(info *SeqInfo) float64 { return info.AverageYieldAccuracy },
+	"Accuracy": func(info *SeqInfo) float64 {
+		return math2.DivisionInt(info.RightReadsNum, info.Stats["AnalyzedReadsNum"])
+	},
+	"AverageBaseAccuracy": func(info *SeqInfo) float64 {
+		return math2.DivisionInt(info.Stats["AccuRightNum"], info.Stats["AccuReadsNum"])
+	},
+	// 各位置单步错误率最大值，跳过 NaN 及 reads 少于 QCMinStepReads 的位置
+	"MaxStepErrorRate": func(info *SeqInfo) float64 {
+		var rate float64
+		for _, step := range info.Steps {
+			var errRate = 1 - step.StepAccuracy
+			if step.ReadsCount < QCMinStepReads || math.IsNaN(errRate) || math.IsInf(errRate, 0) {
+				continue
+			}
+			rate = math.Max(rate, errRate)
+		}
+		return rate
+	},
+	"Length": func(info *SeqInfo) float64 { return float64(len(info.Seq)) },
+}
+
+var qcOps = map[string]func(a, b float64) bool{
+	">=": func(a, b float64) bool { return a >= b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+	">":  func(a, b float64) bool { return a > b },
+	"<":  func(a, b float64) bool { return a < b },
+}
+
+// QCRule one line of qc_rules.txt: sample get Level if Rule not satisfied
+type QCRule struct {
+	Level string
+	Rule  string
+	Note  string
+
+	left, op, right string
+}
+
+// QCResult QC of one sample, Level is the most severe level of unsatisfied rules
+type QCResult struct {
+	Level   string   `json:"level"`
+	Reasons []string `json:"reasons"`
+}
+
+// qcOperand check operand is number, percentage or known metric
+func qcOperand(s string) error {
+	if _, err := qcNumber(s); err == nil {
+		return nil
+	}
+	if _, ok := qcDerived[s]; ok {
+		return nil
+	}
+	var key, _ = strings.CutSuffix(s, "Ratio")
+	if slices.Contains(qcStatsKeys, s) || slices.Contains(qcStatsKeys, key) {
+		return nil
+	}
+	return fmt.Errorf("unknown metric: %s", s)
+}
+
+// qcNumber parse number, 5% as 0.05
+func qcNumber(s string) (float64, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		var v, err = strconv.ParseFloat(p, 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// NewQCRule parse rule as "metric op value", op in >= <= == != > <
+func NewQCRule(level, rule, note string) (*QCRule, error) {
+	var r = &QCRule{
+		Level: strings.ToUpper(strings.TrimSpace(level)),
+		Rule:  strings.TrimSpace(rule),
+		Note:  strings.TrimSpace(note),
+	}
+	if r.Level != QCWarn && r.Level != QCFail {
+		return nil, fmt.Errorf("invalid level %q of rule %q, need WARN or FAIL", level, rule)
+	}
+	var fields = strings.Fields(r.Rule)
+	if len(fields) != 3 || qcOps[fields[1]] == nil {
+		return nil, fmt.Errorf("invalid rule %q, need [metric] [>=|<=|==|!=|>|<] [value]", rule)
+	}
+	r.left, r.op, r.right = fields[0], fields[1], fields[2]
+	for _, s := range []string{r.left, r.right} {
+		if err := qcOperand(s); err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+	}
+	return r, nil
+}
+
+// LoadQCRules load rules from tsv with title Level Rule Note, lines start with # are comments
+func LoadQCRules(r io.Reader) (rules []*QCRule, err error) {
+	var data []byte
+	data, err = io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	var title []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var cols = strings.Split(line, "\t")
+		if title == nil {
+			title = cols
+			continue
+		}
+		var m = make(map[string]string)
+		for i, t := range title {
+			if i < len(cols) {
+				m[t] = cols[i]
+			}
+		}
+		var rule *QCRule
+		rule, err = NewQCRule(m["Level"], m["Rule"], m["Note"])
+		if err != nil {
+			return
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// Metric value of metric or number s of info
+func (info *SeqInfo) Metric(s string) float64 {
+	if v, err := qcNumber(s); err == nil {
+		return v
+	}
+	if f, ok := qcDerived[s]; ok {
+		return f(info)
+	}
+	if key, ok := strings.CutSuffix(s, "Ratio"); ok && slices.Contains(qcStatsKeys, key) {
+		return math2.DivisionInt(info.Stats[key], info.Stats["AnalyzedReadsNum"])
+	}
+	return float64(info.Stats[s])
+}
+
+// Check return false and reason if info not satisfy rule
+func (rule *QCRule) Check(info *SeqInfo) (bool, string) {
+	var left, right = info.Metric(rule.left), info.Metric(rule.right)
+	if qcOps[rule.op](left, right) {
+		return true, ""
+	}
+	var reason = fmt.Sprintf("%s (%s; %s=%.4g)", rule.Note, rule.Rule, rule.left, left)
+	if rule.Note == "" {
+		reason = fmt.Sprintf("%s (%s=%.4g)", rule.Rule, rule.left, left)
+	}
+	return false, reason
+}
+
+// EvaluateQC QCResult of info by rules
+func (info *SeqInfo) EvaluateQC(rules []*QCRule) *QCResult {
+	var result = &QCResult{Level: QCPass, Reasons: []string{}}
+	for _, rule := range rules {
+		if ok, reason := rule.Check(info); !ok {
+			result.Reasons = append(result.Reasons, rule.Level+": "+reason)
+			if qcSeverity[rule.Level] > qcSeverity[result.Level] {
+				result.Level = rule.Level
+			}
+		}
+	}
+	return result
+}
+
+// Reason reasons joined by "; "
+func (result *QCResult) Reason() string {
+	return strings.Join(result.Reasons, "; ")
+}
+
+// QCReached if level reach threshold, false if threshold is empty
+func QCReached(level, threshold string) bool {
+	if threshold == "" {
+		return false
+	}
+	return qcSeverity[level] >= qcSeverity[strings.ToUpper(threshold)]
+}
+
+// hasQC if samples have QC, QC is evaluated for all samples or none
+func hasQC(SeqInfoMap map[string]*SeqInfo) bool {
+	for _, info := range SeqInfoMap {
+		return info.QC != nil
+	}
+	return false
+}
+
+// qcTitle titles of QC columns
+func qcTitle() []string {
+	return []string{"QC", L("QC说明")}
+}
+
+// AddQCFormat fill QC column col from row1 to row2 by level, WARN yellow and FAIL red
+func AddQCFormat(excel *excelize.File, sheet string, col, row1, row2 int) {
+	if col < 1 || row2 < row1 {
+		return
+	}
+	var cells = simpleUtil.HandleError(excelize.CoordinatesToCellName(col, row1)) + ":" +
+		simpleUtil.HandleError(excelize.CoordinatesToCellName(col, row2))
+	var opts []excelize.ConditionalFormatOptions
+	for _, fill := range [][2]string{{QCWarn, "#FFEB9C"}, {QCFail, "#FFC7CE"}} {
+		var style = simpleUtil.HandleError(excel.NewConditionalStyle(&excelize.Style{
+			Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{fill[1]}},
+		}))
+		opts = append(opts, excelize.ConditionalFormatOptions{
+			Type: "cell", Criteria: "==", Format: &style, Value: `"` + fill[0] + `"`,
+		})
+	}
+	simpleUtil.CheckErr(excel.SetConditionalFormat(sheet, cells, opts))
+}
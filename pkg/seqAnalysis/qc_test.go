@@ -0,0 +1,81 @@
+package seqAnalysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoadQCRules(t *testing.T) {
+	var rules, err = LoadQCRules(strings.NewReader(
+		"Level\tRule\tNote\n# comment\nFAIL\tAnalyzedReadsNum >= 1000\t分析reads不足\nwarn\tMaxStepErrorRate <= 5%\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[1].Level != QCWarn || rules[1].right != "5%" {
+		t.Errorf("rules = %+v", rules)
+	}
+	for _, rule := range []string{"Yield >= ", "Yield => 0.1", "Unknown >= 1"} {
+		if _, err = NewQCRule(QCFail, rule, ""); err == nil {
+			t.Errorf("NewQCRule(%q) should fail", rule)
+		}
+	}
+	if _, err = NewQCRule("ERROR", "Yield >= 0.1", ""); err == nil {
+		t.Error("level ERROR should fail")
+	}
+}
+
+func TestEvaluateQC(t *testing.T) {
+	var info = &SeqInfo{
+		YieldCoefficient: 0.05,
+		Stats:            map[string]int{"AnalyzedReadsNum": 2000, "Deletion": 500},
+		Steps:            []*StepStat{{ReadsCount: 2000, StepAccuracy: 0.99}, {ReadsCount: 1500, StepAccuracy: 0.9}},
+	}
+	var rules []*QCRule
+	for _, r := range [][3]string{
+		{QCFail, "AnalyzedReadsNum >= 1000", ""},
+		{QCWarn, "Yield >= 10%", "收率偏低"},
+		{QCWarn, "DeletionRatio < 0.3", ""},
+		{QCFail, "MaxStepErrorRate <= 0.2", ""},
+	} {
+		var rule, err = NewQCRule(r[0], r[1], r[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	var result = info.EvaluateQC(rules)
+	if result.Level != QCWarn || len(result.Reasons) != 1 || !strings.Contains(result.Reasons[0], "收率偏低") {
+		t.Errorf("result = %+v", result)
+	}
+
+	rules[3].right = "5%"
+	if result = info.EvaluateQC(rules); result.Level != QCFail || len(result.Reasons) != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if !QCReached(QCFail, "warn") || QCReached(QCWarn, QCFail) || QCReached(QCFail, "") {
+		t.Error("QCReached")
+	}
+}
+
+func TestMaxStepErrorRate(t *testing.T) {
+	var info = &SeqInfo{Steps: []*StepStat{
+		{ReadsCount: 2000, StepAccuracy: 0.99},
+		{ReadsCount: 1000, StepAccuracy: 0.95},
+		// 末端 reads 过少
+		{ReadsCount: 10, StepAccuracy: 0.5},
+		// 0 reads
+		{ReadsCount: 0, StepAccuracy: math.NaN()},
+	}}
+	if rate := info.Metric("MaxStepErrorRate"); math.Abs(rate-0.05) > 1e-12 {
+		t.Errorf("MaxStepErrorRate = %v, want 0.05", rate)
+	}
+	var rule, err = NewQCRule(QCWarn, "MaxStepErrorRate <= 10%", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason := rule.Check(info); !ok {
+		t.Errorf("Check = %v %s", ok, reason)
+	}
+}
@@ -0,0 +1,206 @@
+package seqAnalysis
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+// EchartsVersion ECharts embedded in report.html, options generated by go-echarts v2 target ECharts 5
+const EchartsVersion = "5.4.3"
+
+//go:generate curl -fsSL -o assets/echarts.min.js https://cdn.jsdelivr.net/npm/echarts@5.4.3/dist/echarts.min.js
+
+// reportAssets assets/echarts.min.js of EchartsVersion, fetched by go generate
+//
+//go:embed assets
+var reportAssets embed.FS
+
+// echartsScript inline <script> of embedded echarts.min.js, error if not embedded, report.html never loads online assets
+func echartsScript() (string, error) {
+	var js, err = reportAssets.ReadFile("assets/echarts.min.js")
+	if err != nil {
+		return "", fmt.Errorf("echarts.min.js %s not embedded, run go generate ./pkg/seqAnalysis: %w", EchartsVersion, err)
+	}
+	if len(js) == 0 {
+		return "", fmt.Errorf("embedded echarts.min.js %s is empty", EchartsVersion)
+	}
+	return "<script>" + string(js) + "</script>", nil
+}
+
+// reportChartOpts common options of report charts
+func reportChartOpts(title, xName, yName string) []charts.GlobalOpts {
+	return []charts.GlobalOpts{
+		charts.WithInitializationOpts(opts.Initialization{Width: "1200px", Height: "500px"}),
+		charts.WithTitleOpts(opts.Title{Title: title}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Type: "scroll", Top: "30px"}),
+		charts.WithDataZoomOpts(opts.DataZoom{Type: "inside"}, opts.DataZoom{Type: "slider"}),
+		charts.WithGridOpts(opts.Grid{Top: "80px"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: xName}),
+		charts.WithYAxisOpts(opts.YAxis{Name: yName}),
+	}
+}
+
+// positionLine line chart of value of each synthesis position of samples
+func positionLine(title, yName string, samples []*SeqInfo, value func(info *SeqInfo, i int) float64) *charts.Line {
+	var (
+		line   = charts.NewLine()
+		maxLen = 0
+		xaxis  []int
+	)
+	line.SetGlobalOptions(reportChartOpts(title, L("合成位置"), yName)...)
+	for _, info := range samples {
+		maxLen = max(maxLen, len(info.Seq))
+	}
+	for i := 1; i <= maxLen; i++ {
+		xaxis = append(xaxis, i)
+	}
+	line.SetXAxis(xaxis)
+	for _, info := range samples {
+		var items []opts.LineData
+		for i := range info.Seq {
+			items = append(items, opts.LineData{Value: value(info, i)})
+		}
+		line.AddSeries(info.Name, items)
+	}
+	return line
+}
+
+// lengthLine line chart of length histogram of samples
+func lengthLine(samples []*SeqInfo) *charts.Line {
+	var (
+		line    = charts.NewLine()
+		lengths []int
+		seen    = make(map[int]bool)
+	)
+	line.SetGlobalOptions(reportChartOpts(L("长度分布"), L("长度"), L("reads数"))...)
+	for _, info := range samples {
+		for k := range info.Histogram {
+			if !seen[k] {
+				seen[k] = true
+				lengths = append(lengths, k)
+			}
+		}
+	}
+	sort.Ints(lengths)
+	line.SetXAxis(lengths)
+	for _, info := range samples {
+		var items []opts.LineData
+		for _, k := range lengths {
+			items = append(items, opts.LineData{Value: info.Histogram[k]})
+		}
+		line.AddSeries(info.Name, items)
+	}
+	return line
+}
+
+// parallelBar bar chart of mean and sd of yield and accuracy of each parallel group
+func parallelBar(ParallelStatsMap map[string]*ParallelTest) *charts.Bar {
+	var (
+		bar                    = charts.NewBar()
+		ids                    []string
+		yMean, ySD, aMean, aSD []opts.BarData
+	)
+	bar.SetGlobalOptions(reportChartOpts(L("平行组比较"), L("平行"), "")...)
+	for id := range ParallelStatsMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		var p = ParallelStatsMap[id]
+		yMean = append(yMean, opts.BarData{Value: p.YieldCoefficientMean})
+		ySD = append(ySD, opts.BarData{Value: p.YieldCoefficientSD})
+		aMean = append(aMean, opts.BarData{Value: p.AverageYieldAccuracyMean})
+		aSD = append(aSD, opts.BarData{Value: p.AverageYieldAccuracySD})
+	}
+	bar.SetXAxis(ids).
+		AddSeries(L("平均收率"), yMean).
+		AddSeries(L("收率误差"), ySD).
+		AddSeries(L("平均准确率"), aMean).
+		AddSeries(L("准确率误差"), aSD)
+	return bar
+}
+
+// reportTable html table of sample metrics
+func reportTable(samples []*SeqInfo) string {
+	var b strings.Builder
+	b.WriteString(`<table class="summary"><tr>`)
+	for _, title := range []string{"样品名称", "平行", "分析reads", "正确reads", "收率", "单步准确率"} {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(L(title)))
+	}
+	b.WriteString("</tr>")
+	for _, info := range samples {
+		fmt.Fprintf(
+			&b, "<tr><td>%s</td><td>%s</td><td>%d</td><td>%d</td><td>%.4f</td><td>%.4f</td></tr>",
+			html.EscapeString(info.Name), html.EscapeString(info.ParallelTestID),
+			info.Stats["AnalyzedReadsNum"], info.RightReadsNum, info.YieldCoefficient, info.AverageYieldAccuracy,
+		)
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
+
+// WriteReportHTML write offline html report of samples in order of inputInfo, echarts.min.js of EchartsVersion embedded
+func WriteReportHTML(path, title string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest) error {
+	var samples []*SeqInfo
+	for _, data := range inputInfo {
+		samples = append(samples, SeqInfoMap[data["id"]])
+	}
+
+	var page = components.NewPage()
+	page.PageTitle = title
+	page.SetLayout(components.PageFlexLayout)
+	page.AddCharts(
+		positionLine(L("单步错误率"), "%", samples, func(info *SeqInfo, i int) float64 {
+			if i >= len(info.Steps) {
+				return 0
+			}
+			return (1 - info.Steps[i].StepAccuracy) * 100
+		}),
+		positionLine(L("收率衰减"), L("收率"), samples, func(info *SeqInfo, i int) float64 {
+			if i >= len(info.Steps) {
+				return 0
+			}
+			return info.Steps[i].Yield
+		}),
+		lengthLine(samples),
+	)
+	for j, name := range []string{"缺失分布", "插入分布", "突变分布"} {
+		page.AddCharts(positionLine(L(name), L("比例"), samples, func(info *SeqInfo, i int) float64 {
+			if i >= len(info.DistributionFreq[j]) {
+				return 0
+			}
+			return info.DistributionFreq[j][i]
+		}))
+	}
+	page.AddCharts(parallelBar(ParallelStatsMap))
+
+	// 不引用 go-echarts 在线 assets，echarts.min.js 内嵌于 <body> 开头，先于各图表脚本
+	page.JSAssets = types.OrderedSet{}
+	page.JSAssets.Init()
+
+	var script, err = echartsScript()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = page.Render(&buf); err != nil {
+		return err
+	}
+	var content = strings.Replace(
+		buf.String(), "<body>",
+		"<body>\n"+script+"\n<style>table.summary{border-collapse:collapse;margin:20px auto} table.summary td,table.summary th{border:1px solid #ccc;padding:4px 8px}</style>\n<h2 style=\"text-align:center\">"+html.EscapeString(title)+"</h2>\n"+reportTable(samples),
+		1,
+	)
+	return os.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,20 @@
+package seqAnalysis
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEchartsAsset report.html needs embedded echarts.min.js of EchartsVersion, fails until assets/echarts.min.js committed
+func TestEchartsAsset(t *testing.T) {
+	var script, err = echartsScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script, EchartsVersion) {
+		t.Errorf("embedded echarts.min.js is not version %s", EchartsVersion)
+	}
+	if strings.Contains(script, "<script src=") {
+		t.Errorf("report.html must not load online assets")
+	}
+}
@@ -0,0 +1,435 @@
+package seqAnalysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	math2 "github.com/liserjrqlxue/goUtil/math"
+)
+
+// ResultSchemaVersion version of [id].result.json and batch.json, see docs/result.schema.json and docs/batch.schema.json,
+// bump major for incompatible change, minor for new fields
+const ResultSchemaVersion = "1.6"
+
+// StepStat one row of step table of Stats sheet, i.e. [id].steps.txt
+type StepStat struct {
+	Position int    `json:"position"`
+	Base     string `json:"base"`
+	// 各位置 Del/Ins/Mut/Right 比例
+	DelFreq   float64 `json:"delFreq"`
+	InsFreq   float64 `json:"insFreq"`
+	MutFreq   float64 `json:"mutFreq"`
+	RightFreq float64 `json:"rightFreq"`
+	// 进入该步的 reads 数，及该步 A/T/C/G 计数
+	ReadsCount int `json:"readsCount"`
+	A          int `json:"A"`
+	T          int `json:"T"`
+	C          int `json:"C"`
+	G          int `json:"G"`
+	Deletion   int `json:"deletion"`
+	// 收率
+	Yield  float64 `json:"yield"`
+	RatioA float64 `json:"ratioA"`
+	RatioT float64 `json:"ratioT"`
+	RatioC float64 `json:"ratioC"`
+	RatioG float64 `json:"ratioG"`
+	// 单步准确率
+	StepAccuracy float64 `json:"stepAccuracy"`
+	// 收率平均准确率
+	AverageYieldAccuracy float64 `json:"averageYieldAccuracy"`
+	Top1Base             string  `json:"top1Base"`
+	Top1Ratio            float64 `json:"top1Ratio"`
+	Top2Base             string  `json:"top2Base"`
+	Top2Ratio            float64 `json:"top2Ratio"`
+	Deletion1            int     `json:"deletion1"`
+	Deletion1Ratio       float64 `json:"deletion1Ratio"`
+	// 单步准确率、收率 区间，since 1.2
+	StepAccuracyCI Interval `json:"stepAccuracyCI"`
+	YieldCI        Interval `json:"yieldCI"`
+}
+
+// LengthCount one bin of length histogram
+type LengthCount struct {
+	Length int `json:"length"`
+	Count  int `json:"count"`
+}
+
+// Distribution Del/Ins/Mut/Right of each position
+type Distribution struct {
+	Del   []any `json:"del"`
+	Ins   []any `json:"ins"`
+	Mut   []any `json:"mut"`
+	Right []any `json:"right"`
+}
+
+// SampleResult content of [id].result.json
+type SampleResult struct {
+	SchemaVersion string   `json:"schemaVersion"`
+	ID            string   `json:"id"`
+	ParallelID    string   `json:"parallelId"`
+	IndexSeq      string   `json:"indexSeq"`
+	Seq           string   `json:"seq"`
+	PostSeq       string   `json:"postSeq"`
+	Length        int      `json:"length"`
+	Fastqs        []string `json:"fastqs"`
+
+	AllReadsNum        int `json:"allReadsNum"`
+	IndexReadsNum      int `json:"indexReadsNum"`
+	IndexPolyAReadsNum int `json:"indexPolyAReadsNum"`
+	AnalyzedReadsNum   int `json:"analyzedReadsNum"`
+	RightReadsNum      int `json:"rightReadsNum"`
+	ExcludeReadsNum    int `json:"excludeReadsNum"`
+
+	YieldCoefficient     float64 `json:"yieldCoefficient"`
+	AverageYieldAccuracy float64 `json:"averageYieldAccuracy"`
+	Accuracy             float64 `json:"accuracy"`
+	AverageBaseAccuracy  float64 `json:"averageBaseAccuracy"`
+
+	Stats            map[string]int `json:"stats"`
+	DistributionNum  Distribution   `json:"distributionNum"`
+	DistributionFreq Distribution   `json:"distributionFreq"`
+	Steps            []*StepStat    `json:"steps"`
+	Histogram        []LengthCount  `json:"histogram"`
+	// 置信区间，since 1.2
+	CI *SampleCI `json:"ci,omitempty"`
+}
+
+// ParallelResult ParallelTest of one parallel group in batch.json
+type ParallelResult struct {
+	ID                       string    `json:"id"`
+	Samples                  []string  `json:"samples"`
+	YieldCoefficient         []float64 `json:"yieldCoefficient"`
+	YieldCoefficientMean     float64   `json:"yieldCoefficientMean"`
+	YieldCoefficientSD       float64   `json:"yieldCoefficientSD"`
+	AverageYieldAccuracy     []float64 `json:"averageYieldAccuracy"`
+	AverageYieldAccuracyMean float64   `json:"averageYieldAccuracyMean"`
+	AverageYieldAccuracySD   float64   `json:"averageYieldAccuracySD"`
+	// Grubbs 离群样品，since 1.3
+	Outliers []string `json:"outliers"`
+	// 以分析reads加权，since 1.4
+	Depths                           []int   `json:"depths"`
+	YieldCoefficientWeightedMean     float64 `json:"yieldCoefficientWeightedMean"`
+	YieldCoefficientWeightedSD       float64 `json:"yieldCoefficientWeightedSD"`
+	AverageYieldAccuracyWeightedMean float64 `json:"averageYieldAccuracyWeightedMean"`
+	AverageYieldAccuracyWeightedSD   float64 `json:"averageYieldAccuracyWeightedSD"`
+	// 合并 reads 的虚拟样品，-pool only, since 1.4
+	Pooled *BatchSample `json:"pooled,omitempty"`
+}
+
+// BatchSample brief of one sample in batch.json
+type BatchSample struct {
+	ID                   string  `json:"id"`
+	ParallelID           string  `json:"parallelId"`
+	Result               string  `json:"result"`
+	AnalyzedReadsNum     int     `json:"analyzedReadsNum"`
+	RightReadsNum        int     `json:"rightReadsNum"`
+	YieldCoefficient     float64 `json:"yieldCoefficient"`
+	AverageYieldAccuracy float64 `json:"averageYieldAccuracy"`
+	// QC by qc_rules.txt, since 1.1
+	QC *QCResult `json:"qc,omitempty"`
+}
+
+// BatchResult content of batch.json
+type BatchResult struct {
+	SchemaVersion string            `json:"schemaVersion"`
+	Input         string            `json:"input"`
+	CreateTime    time.Time         `json:"createTime"`
+	Samples       []*BatchSample    `json:"samples"`
+	Parallels     []*ParallelResult `json:"parallels"`
+	// 分碱基偶联效率模型，since 1.5
+	Coupling *CouplingModel `json:"coupling,omitempty"`
+}
+
+// distribution convert [4][]T to Distribution, cut to length of Seq
+func distribution[T int | float64](d [4][]T, n int) (result Distribution) {
+	var cols = make([][]any, 4)
+	for j := range d {
+		cols[j] = make([]any, 0, n)
+		for i := 0; i < n && i < len(d[j]); i++ {
+			cols[j] = append(cols[j], d[j][i])
+		}
+	}
+	result.Del, result.Ins, result.Mut, result.Right = cols[0], cols[1], cols[2], cols[3]
+	return
+}
+
+// Result SampleResult of seqInfo, after SingleRun
+func (seqInfo *SeqInfo) Result() *SampleResult {
+	var stats = seqInfo.Stats
+	var result = &SampleResult{
+		SchemaVersion: ResultSchemaVersion,
+		ID:            seqInfo.Name,
+		ParallelID:    seqInfo.ParallelTestID,
+		IndexSeq:      seqInfo.IndexSeq,
+		Seq:           string(seqInfo.Seq),
+		PostSeq:       seqInfo.PostSeq,
+		Length:        len(seqInfo.Seq),
+		Fastqs:        []string{},
+
+		AllReadsNum:        seqInfo.AllReadsNum,
+		IndexReadsNum:      seqInfo.IndexReadsNum,
+		IndexPolyAReadsNum: seqInfo.IndexPolyAReadsNum,
+		AnalyzedReadsNum:   stats["AnalyzedReadsNum"],
+		RightReadsNum:      seqInfo.RightReadsNum,
+		ExcludeReadsNum:    seqInfo.ExcludeReadsNum,
+
+		YieldCoefficient:     seqInfo.YieldCoefficient,
+		AverageYieldAccuracy: seqInfo.AverageYieldAccuracy,
+		Accuracy:             math2.DivisionInt(seqInfo.RightReadsNum, stats["AnalyzedReadsNum"]),
+		AverageBaseAccuracy:  math2.DivisionInt(stats["AccuRightNum"], stats["AccuReadsNum"]),
+
+		Stats:            stats,
+		DistributionNum:  distribution(seqInfo.DistributionNum, len(seqInfo.Seq)),
+		DistributionFreq: distribution(seqInfo.DistributionFreq, len(seqInfo.Seq)),
+		Steps:            seqInfo.Steps,
+		Histogram:        []LengthCount{},
+		CI:               seqInfo.CI,
+	}
+	for _, fq := range seqInfo.Fastqs {
+		if fq != "" {
+			result.Fastqs = append(result.Fastqs, fq)
+		}
+	}
+	var lengths []int
+	for k := range seqInfo.Histogram {
+		lengths = append(lengths, k)
+	}
+	sort.Ints(lengths)
+	for _, k := range lengths {
+		result.Histogram = append(result.Histogram, LengthCount{Length: k, Count: seqInfo.Histogram[k]})
+	}
+	return result
+}
+
+// jsonMarshaler types encoded by their own MarshalJSON, e.g. time.Time
+var jsonMarshaler = reflect.TypeFor[json.Marshaler]()
+
+// jsonField one field of jsonObject
+type jsonField struct {
+	Name  string
+	Value any
+}
+
+// jsonObject struct as ordered fields, keep field order of struct
+type jsonObject []jsonField
+
+// MarshalJSON fields in order, without escaping <>&
+func (object jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	var encoder = json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, field := range object {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encoder.Encode(field.Name); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteByte(':')
+		if err := encoder.Encode(field.Value); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// finiteJSON copy of v for encoding/json with NaN and ±Inf as nil (null),
+// struct fields by json tag in order, omitempty and "-" as encoding/json
+func finiteJSON(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshaler) && (v.Kind() != reflect.Pointer || !v.IsNil()) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		var f = v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return f
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return finiteJSON(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		var values = make([]any, v.Len())
+		for i := range values {
+			values[i] = finiteJSON(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		var values = make(map[string]any, v.Len())
+		var iter = v.MapRange()
+		for iter.Next() {
+			values[fmt.Sprint(iter.Key().Interface())] = finiteJSON(iter.Value())
+		}
+		return values
+	case reflect.Struct:
+		var object jsonObject
+		for i := range v.NumField() {
+			var field = v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			var tag = field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			var name, opts, _ = strings.Cut(tag, ",")
+			if field.Anonymous && name == "" {
+				var embedded = v.Field(i)
+				if embedded.Kind() == reflect.Pointer {
+					if embedded.IsNil() {
+						continue
+					}
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					if sub, ok := finiteJSON(embedded).(jsonObject); ok {
+						object = append(object, sub...)
+					}
+					continue
+				}
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if strings.Contains(opts, "omitempty") && emptyJSON(v.Field(i)) {
+				continue
+			}
+			object = append(object, jsonField{name, finiteJSON(v.Field(i))})
+		}
+		return object
+	default:
+		return v.Interface()
+	}
+}
+
+// emptyJSON v is empty value omitted by omitempty of encoding/json
+func emptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// writeJSON write v to path with indent, without escaping <>& of QC rules,
+// NaN and ±Inf (e.g. ratios of 0 reads) as null
+func writeJSON(path string, v any) error {
+	var buf bytes.Buffer
+	var encoder = json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(finiteJSON(reflect.ValueOf(v))); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// WriteResultJSON write [id].result.json to resultDir
+func (seqInfo *SeqInfo) WriteResultJSON(resultDir string) error {
+	return writeJSON(filepath.Join(resultDir, seqInfo.Name+".result.json"), seqInfo.Result())
+}
+
+// NewBatchResult BatchResult of samples in order of inputInfo and parallel groups sorted by id
+func NewBatchResult(input string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest, coupling *CouplingModel) *BatchResult {
+	var (
+		result = &BatchResult{
+			SchemaVersion: ResultSchemaVersion,
+			Input:         input,
+			CreateTime:    time.Now(),
+			Samples:       []*BatchSample{},
+			Parallels:     []*ParallelResult{},
+			Coupling:      coupling,
+		}
+		groups = make(map[string][]string)
+		ids    []string
+	)
+	for _, data := range inputInfo {
+		var info = SeqInfoMap[data["id"]]
+		result.Samples = append(result.Samples, &BatchSample{
+			ID:                   info.Name,
+			ParallelID:           info.ParallelTestID,
+			Result:               info.Name + ".result.json",
+			AnalyzedReadsNum:     info.Stats["AnalyzedReadsNum"],
+			RightReadsNum:        info.RightReadsNum,
+			YieldCoefficient:     info.YieldCoefficient,
+			AverageYieldAccuracy: info.AverageYieldAccuracy,
+			QC:                   info.QC,
+		})
+		groups[info.ParallelTestID] = append(groups[info.ParallelTestID], info.Name)
+	}
+	for id := range ParallelStatsMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		var p = ParallelStatsMap[id]
+		var parallel = &ParallelResult{
+			ID:                       id,
+			Samples:                  groups[id],
+			YieldCoefficient:         p.YieldCoefficient,
+			YieldCoefficientMean:     p.YieldCoefficientMean,
+			YieldCoefficientSD:       p.YieldCoefficientSD,
+			AverageYieldAccuracy:     p.AverageYieldAccuracy,
+			AverageYieldAccuracyMean: p.AverageYieldAccuracyMean,
+			AverageYieldAccuracySD:   p.AverageYieldAccuracySD,
+			Outliers:                 append([]string{}, p.Outliers...),
+
+			Depths:                           append([]int{}, p.Depth...),
+			YieldCoefficientWeightedMean:     p.YieldCoefficientWeightedMean,
+			YieldCoefficientWeightedSD:       p.YieldCoefficientWeightedSD,
+			AverageYieldAccuracyWeightedMean: p.AverageYieldAccuracyWeightedMean,
+			AverageYieldAccuracyWeightedSD:   p.AverageYieldAccuracyWeightedSD,
+		}
+		if info := p.Pooled; info != nil {
+			parallel.Pooled = &BatchSample{
+				ID:                   info.Name,
+				ParallelID:           id,
+				Result:               info.Name + ".result.json",
+				AnalyzedReadsNum:     info.Stats["AnalyzedReadsNum"],
+				RightReadsNum:        info.RightReadsNum,
+				YieldCoefficient:     info.YieldCoefficient,
+				AverageYieldAccuracy: info.AverageYieldAccuracy,
+			}
+		}
+		result.Parallels = append(result.Parallels, parallel)
+	}
+	return result
+}
+
+// WriteBatchJSON write batch.json to resultDir
+func WriteBatchJSON(resultDir, input string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest, coupling *CouplingModel) error {
+	return writeJSON(filepath.Join(resultDir, "batch.json"), NewBatchResult(input, inputInfo, SeqInfoMap, ParallelStatsMap, coupling))
+}
@@ -0,0 +1,87 @@
+package seqAnalysis
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewBatchResult(t *testing.T) {
+	var (
+		inputInfo  = []map[string]string{{"id": "B.1"}, {"id": "A.1"}, {"id": "A.2"}}
+		seqInfoMap = map[string]*SeqInfo{
+			"A.1": {Name: "A.1", ParallelTestID: "A", YieldCoefficient: 0.4, Stats: map[string]int{}},
+			"A.2": {Name: "A.2", ParallelTestID: "A", YieldCoefficient: 0.6, Stats: map[string]int{}},
+			"B.1": {Name: "B.1", ParallelTestID: "B", YieldCoefficient: 0.5, Stats: map[string]int{}},
+		}
+		parallel = map[string]*ParallelTest{
+			"A": {ID: "A", YieldCoefficient: []float64{0.4, 0.6}},
+			"B": {ID: "B", YieldCoefficient: []float64{0.5}},
+		}
+	)
+	for _, p := range parallel {
+		p.Calculater()
+	}
+	var result = NewBatchResult("input.xlsx", inputInfo, seqInfoMap, parallel, nil)
+	if result.SchemaVersion != ResultSchemaVersion {
+		t.Errorf("SchemaVersion = %s, want %s", result.SchemaVersion, ResultSchemaVersion)
+	}
+	var ids []string
+	for _, s := range result.Samples {
+		ids = append(ids, s.ID)
+	}
+	if want := []string{"B.1", "A.1", "A.2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Samples = %v, want %v", ids, want)
+	}
+	if len(result.Parallels) != 2 || result.Parallels[0].ID != "A" || !reflect.DeepEqual(result.Parallels[0].Samples, []string{"A.1", "A.2"}) {
+		t.Fatalf("Parallels[0] = %+v", result.Parallels[0])
+	}
+	if m := result.Parallels[0].YieldCoefficientMean; m < 0.4999 || m > 0.5001 {
+		t.Errorf("YieldCoefficientMean = %f, want 0.5", m)
+	}
+}
+
+func TestWriteResultJSONZeroReads(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		info = &SeqInfo{
+			Name:                 "zero",
+			Seq:                  []byte("AC"),
+			Stats:                map[string]int{"AnalyzedReadsNum": 0},
+			YieldCoefficient:     math.NaN(),
+			AverageYieldAccuracy: math.NaN(),
+			Steps: []*StepStat{
+				{Position: 1, Base: "A", StepAccuracy: math.NaN(), StepAccuracyCI: Interval{math.NaN(), math.Inf(1)}},
+			},
+			CI: &SampleCI{},
+		}
+	)
+	if err := info.WriteResultJSON(dir); err != nil {
+		t.Fatal(err)
+	}
+	var data, err = os.ReadFile(filepath.Join(dir, "zero.result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]any
+	if err = json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	var step = result["steps"].([]any)[0].(map[string]any)
+	if result["yieldCoefficient"] != nil || result["accuracy"] != nil || step["stepAccuracy"] != nil || step["position"] != 1.0 {
+		t.Errorf("non-finite values should be null: %s", data)
+	}
+	if !strings.HasPrefix(string(data), "{\n  \"schemaVersion\": ") {
+		t.Errorf("field order of result.json changed: %s", data[:min(len(data), 40)])
+	}
+
+	var batch = &SeqInfo{Name: "zero", Stats: map[string]int{}, YieldCoefficient: math.NaN()}
+	if err = WriteBatchJSON(dir, "input.xlsx", []map[string]string{{"id": "zero"}}, map[string]*SeqInfo{"zero": batch},
+		map[string]*ParallelTest{"": {YieldCoefficientMean: math.NaN()}}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
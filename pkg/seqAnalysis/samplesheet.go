@@ -0,0 +1,267 @@
+package seqAnalysis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+// table format of non-xlsx input
+const (
+	FormatLegacy   = "legacy"   // 无表头 id index seq fq...
+	FormatTable    = "table"    // 表头同 input.xlsx
+	FormatIllumina = "illumina" // Illumina SampleSheet.csv
+	FormatMGI      = "mgi"      // MGI barcode 表
+)
+
+var (
+	isCSV            = regexp.MustCompile(`(?i)\.csv$`)
+	illuminaSections = map[string]bool{"[data]": true, "[bclconvert_data]": true}
+	mgiBarcodeColumn = map[string]bool{"barcode": true, "barcode id": true, "barcodeid": true, "barcode_id": true}
+)
+
+// illuminaColumns columns of Illumina SampleSheet keep as is, index/index2 are barcodes but not 靶标序列
+var illuminaColumns = map[string]bool{
+	"sample_id":      true,
+	"sample_name":    true,
+	"sample_plate":   true,
+	"sample_well":    true,
+	"sample_project": true,
+	"index":          true,
+	"index2":         true,
+	"i7_index_id":    true,
+	"i5_index_id":    true,
+	"lane":           true,
+	"description":    true,
+}
+
+// ReadTable read CSV (.csv) or TSV (others), strip BOM and spaces of cells
+func ReadTable(path string) (rows [][]string, err error) {
+	var data []byte
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var r = csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff")))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if !isCSV.MatchString(path) {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, record)
+	}
+	return
+}
+
+// blankRow row without any value
+func blankRow(row []string) bool {
+	for _, v := range row {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// TableFormat detect format of non-xlsx input from its rows
+func TableFormat(rows [][]string) string {
+	for _, row := range rows {
+		if len(row) > 0 && illuminaSections[strings.ToLower(row[0])] {
+			return FormatIllumina
+		}
+	}
+	if len(rows) == 0 {
+		return FormatLegacy
+	}
+	var title = make(map[string]bool)
+	for _, v := range CanonicalColumns(rows[0]) {
+		title[v] = true
+	}
+	if !title["样品名称"] {
+		return FormatLegacy
+	}
+	if !title["路径-R1"] {
+		for _, v := range rows[0] {
+			if mgiBarcodeColumn[strings.ToLower(v)] {
+				return FormatMGI
+			}
+		}
+	}
+	return FormatTable
+}
+
+// tableRows rows of table to map with canonical columns, row is line number of file
+func tableRows(title []string, rows [][]string, firstRow int, canonical func(string) string) (info []map[string]string) {
+	for i, row := range rows {
+		if blankRow(row) {
+			continue
+		}
+		var data = make(map[string]string)
+		for j, v := range row {
+			if j < len(title) && title[j] != "" {
+				data[canonical(title[j])] = v
+			}
+		}
+		data["row"] = strconv.Itoa(firstRow + i)
+		info = append(info, data)
+	}
+	return
+}
+
+// globFastq return sorted matches of first pattern with any match, relative to fqDir
+func globFastq(fqDir string, patterns ...string) []string {
+	for _, pattern := range patterns {
+		var matches = simpleUtil.HandleError(filepath.Glob(filepath.Join(fqDir, pattern)))
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Strings(matches)
+		if fqDir != "" {
+			for i, m := range matches {
+				matches[i] = simpleUtil.HandleError(filepath.Rel(fqDir, m))
+			}
+		}
+		return matches
+	}
+	return nil
+}
+
+// ParseIlluminaSampleSheet parse [Data] or [BCLConvert_Data] section of Illumina SampleSheet.csv,
+// 样品名称 from Sample_ID, fastq from bcl2fastq/BCL Convert naming [Sample_Project/]<Sample_Name>_S<N>_L00<lane>_R1_001.fastq.gz,
+// 靶标序列/合成序列 etc. from extra columns
+func ParseIlluminaSampleSheet(rows [][]string, fqDir string) (info []map[string]string) {
+	var start = -1
+	for i, row := range rows {
+		if len(row) > 0 && illuminaSections[strings.ToLower(row[0])] {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 || start >= len(rows) {
+		return
+	}
+	var (
+		title = rows[start]
+		end   = len(rows)
+	)
+	for i := start + 1; i < len(rows); i++ {
+		if len(rows[i]) > 0 && strings.HasPrefix(rows[i][0], "[") {
+			end = i
+			break
+		}
+	}
+	var (
+		seen   = make(map[string]bool)
+		number = 0
+	)
+	for _, data := range tableRows(title, rows[start+1:end], start+2, func(col string) string {
+		if illuminaColumns[strings.ToLower(col)] {
+			return strings.ToLower(col)
+		}
+		return CanonicalColumn(col)
+	}) {
+		var id = data["sample_id"]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		number++
+
+		data["样品名称"] = id
+		var name = data["sample_name"]
+		if name == "" {
+			name = id
+		}
+		var prefix = filepath.Join(data["sample_project"], fmt.Sprintf("%s_S%d", name, number))
+		for _, read := range []string{"R1", "R2"} {
+			var col = "路径-" + read
+			if data[col] != "" {
+				continue
+			}
+			var fqs = globFastq(
+				fqDir,
+				prefix+"_L*_"+read+"_001.fastq.gz",
+				prefix+"_"+read+"_001.fastq.gz",
+			)
+			if len(fqs) == 0 && read == "R1" {
+				fqs = []string{prefix + "_L001_R1_001.fastq.gz"}
+			}
+			data[col] = strings.Join(fqs, ",")
+		}
+		info = append(info, data)
+	}
+	return
+}
+
+// mgiLane normalize lane to L01 style, empty for all lanes
+func mgiLane(lane string) string {
+	lane = strings.TrimPrefix(strings.ToUpper(lane), "L")
+	if lane == "" {
+		return ""
+	}
+	if n, err := strconv.Atoi(lane); err == nil {
+		return fmt.Sprintf("L%02d", n)
+	}
+	return "L" + lane
+}
+
+// ParseMGISheet parse MGI barcode sheet with 样品名称 and Barcode columns, optional Lane,
+// fastq from splitBarcode naming [L01/]<FlowCell>_L01_<barcode>_1.fq.gz
+func ParseMGISheet(rows [][]string, fqDir string) (info []map[string]string) {
+	var title = rows[0]
+	for _, data := range tableRows(title, rows[1:], 2, func(col string) string {
+		if mgiBarcodeColumn[strings.ToLower(col)] {
+			return "barcode"
+		}
+		if strings.ToLower(col) == "lane" {
+			return "lane"
+		}
+		return CanonicalColumn(col)
+	}) {
+		var lane = mgiLane(data["lane"])
+		for _, read := range []string{"1", "2"} {
+			var suffix = "_" + data["barcode"] + "_" + read + ".fq.gz"
+			var patterns = []string{"*_L0*" + suffix, "L0*/*_L0*" + suffix}
+			if lane != "" {
+				patterns = []string{"*_" + lane + suffix, lane + "/*_" + lane + suffix}
+			}
+			var fqs = globFastq(fqDir, patterns...)
+			if len(fqs) == 0 && read == "1" {
+				fqs = []string{patterns[0]}
+			}
+			data["路径-R"+read] = strings.Join(fqs, ",")
+		}
+		info = append(info, data)
+	}
+	return
+}
+
+// ParseTable parse non-xlsx input with header: sample table, Illumina SampleSheet or MGI barcode sheet
+func ParseTable(rows [][]string, format, fqDir, suffixCol string) (info []map[string]string, fqSet map[string][]*SeqInfo) {
+	fqSet = make(map[string][]*SeqInfo)
+	switch format {
+	case FormatIllumina:
+		info = ParseIlluminaSampleSheet(rows, fqDir)
+	case FormatMGI:
+		info = ParseMGISheet(rows, fqDir)
+	default:
+		info = tableRows(rows[0], rows[1:], 2, CanonicalColumn)
+	}
+	ParseSampleTable(info, fqDir, suffixCol, fqSet)
+	return
+}
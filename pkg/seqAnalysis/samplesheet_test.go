@@ -0,0 +1,87 @@
+package seqAnalysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	var dir = t.TempDir()
+	for _, name := range []string{"K30_S1_L001_R1_001.fastq.gz", "K30_S1_L002_R1_001.fastq.gz", "V300_L01_7_1.fq.gz", "V300_L01_7_2.fq.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		name, content, format, fq string
+	}{
+		{
+			"SampleSheet.csv",
+			"[Header]\nIEMFileVersion,4\n\n[Data]\nSample_ID,Sample_Name,index,Index Sequence,Sequence\nK30,K30,ACGTACGT,ACTAG,ATGAC\n",
+			FormatIllumina,
+			filepath.Join(dir, "K30_S1_L001_R1_001.fastq.gz") + "," + filepath.Join(dir, "K30_S1_L002_R1_001.fastq.gz") + ",",
+		},
+		{
+			"mgi.tsv",
+			"Sample\tBarcode\tLane\t靶标序列\t合成序列\nK30\t7\tL01\tACTAG\tATGAC\n",
+			FormatMGI,
+			filepath.Join(dir, "V300_L01_7_1.fq.gz") + "," + filepath.Join(dir, "V300_L01_7_2.fq.gz"),
+		},
+		{
+			"input.csv",
+			"Sample,Index,Sequence,R1\nK30,ACTAG,ATGAC,a.fq.gz\n",
+			FormatTable,
+			filepath.Join(dir, "a.fq.gz") + ",",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path = filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var rows, err = ReadTable(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := TableFormat(rows); got != tt.format {
+				t.Fatalf("TableFormat() = %s, want %s", got, tt.format)
+			}
+			info, _, err := ParseInput(path, dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(info) != 1 {
+				t.Fatalf("ParseInput() got %d samples, want 1", len(info))
+			}
+			var data = info[0]
+			if data["id"] != "K30" || data["index"] != "ACTAG" || data["seq"] != "ATGAC" {
+				t.Errorf("ParseInput() id/index/seq = %s/%s/%s", data["id"], data["index"], data["seq"])
+			}
+			if data["fq"] != tt.fq {
+				t.Errorf("ParseInput() fq = %s, want %s", data["fq"], tt.fq)
+			}
+		})
+	}
+}
+
+func TestParseInputError(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		bad = filepath.Join(dir, "bad.xlsx")
+	)
+	if err := os.WriteFile(bad, []byte("not xlsx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing.csv"), dir, bad} {
+		if _, _, err := ParseInput(path, dir, ""); err == nil {
+			t.Errorf("ParseInput(%s) should fail", path)
+		}
+	}
+	var problems, info, _ = ValidateInput(bad, dir, "")
+	if !HasInputError(problems) || info != nil {
+		t.Errorf("ValidateInput of bad xlsx = %v, %v", problems, info)
+	}
+}
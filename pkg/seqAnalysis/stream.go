@@ -0,0 +1,232 @@
+package seqAnalysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/xuri/excelize/v2"
+)
+
+// SheetRowLimit max rows of one sheet, Excel 上限 1048576
+var SheetRowLimit = excelize.TotalRows
+
+// SheetSplit max continuation sheets [sheet]_2... of one large sheet, rows beyond spill to [id].[sheet].txt
+var SheetSplit = 4
+
+// sheetStream write rows of one sheet of single sample xlsx by excelize.StreamWriter,
+// rows must be in ascending order, rows beyond SheetRowLimit continue in [sheet]_2... then spill to tsv.
+// buffered sheet keep rows in memory until Flush, for columns depend on totals
+type sheetStream struct {
+	xlsx   *excelize.File
+	name   string
+	prefix string
+	// 列宽
+	lastCol int
+	width   float64
+	// row 1, repeated in continuation sheets and tsv
+	header []any
+
+	buffered bool
+	pending  []sheetRow
+
+	sheets    []string
+	writer    *excelize.StreamWriter
+	rows      int // last row written to current sheet
+	spillPath string
+	spill     *os.File
+	spillRows int
+}
+
+// sheetRow one buffered row
+type sheetRow struct {
+	row    int
+	values []any
+}
+
+// newSheetStream stream of existing sheet, set width of columns A to lastCol
+func newSheetStream(xlsx *excelize.File, sheet, prefix string, lastCol int, width float64, buffered bool) *sheetStream {
+	var s = &sheetStream{
+		xlsx:     xlsx,
+		name:     sheet,
+		prefix:   prefix,
+		lastCol:  lastCol,
+		width:    width,
+		buffered: buffered,
+	}
+	simpleUtil.CheckErr(s.open(sheet))
+	return s
+}
+
+// open stream writer of sheet
+func (s *sheetStream) open(sheet string) (err error) {
+	s.writer, err = s.xlsx.NewStreamWriter(sheet)
+	if err != nil {
+		return
+	}
+	s.sheets = append(s.sheets, sheet)
+	s.rows = 0
+	if s.lastCol > 0 {
+		err = s.writer.SetColWidth(1, s.lastCol, s.width)
+	}
+	return
+}
+
+// SetRow set values from column A of row, buffered until Flush if buffered
+func (s *sheetStream) SetRow(row int, values []any) error {
+	if s.buffered {
+		if row == 1 {
+			s.header = values
+		} else {
+			s.pending = append(s.pending, sheetRow{row: row, values: values})
+		}
+		return nil
+	}
+	if row == 1 {
+		s.header = values
+	}
+	return s.write(row, values)
+}
+
+// Flush write buffered rows, update(row, values) return values to write, row 1 is updated even if not set
+func (s *sheetStream) Flush(update func(row int, values []any) []any) error {
+	s.buffered = false
+	s.header = update(1, s.header)
+	if s.header != nil {
+		if err := s.write(1, s.header); err != nil {
+			return err
+		}
+	}
+	for _, r := range s.pending {
+		if err := s.write(r.row, update(r.row, r.values)); err != nil {
+			return err
+		}
+	}
+	s.pending = nil
+	return nil
+}
+
+func (s *sheetStream) write(row int, values []any) error {
+	if s.spill != nil {
+		return s.writeSpill(values)
+	}
+	// 首个 sheet 保持原行号
+	if len(s.sheets) == 1 && row <= SheetRowLimit {
+		s.rows = row
+		return s.writer.SetRow(simpleUtil.HandleError(excelize.CoordinatesToCellName(1, row)), values)
+	}
+	if s.rows >= SheetRowLimit {
+		if err := s.next(); err != nil {
+			return err
+		}
+		if s.spill != nil {
+			return s.writeSpill(values)
+		}
+	}
+	s.rows++
+	return s.writer.SetRow(simpleUtil.HandleError(excelize.CoordinatesToCellName(1, s.rows)), values)
+}
+
+// next continue in next sheet, or spill to tsv after SheetSplit sheets
+func (s *sheetStream) next() (err error) {
+	if err = s.writer.Flush(); err != nil {
+		return
+	}
+	s.writer = nil
+	if len(s.sheets) <= SheetSplit {
+		var sheet = fmt.Sprintf("%s_%d", s.name, len(s.sheets)+1)
+		if _, err = s.xlsx.NewSheet(sheet); err != nil {
+			return
+		}
+		if err = s.open(sheet); err != nil {
+			return
+		}
+		if s.header != nil {
+			s.rows = 1
+			return s.writer.SetRow("A1", s.header)
+		}
+		return
+	}
+
+	s.spillPath = s.prefix + "." + s.name + ".txt"
+	s.spill, err = os.Create(s.spillPath)
+	if err != nil {
+		return
+	}
+	if s.header != nil {
+		err = s.writeSpill(s.header)
+		s.spillRows = 0
+	}
+	return
+}
+
+func (s *sheetStream) writeSpill(values []any) error {
+	var cells = make([]string, len(values))
+	for i, v := range values {
+		cells[i] = cellString(v)
+	}
+	s.spillRows++
+	_, err := fmt.Fprintln(s.spill, strings.Join(cells, "\t"))
+	return err
+}
+
+// cellString value of cell as text
+func cellString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Close flush stream, buffered rows written as is
+func (s *sheetStream) Close() error {
+	if s.buffered {
+		if err := s.Flush(func(_ int, values []any) []any { return values }); err != nil {
+			return err
+		}
+	}
+	if s.writer != nil {
+		if err := s.writer.Flush(); err != nil {
+			return err
+		}
+		s.writer = nil
+	}
+	if s.spill != nil {
+		return s.spill.Close()
+	}
+	return nil
+}
+
+// Note note of continuation sheets and spilled tsv, empty if not split
+func (s *sheetStream) Note() string {
+	var notes []string
+	if len(s.sheets) > 1 {
+		notes = append(notes, fmt.Sprintf(L("续表: %s"), strings.Join(s.sheets[1:], ", ")))
+	}
+	if s.spill != nil {
+		notes = append(notes, fmt.Sprintf(L("其余 %d 行见 %s"), s.spillRows, filepath.Base(s.spillPath)))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(L("%s 超出单表 %d 行，"), s.name, SheetRowLimit) + strings.Join(notes, L("；"))
+}
+
+// WriteNoteSheet add Note sheet of notes to xlsx
+func WriteNoteSheet(xlsx *excelize.File, notes []string) {
+	if len(notes) == 0 {
+		return
+	}
+	var sheet = "Note"
+	simpleUtil.HandleError(xlsx.NewSheet(sheet))
+	simpleUtil.CheckErr(xlsx.SetColWidth(sheet, "A", "A", 120))
+	for i, note := range notes {
+		SetCellStr(xlsx, sheet, 1, i+1, note)
+	}
+}
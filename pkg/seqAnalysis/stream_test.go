@@ -0,0 +1,79 @@
+package seqAnalysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestSheetStreamSplit(t *testing.T) {
+	var limit, split = SheetRowLimit, SheetSplit
+	defer func() { SheetRowLimit, SheetSplit = limit, split }()
+	SheetRowLimit, SheetSplit = 3, 1
+
+	var (
+		dir    = t.TempDir()
+		xlsx   = excelize.NewFile()
+		stream = newSheetStream(xlsx, "Sheet1", filepath.Join(dir, "K30"), 2, 25, false)
+	)
+	if err := stream.SetRow(1, []any{"#Seq", "Count"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= 10; i++ {
+		if err := stream.SetRow(i, []any{[]byte("ACGT"), i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sheet1: 表头 + 2 行，Sheet1_2: 表头 + 2 行，其余 5 行写入 K30.Sheet1.txt
+	for sheet, want := range map[string]int{"Sheet1": 3, "Sheet1_2": 3} {
+		rows, err := xlsx.GetRows(sheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != want || rows[0][0] != "#Seq" {
+			t.Errorf("%s rows = %v, want %d rows with header", sheet, rows, want)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "K30.Sheet1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 6 || lines[0] != "#Seq\tCount" || lines[5] != "ACGT\t10" {
+		t.Errorf("spilled tsv = %q", lines)
+	}
+	if note := stream.Note(); !strings.Contains(note, "Sheet1_2") || !strings.Contains(note, "K30.Sheet1.txt") {
+		t.Errorf("Note() = %s", note)
+	}
+}
+
+func TestSheetStreamFlush(t *testing.T) {
+	var stream = newSheetStream(excelize.NewFile(), "Sheet1", "", 0, 0, true)
+	stream.SetRow(2, []any{"A", "A", 3})
+	stream.SetRow(3, []any{"A", "", 1, "-"})
+	if err := stream.Flush(func(row int, values []any) []any {
+		if row == 1 {
+			return append(padRow(values, 4), "总数", 4)
+		}
+		return append(padRow(values, 4), float64(values[2].(int))/4)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var rows, err = stream.xlsx.GetRows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][4] != "总数" || rows[1][4] != "0.75" || rows[2][3] != "-" {
+		t.Errorf("rows = %q", rows)
+	}
+}
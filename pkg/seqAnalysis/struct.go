@@ -19,7 +19,6 @@ import (
 	math2 "github.com/liserjrqlxue/goUtil/math"
 	"github.com/liserjrqlxue/goUtil/osUtil"
 	"github.com/liserjrqlxue/goUtil/simpleUtil"
-	"github.com/liserjrqlxue/goUtil/stringsUtil"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -43,6 +42,10 @@ var (
 
 type ParallelTest struct {
 	ID string
+	// 样品名称，同 YieldCoefficient 顺序
+	Samples []string
+	// Grubbs 离群样品
+	Outliers []string
 
 	// 收率
 	YieldCoefficient     []float64
@@ -54,6 +57,16 @@ type ParallelTest struct {
 	AverageYieldAccuracy     []float64
 	AverageYieldAccuracyMean float64
 	AverageYieldAccuracySD   float64
+
+	// 各样品分析reads，加权统计的权重
+	Depth                            []int
+	YieldCoefficientWeightedMean     float64
+	YieldCoefficientWeightedSD       float64
+	AverageYieldAccuracyWeightedMean float64
+	AverageYieldAccuracyWeightedSD   float64
+
+	// 合并 reads 的虚拟样品，nil if not pooled
+	Pooled *SeqInfo
 }
 
 func (p *ParallelTest) Calculater() {
@@ -65,6 +78,8 @@ func (p *ParallelTest) Calculater() {
 	if len(p.AverageYieldAccuracy) == 1 {
 		p.AverageYieldAccuracySD = 0
 	}
+	p.YieldCoefficientWeightedMean, p.YieldCoefficientWeightedSD = WeightedMeanSD(p.YieldCoefficient, p.Depth)
+	p.AverageYieldAccuracyWeightedMean, p.AverageYieldAccuracyWeightedSD = WeightedMeanSD(p.AverageYieldAccuracy, p.Depth)
 }
 
 type SeqInfo struct {
@@ -76,9 +91,11 @@ type SeqInfo struct {
 	AssemblerMode        bool
 	Reverse              bool
 	NoTail               bool
+	Short                int
 
 	lineLimit int
 	xlsx      *excelize.File
+	streams   map[string]*sheetStream
 	Sheets    map[string]string
 	SheetList []string
 	Style     map[string]int
@@ -103,6 +120,15 @@ type SeqInfo struct {
 	Align       []byte
 	AlignInsert []byte
 	AlignMut    []byte
+	// class of last aligned sequence by Align1/Align2
+	class string
+
+	// pool of 平行 group, nil if not pooled
+	pool *Pool
+
+	// Export sequences.parquet of batch, nil for no export
+	Export       *SeqExport
+	exportBuffer []SeqRecord
 
 	IndexSeq  string
 	PostSeq   string
@@ -127,6 +153,19 @@ type SeqInfo struct {
 	DistributionNum  [4][]int
 	DistributionFreq [4][]float64
 
+	// QC by qc_rules.txt, nil if no rules
+	QC *QCResult
+	// CI confidence intervals, after WriteStatsSheet
+	CI *SampleCI
+	// 单步统计，同 [id].steps.txt
+	Steps []*StepStat
+	// 单步错误率，同 [id].one.step.error.rate.txt
+	ErrRates []*ErrRateRecord
+	// 截短及内部缺失产物，HitSeqCount 释放前统计
+	Truncation *TruncationProfile
+	// 批次污染矩阵，HitSeqCount 释放前记录
+	contamination *ContaminationMatrix
+
 	// fastq
 	// ReadsLength map[int]int
 	A   [300]int
@@ -154,7 +193,8 @@ type SeqInfo struct {
 	HighFreqCount int
 }
 
-func NewSeqInfo(data, Sheets map[string]string, sheetList []string, outputDir string, lineLimit int, long, rev, useRC, useKmer, lessMem bool) *SeqInfo {
+// NewSeqInfo create SeqInfo from one row of input, batch defaults are overridden by option columns of the row
+func NewSeqInfo(data, Sheets map[string]string, sheetList []string, outputDir string, lineLimit int, long, rev, useRC, useKmer, lessMem, noTail bool) *SeqInfo {
 	var seqInfo = new(SeqInfo)
 	seqInfo = &SeqInfo{
 		Name:           data["id"],
@@ -179,7 +219,10 @@ func NewSeqInfo(data, Sheets map[string]string, sheetList []string, outputDir st
 		UseReverseComplement: useRC,
 		UseKmer:              useKmer,
 		LessMem:              lessMem,
+		NoTail:               noTail,
+		Short:                Short,
 	}
+	seqInfo.ApplySampleOptions(data)
 
 	seqInfo.SeqChanWG.Add(len(seqInfo.Fastqs))
 	// close seqInfo.SeqChan after seqInfo.SeqChanWG
@@ -199,6 +242,27 @@ func NewSeqInfo(data, Sheets map[string]string, sheetList []string, outputDir st
 	return seqInfo
 }
 
+// ApplySampleOptions override options by option columns of one input row, empty cell keep batch default
+func (seqInfo *SeqInfo) ApplySampleOptions(data map[string]string) {
+	for _, opt := range []struct {
+		key string
+		v   *bool
+	}{
+		{"rc", &seqInfo.UseReverseComplement},
+		{"rev", &seqInfo.Reverse},
+		{"noTail", &seqInfo.NoTail},
+		{"long", &seqInfo.AssemblerMode},
+		{"kmer", &seqInfo.UseKmer},
+	} {
+		if v, ok, err := ParseOptionBool(data[opt.key]); err == nil && ok {
+			*opt.v = v
+		}
+	}
+	if v, ok, err := ParseOptionInt(data["short"]); err == nil && ok {
+		seqInfo.Short = v
+	}
+}
+
 func (seqInfo *SeqInfo) Init() {
 	seqInfo.Kmer = make(map[string]int)
 
@@ -251,22 +315,64 @@ func (seqInfo *SeqInfo) Init() {
 	//simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.Sheets[0], "A", "A", 20))
 	simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.Sheets["Stats"], "M", "R", 12))
 	simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.Sheets["Stats"], "S", "S", 14))
-	simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.Sheets["BarCode"], "A", "E", 50))
-	simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.Sheets["BarCode"], "B", "B", 50))
 
-	for i := 3; i < len(seqInfo.SheetList)-1; i++ {
-		SetRow(seqInfo.xlsx, seqInfo.SheetList[i], 1, 1, []interface{}{"#TargetSeq", "SubMatchSeq", "Count", "AlignResult"})
-		simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.SheetList[i], "A", "D", 25))
+	// 除 Stats 外各表行数随序列种类增长，使用 StreamWriter 逐行写出
+	var prefix = strings.TrimSuffix(seqInfo.Excel, ".xlsx")
+	seqInfo.streams = make(map[string]*sheetStream)
+	for name, sheet := range seqInfo.Sheets {
+		switch name {
+		case "Stats":
+		case "BarCode":
+			seqInfo.streams[name] = newSheetStream(seqInfo.xlsx, sheet, prefix, 5, 50, false)
+		case "Deletion":
+			seqInfo.streams[name] = newSheetStream(seqInfo.xlsx, sheet, prefix, 0, 0, true)
+		case "Other":
+			seqInfo.streams[name] = newSheetStream(seqInfo.xlsx, sheet, prefix, 6, 25, false)
+			seqInfo.SetSheetRow(name, 1, []interface{}{"#TargetSeq", "SubMatchSeq", "Count", "AlignDeletion", "AlignInsertion", "AlignMutation"})
+		default:
+			seqInfo.streams[name] = newSheetStream(seqInfo.xlsx, sheet, prefix, 4, 25, ratioSheets[name])
+			seqInfo.SetSheetRow(name, 1, []interface{}{"#TargetSeq", "SubMatchSeq", "Count", "AlignResult"})
+		}
+	}
+}
+
+// ratioSheets sheets with 比例 columns of 总数, buffered until totals known
+var ratioSheets = map[string]bool{
+	"Deletion":            true,
+	"DeletionSingle":      true,
+	"DeletionDiscrete2":   true,
+	"DeletionContinuous2": true,
+	"DeletionDiscrete3":   true,
+}
+
+// streamNames names of streamed sheets in order of SheetList
+func (seqInfo *SeqInfo) streamNames() (names []string) {
+	for _, sheet := range seqInfo.SheetList {
+		for name, s := range seqInfo.Sheets {
+			if s == sheet && seqInfo.streams[name] != nil {
+				names = append(names, name)
+			}
+		}
 	}
-	SetRow(seqInfo.xlsx, seqInfo.Sheets["Other"], 1, 1, []interface{}{"#TargetSeq", "SubMatchSeq", "Count", "AlignDeletion", "AlignInsertion", "AlignMutation"})
-	simpleUtil.CheckErr(seqInfo.xlsx.SetColWidth(seqInfo.Sheets["Other"], "A", "F", 25))
+	return
+}
+
+// SetSheetRow set values from column A of row of sheet name, rows of each sheet in ascending order
+func (seqInfo *SeqInfo) SetSheetRow(name string, row int, values []any) {
+	simpleUtil.CheckErr(seqInfo.streams[name].SetRow(row, values))
 }
 
-func (seqInfo *SeqInfo) SingleRun(resultDir string, TitleTar, TitleStats []string) {
+func (seqInfo *SeqInfo) SingleRun(resultDir string, TitleTar, TitleStats []string) error {
 	slog.Debug("SingleRun Init", slog.Group("seqInfo", "name", seqInfo.Name))
 	seqInfo.Init()
 	slog.Debug("SingleRun CountError", slog.Group("seqInfo", "name", seqInfo.Name))
 	seqInfo.CountError4(resultDir)
+	seqInfo.Truncation = NewTruncationProfile(string(seqInfo.Seq), seqInfo.HitSeqCount, seqInfo.Stats["AnalyzedReadsNum"], TruncationK)
+	seqInfo.contamination.Record(seqInfo)
+	if seqInfo.pool != nil {
+		slog.Debug("SingleRun Pool Merge", slog.Group("seqInfo", "name", seqInfo.Name))
+		seqInfo.pool.Merge(seqInfo)
+	}
 
 	slog.Debug("SingleRun WriteStatsSheet", slog.Group("seqInfo", "name", seqInfo.Name))
 	seqInfo.WriteStatsSheet(resultDir, TitleTar, TitleStats)
@@ -274,6 +380,10 @@ func (seqInfo *SeqInfo) SingleRun(resultDir string, TitleTar, TitleStats []strin
 	seqInfo.Save()
 	slog.Debug("SingleRun PrintStats", slog.Group("seqInfo", "name", seqInfo.Name))
 	seqInfo.PrintStats(resultDir)
+	slog.Debug("SingleRun WriteResultJSON", slog.Group("seqInfo", "name", seqInfo.Name))
+	if err := seqInfo.WriteResultJSON(resultDir); err != nil {
+		return fmt.Errorf("write result.json of %s: %w", seqInfo.Name, err)
+	}
 
 	slog.Debug("SingleRun PlotLineACGT", slog.Group("seqInfo", "name", seqInfo.Name))
 	prefix := filepath.Join(resultDir, seqInfo.Name)
@@ -282,9 +392,22 @@ func (seqInfo *SeqInfo) SingleRun(resultDir string, TitleTar, TitleStats []strin
 		slog.Info("SingleRun WriteKmer", slog.Group("seqInfo", "name", seqInfo.Name))
 		seqInfo.WriteKmer(prefix)
 	}
+	return nil
 }
 
 func (seqInfo *SeqInfo) Save() {
+	var notes []string
+	for _, name := range seqInfo.streamNames() {
+		var stream = seqInfo.streams[name]
+		simpleUtil.CheckErr(stream.Close())
+		if note := stream.Note(); note != "" {
+			slog.Warn(note, slog.Group("seqInfo", "name", seqInfo.Name))
+			notes = append(notes, note)
+		}
+	}
+	seqInfo.streams = nil
+	WriteNoteSheet(seqInfo.xlsx, notes)
+
 	slog.Info("save xlsx", slog.Group("seqInfo", "name", seqInfo.Name, "path", seqInfo.Excel))
 	simpleUtil.CheckErr(seqInfo.xlsx.SaveAs(seqInfo.Excel))
 	slog.Info("free xlsx", slog.Group("seqInfo", "name", seqInfo.Name))
@@ -335,6 +458,9 @@ func (seqInfo *SeqInfo) WriteSeqResult(path, outputDir string) {
 	if postSeq == "" && !seqInfo.NoTail {
 		postSeq = "AAAAAAAA"
 	}
+	// support IUPAC if IUPACIndex
+	indexSeq = indexRegexp(indexSeq)
+	postSeq = indexRegexp(postSeq)
 	var regPost = regexp.MustCompile(postSeq)
 
 	// seqInfo.RegPolyA = regexp.MustCompile(`^` + indexSeq + `(.*?)` + postSeq)
@@ -405,7 +531,7 @@ func (seqInfo *SeqInfo) Write1SeqResult(s string, reg *regexp.Regexp) {
 		// fmtUtil.Fprintln(seqInfo.SeqResultTxt, tSeq)
 
 		// 过滤 len(seq)<=Short
-		if Short > 0 && len(tSeq) <= Short {
+		if seqInfo.Short > 0 && len(tSeq) <= seqInfo.Short {
 			seqInfo.ExcludeReadsNum++
 			return
 		}
@@ -502,34 +628,39 @@ func (seqInfo *SeqInfo) WriteHitSeqLessMem() {
 			keep = false
 		}
 		if key == string(seqInfo.Seq) {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["Deletion"], 1, seqInfo.rowDeletion, []interface{}{seqInfo.Seq, key, seqInfo.HitSeqCount[key]})
+			seqInfo.SetSheetRow("Deletion", seqInfo.rowDeletion, []interface{}{seqInfo.Seq, key, seqInfo.HitSeqCount[key]})
 			seqInfo.rowDeletion++
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []interface{}{key, seqInfo.HitSeqCount[key]})
+			seqInfo.SetSheetRow("BarCode", i+1, []interface{}{key, seqInfo.HitSeqCount[key]})
+			seqInfo.exportSeq(key, ClassRight)
 			continue
 		}
 		if seqInfo.Align1(key, keep) {
+			seqInfo.exportSeq(key, seqInfo.class, seqInfo.Align)
 			if keep {
-				SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align})
+				seqInfo.SetSheetRow("BarCode", i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align})
 			}
 			continue
 		}
 
 		if seqInfo.Align2(key, keep) {
+			seqInfo.exportSeq(key, seqInfo.class, seqInfo.Align, seqInfo.AlignInsert)
 			if keep {
-				SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert})
+				seqInfo.SetSheetRow("BarCode", i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert})
 			}
 			continue
 		}
 
 		if seqInfo.Align3(key, keep) {
+			seqInfo.exportSeq(key, ClassMutation, seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut)
 			if keep {
-				SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+				seqInfo.SetSheetRow("BarCode", i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
 			}
 			continue
 		}
+		seqInfo.exportSeq(key, ClassOther, seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut)
 		if keep {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["Other"], 1, seqInfo.rowOther, []interface{}{seqInfo.Seq, key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+			seqInfo.SetSheetRow("BarCode", i+1, []interface{}{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+			seqInfo.SetSheetRow("Other", seqInfo.rowOther, []interface{}{seqInfo.Seq, key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
 			seqInfo.rowOther++
 		}
 		seqInfo.Stats["ErrorOtherReadsNum"] += seqInfo.HitSeqCount[key]
@@ -537,6 +668,7 @@ func (seqInfo *SeqInfo) WriteHitSeqLessMem() {
 	// seqInfo.HighFreqSeq = seqInfo.HitSeq[0]
 	// seqInfo.HighFreqCount = seqInfo.HitSeqCount[seqInfo.HighFreqSeq]
 	// slog.Info("高频序列", "Name", seqInfo.Name, "HighFreqSeq", seqInfo.HighFreqSeq, "HighFreqCount", seqInfo.HighFreqCount)
+	seqInfo.flushExport()
 	// free HitSeq
 	seqInfo.HitSeq = nil
 }
@@ -545,31 +677,37 @@ func (seqInfo *SeqInfo) WriteHitSeq() {
 	var keep = true
 	for i, key := range seqInfo.HitSeq {
 		if key == string(seqInfo.Seq) {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []any{key, seqInfo.HitSeqCount[key]})
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["Deletion"], 1, seqInfo.rowDeletion, []any{seqInfo.Seq, key, seqInfo.HitSeqCount[key]})
+			seqInfo.SetSheetRow("BarCode", i+1, []any{key, seqInfo.HitSeqCount[key]})
+			seqInfo.SetSheetRow("Deletion", seqInfo.rowDeletion, []any{seqInfo.Seq, key, seqInfo.HitSeqCount[key]})
 			seqInfo.rowDeletion++
+			seqInfo.exportSeq(key, ClassRight)
 			continue
 		}
 		if seqInfo.Align1(key, keep) {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align})
+			seqInfo.SetSheetRow("BarCode", i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align})
+			seqInfo.exportSeq(key, seqInfo.class, seqInfo.Align)
 			continue
 		}
 
 		if seqInfo.Align2(key, keep) {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert})
+			seqInfo.SetSheetRow("BarCode", i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert})
+			seqInfo.exportSeq(key, seqInfo.class, seqInfo.Align, seqInfo.AlignInsert)
 			continue
 		}
 
 		if seqInfo.Align3(key, keep) {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+			seqInfo.SetSheetRow("BarCode", i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+			seqInfo.exportSeq(key, ClassMutation, seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut)
 			continue
 		}
-		SetRow(seqInfo.xlsx, seqInfo.Sheets["BarCode"], 1, i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+		seqInfo.SetSheetRow("BarCode", i+1, []any{key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+		seqInfo.exportSeq(key, ClassOther, seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut)
 
-		SetRow(seqInfo.xlsx, seqInfo.Sheets["Other"], 1, seqInfo.rowOther, []any{seqInfo.Seq, key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
+		seqInfo.SetSheetRow("Other", seqInfo.rowOther, []any{seqInfo.Seq, key, seqInfo.HitSeqCount[key], seqInfo.Align, seqInfo.AlignInsert, seqInfo.AlignMut})
 		seqInfo.rowOther++
 		seqInfo.Stats["ErrorOtherReadsNum"] += seqInfo.HitSeqCount[key]
 	}
+	seqInfo.flushExport()
 	// free HitSeq
 	seqInfo.HitSeq = nil
 }
@@ -579,53 +717,37 @@ func (seqInfo *SeqInfo) WriteSeqResultNum() {
 	simpleUtil.CheckErr(seqInfo.del3.Close())
 	simpleUtil.CheckErr(seqInfo.del1.Close())
 
-	SetRow(seqInfo.xlsx, seqInfo.Sheets["Deletion"], 5, 1,
-		[]any{"总数", seqInfo.Stats["Deletion"] + seqInfo.RightReadsNum},
-	)
-	SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionSingle"], 5, 1,
-		[]any{"总数", seqInfo.Stats["DeletionSingle"]},
-	)
-	SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionDiscrete2"], 5, 1,
-		[]any{"总数", seqInfo.Stats["DeletionDiscrete2"]},
-	)
-	SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionContinuous2"], 5, 1,
-		[]any{"总数", seqInfo.Stats["DeletionContinuous2"]},
-	)
-	SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionDiscrete3"], 5, 1,
-		[]any{"总数", seqInfo.Stats["DeletionDiscrete3"]},
-	)
-
-	var sheet = seqInfo.Sheets["Deletion"]
-	for i := 3; i < seqInfo.rowDeletion; i++ {
-		var count = stringsUtil.Atoi(GetCellValue(seqInfo.xlsx, sheet, 3, i), "from Deletion: "+seqInfo.Name)
-		SetCellValue(seqInfo.xlsx, sheet, 5, i, math2.DivisionInt(count, seqInfo.Stats["Deletion"]))
-		SetCellValue(seqInfo.xlsx, sheet, 6, i, math2.DivisionInt(count, seqInfo.Stats["AnalyzedReadsNum"]))
-	}
-	sheet = seqInfo.Sheets["DeletionSingle"]
-	for i := 2; i < seqInfo.rowDeletionSingle; i++ {
-		var count = stringsUtil.Atoi(GetCellValue(seqInfo.xlsx, sheet, 3, i), "from DeletionSingle: "+seqInfo.Name)
-		SetCellValue(seqInfo.xlsx, sheet, 5, i, math2.DivisionInt(count, seqInfo.Stats["DeletionSingle"]))
-		SetCellValue(seqInfo.xlsx, sheet, 6, i, math2.DivisionInt(count, seqInfo.Stats["AnalyzedReadsNum"]))
-	}
-	sheet = seqInfo.Sheets["DeletionDiscrete2"]
-	for i := 2; i < seqInfo.rowDeletionDiscrete2; i++ {
-		var count = stringsUtil.Atoi(GetCellValue(seqInfo.xlsx, sheet, 3, i), "from DeletionDiscrete2: "+seqInfo.Name)
-		SetCellValue(seqInfo.xlsx, sheet, 5, i, math2.DivisionInt(count, seqInfo.Stats["DeletionDiscrete2"]))
-		SetCellValue(seqInfo.xlsx, sheet, 6, i, math2.DivisionInt(count, seqInfo.Stats["AnalyzedReadsNum"]))
-	}
-	sheet = seqInfo.Sheets["DeletionDiscrete3"]
-	for i := 2; i < seqInfo.rowDeletionDiscrete3; i++ {
-		var count = stringsUtil.Atoi(GetCellValue(seqInfo.xlsx, sheet, 3, i), "from DeletionDiscrete3: ", seqInfo.Name)
-		SetCellValue(seqInfo.xlsx, sheet, 5, i, math2.DivisionInt(count, seqInfo.Stats["DeletionDiscrete3"]))
-		SetCellValue(seqInfo.xlsx, sheet, 6, i, math2.DivisionInt(count, seqInfo.Stats["AnalyzedReadsNum"]))
-	}
-	sheet = seqInfo.Sheets["DeletionContinuous2"]
-	for i := 2; i < seqInfo.rowDeletionContinuous2; i++ {
-		var count = stringsUtil.Atoi(GetCellValue(seqInfo.xlsx, sheet, 3, i), "from DeletionContinuous2: ", seqInfo.Name)
-		SetCellValue(seqInfo.xlsx, sheet, 5, i, math2.DivisionInt(count, seqInfo.Stats["DeletionContinuous2"]))
-		SetCellValue(seqInfo.xlsx, sheet, 6, i, math2.DivisionInt(count, seqInfo.Stats["AnalyzedReadsNum"]))
+	var analyzed = seqInfo.Stats["AnalyzedReadsNum"]
+	for _, sheet := range []struct {
+		name         string
+		total, denom int
+		// 比例起始行，Deletion 第 2 行为正确序列
+		first int
+	}{
+		{"Deletion", seqInfo.Stats["Deletion"] + seqInfo.RightReadsNum, seqInfo.Stats["Deletion"], 3},
+		{"DeletionSingle", seqInfo.Stats["DeletionSingle"], seqInfo.Stats["DeletionSingle"], 2},
+		{"DeletionDiscrete2", seqInfo.Stats["DeletionDiscrete2"], seqInfo.Stats["DeletionDiscrete2"], 2},
+		{"DeletionContinuous2", seqInfo.Stats["DeletionContinuous2"], seqInfo.Stats["DeletionContinuous2"], 2},
+		{"DeletionDiscrete3", seqInfo.Stats["DeletionDiscrete3"], seqInfo.Stats["DeletionDiscrete3"], 2},
+	} {
+		simpleUtil.CheckErr(seqInfo.streams[sheet.name].Flush(func(row int, values []any) []any {
+			if row == 1 {
+				return append(padRow(values, 4), L("总数"), sheet.total)
+			}
+			if row < sheet.first {
+				return values
+			}
+			var count = values[2].(int)
+			return append(padRow(values, 4), math2.DivisionInt(count, sheet.denom), math2.DivisionInt(count, analyzed))
+		}))
 	}
+}
 
+// padRow copy of values with at least n columns
+func padRow(values []any, n int) []any {
+	var row = make([]any, max(n, len(values)))
+	copy(row, values)
+	return row
 }
 
 // var dash = regexp.MustCompile(`-+`)
@@ -647,6 +769,7 @@ func (seqInfo *SeqInfo) Align1(sequencingSeqStr string, keep bool) bool {
 		seqInfo.Align = sequencingAlignment
 		seqInfo.DistributionNum[0][0] += count
 		seqInfo.Stats["Deletion"] += count
+		seqInfo.class = ClassDeletion
 		return true
 	}
 
@@ -664,17 +787,19 @@ func (seqInfo *SeqInfo) Align1(sequencingSeqStr string, keep bool) bool {
 	//if k >= len(b) && !minus3.Match(c) { // all match
 	if k >= len(sequencingSeq) { // all match
 		seqInfo.Stats["Deletion"] += count
+		seqInfo.class = ClassDeletion
 
 		if keep {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["Deletion"], 1, seqInfo.rowDeletion, []any{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+			seqInfo.SetSheetRow("Deletion", seqInfo.rowDeletion, []any{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 			seqInfo.rowDeletion++
 		}
 
 		if delCount == 1 { // 单个缺失
 			seqInfo.Stats["DeletionSingle"] += count
+			seqInfo.class = ClassDeletionSingle
 
 			if keep {
-				SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionSingle"], 1, seqInfo.rowDeletionSingle, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+				seqInfo.SetSheetRow("DeletionSingle", seqInfo.rowDeletionSingle, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 				seqInfo.rowDeletionSingle++
 
 			}
@@ -695,16 +820,18 @@ func (seqInfo *SeqInfo) Align1(sequencingSeqStr string, keep bool) bool {
 
 			if minus2.Match(sequencingAlignment) { // 连续2缺失
 				seqInfo.Stats["DeletionContinuous2"] += count
+				seqInfo.class = ClassDeletionContinuous2
 
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionContinuous2"], 1, seqInfo.rowDeletionContinuous2, []any{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+					seqInfo.SetSheetRow("DeletionContinuous2", seqInfo.rowDeletionContinuous2, []any{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 					seqInfo.rowDeletionContinuous2++
 				}
 			} else { // 离散2缺失
 				seqInfo.Stats["DeletionDiscrete2"] += count
+				seqInfo.class = ClassDeletionDiscrete2
 
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionDiscrete2"], 1, seqInfo.rowDeletionDiscrete2, []any{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+					seqInfo.SetSheetRow("DeletionDiscrete2", seqInfo.rowDeletionDiscrete2, []any{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 					seqInfo.rowDeletionDiscrete2++
 				}
 			}
@@ -713,9 +840,10 @@ func (seqInfo *SeqInfo) Align1(sequencingSeqStr string, keep bool) bool {
 
 			if minus3.Match(sequencingAlignment) { // 连续3缺失
 				seqInfo.Stats["DeletionContinuous3"] += count
+				seqInfo.class = ClassDeletionContinuous3
 
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionContinuous3"], 1, seqInfo.rowDeletionContinuous3, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+					seqInfo.SetSheetRow("DeletionContinuous3", seqInfo.rowDeletionContinuous3, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 					seqInfo.rowDeletionContinuous3++
 				}
 
@@ -757,14 +885,16 @@ func (seqInfo *SeqInfo) Align1(sequencingSeqStr string, keep bool) bool {
 				// }
 			} else if minus2.Match(sequencingAlignment) { // 连续2缺失
 				seqInfo.Stats["DeletionContinuous2"] += count
+				seqInfo.class = ClassDeletionContinuous2
 
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionContinuous2"], 1, seqInfo.rowDeletionContinuous2, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+					seqInfo.SetSheetRow("DeletionContinuous2", seqInfo.rowDeletionContinuous2, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 					seqInfo.rowDeletionContinuous2++
 				}
 			} else { // 离散3缺失
+				seqInfo.class = ClassDeletionDiscrete3
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["DeletionDiscrete3"], 1, seqInfo.rowDeletionDiscrete3, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
+					seqInfo.SetSheetRow("DeletionDiscrete3", seqInfo.rowDeletionDiscrete3, []interface{}{seqInfo.Seq, sequencingSeqStr, count, sequencingAlignment})
 					seqInfo.rowDeletionDiscrete3++
 				}
 				seqInfo.Stats["DeletionDiscrete3"] += count
@@ -824,14 +954,16 @@ func (seqInfo *SeqInfo) Align2(key string, keep bool) bool {
 		//if !plus3.Match(c) && !minus3.Match(c) && !m2p2.Match(c) && minus1.Match(c) {
 		if !plus3.Match(c) {
 			if minus1.Match(c) {
+				seqInfo.class = ClassInsertionDeletion
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["InsertionDeletion"], 1, seqInfo.rowInsertionDeletion, []any{seqInfo.Seq, key, count, c})
+					seqInfo.SetSheetRow("InsertionDeletion", seqInfo.rowInsertionDeletion, []any{seqInfo.Seq, key, count, c})
 				}
 				seqInfo.rowInsertionDeletion++
 				seqInfo.Stats["ErrorInsDelReadsNum"] += count
 			} else {
+				seqInfo.class = ClassInsertion
 				if keep {
-					SetRow(seqInfo.xlsx, seqInfo.Sheets["Insertion"], 1, seqInfo.rowInsertion, []any{seqInfo.Seq, key, count, c})
+					seqInfo.SetSheetRow("Insertion", seqInfo.rowInsertion, []any{seqInfo.Seq, key, count, c})
 					seqInfo.rowInsertion++
 				}
 				seqInfo.Stats["ErrorInsReadsNum"] += count
@@ -873,7 +1005,7 @@ func (seqInfo *SeqInfo) Align3(key string, keep bool) bool {
 	seqInfo.AlignMut = c
 	if k < 2 && len(c) > 0 {
 		if keep {
-			SetRow(seqInfo.xlsx, seqInfo.Sheets["Mutation"], 1, seqInfo.rowMutation, []any{seqInfo.Seq, key, count, c})
+			seqInfo.SetSheetRow("Mutation", seqInfo.rowMutation, []any{seqInfo.Seq, key, count, c})
 			seqInfo.rowMutation++
 		}
 		seqInfo.Stats["ErrorMutReadsNum"] += count
@@ -1134,8 +1266,8 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 			statsMap[s] = c
 		}
 	}
-	statsMap["靶标"] = seqInfo.IndexSeq
-	statsMap["合成序列"] = string(seqInfo.Seq)
+	statsMap[L("靶标")] = seqInfo.IndexSeq
+	statsMap[L("合成序列")] = string(seqInfo.Seq)
 	statsMap["Accuracy"] = math2.DivisionInt(seqInfo.RightReadsNum, stats["AnalyzedReadsNum"])
 	statsMap["AverageBaseAccuracy"] = math2.DivisionInt(stats["AccuRightNum"], stats["AccuReadsNum"])
 	for _, s := range titleStats {
@@ -1152,6 +1284,7 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 
 	fmtUtil.FprintStringArray(out, titleTar, "\t")
 	SetRow(xlsx, sheet, 1, rIdx, title)
+	var titleRow = rIdx
 	rIdx++
 
 	var (
@@ -1159,6 +1292,7 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 		countDels = make(map[byte]int)
 		sequence  string
 		extLen    = min(4, len(seqInfo.IndexSeq))
+		nCol      = len(titleTar)
 	)
 	if seqInfo.Reverse {
 		sequence = "AAAA" + string(seqInfo.Seq)
@@ -1203,6 +1337,10 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 		seqInfo.OSAR = ratio[b]
 		var ratioDel = math2.DivisionInt(del1, readsCount)
 		var ratioSort = RankByteFloatMap(ratio)
+		var (
+			stepCI  = ProportionCI(counts[b], readsCount)
+			yieldCI = ProportionCI(counts[b], stats["AnalyzedReadsNum"])
+		)
 
 		seqInfo.AverageYieldAccuracy = math.Pow(seqInfo.YieldCoefficient, 1.0/float64(i+1))
 
@@ -1232,10 +1370,13 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 			ratioSort[1].Value,
 			del1,
 			ratioDel,
+			stepCI.Low,
+			stepCI.High,
+			yieldCI.Low,
+			yieldCI.High,
 		}
 
-		fmtUtil.Fprintf(
-			oser,
+		var errRateLine = fmt.Sprintf(
 			"%s\t%s\t%c\t%d\t%f\t%d\t%d\n",
 			seqInfo.Name,
 			sequence[i:i+extLen],
@@ -1244,12 +1385,46 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 			(1-ratio[b])*100,
 			readsCount, counts[b],
 		)
+		fmtUtil.Fprint(oser, errRateLine)
+		// 与文件内容一致，供 context.txt 复用，无需重读
+		seqInfo.ErrRates = append(seqInfo.ErrRates, simpleUtil.HandleError(ParseErrRateRecord(errRateLine)))
 
 		readsCount = counts[b]
 
 		SetRow(xlsx, sheet, 1, rIdx, rowValue)
+		nCol = max(nCol, len(rowValue))
 		rIdx++
 
+		seqInfo.Steps = append(seqInfo.Steps, &StepStat{
+			Position:             i + 1,
+			Base:                 string(b),
+			DelFreq:              distribution[0][i],
+			InsFreq:              distribution[1][i],
+			MutFreq:              distribution[2][i],
+			RightFreq:            distribution[3][i],
+			ReadsCount:           rowValue[6].(int),
+			A:                    counts['A'],
+			T:                    counts['T'],
+			C:                    counts['C'],
+			G:                    counts['G'],
+			Deletion:             del,
+			Yield:                seqInfo.YieldCoefficient,
+			RatioA:               ratio['A'],
+			RatioT:               ratio['T'],
+			RatioC:               ratio['C'],
+			RatioG:               ratio['G'],
+			StepAccuracy:         seqInfo.OSAR,
+			AverageYieldAccuracy: seqInfo.AverageYieldAccuracy,
+			Top1Base:             string(ratioSort[0].Key),
+			Top1Ratio:            ratioSort[0].Value,
+			Top2Base:             string(ratioSort[1].Key),
+			Top2Ratio:            ratioSort[1].Value,
+			Deletion1:            del1,
+			Deletion1Ratio:       ratioDel,
+			StepAccuracyCI:       stepCI,
+			YieldCI:              yieldCI,
+		})
+
 		fmtUtil.Fprintf(
 			out,
 			"%d\t%s\t%f\t%f\t%f\t%f\t%d\t%d\t%d\t%d\t%d\t%d\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%s\t%f\t%s\t%f\t%d\t%f\n",
@@ -1285,7 +1460,14 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 	seqInfo.HitSeqCount = make(map[string]int)
 	seqInfo.HitSeqCount = nil
 
+	if len(seqInfo.Seq) > 0 {
+		var ciTitle = stepCITitle()
+		SetRow(xlsx, sheet, nCol-len(ciTitle)+1, titleRow, ciTitle)
+	}
+	seqInfo.CI = seqInfo.ConfidenceIntervals()
+
 	simpleUtil.CheckErr(seqInfo.xlsx.SetRowStyle(sheet, 1, rIdx-1, seqInfo.Style["center"]))
+	seqInfo.AddStepChart(sheet, titleRow, nCol)
 }
 
 // WriteStatsTxt writes the statistics of SeqInfo to a text file.
@@ -1295,14 +1477,20 @@ func (seqInfo *SeqInfo) WriteStatsSheet(resultDir string, TitleTar, TitleStats [
 //
 // Return type: none.
 func (info *SeqInfo) WriteStatsTxt(file *os.File) {
+	// Write the statistics string to the file
+	fmtUtil.Fprintf(file, "%s\n", info.statsTxt())
+}
+
+// statsTxt one line of summary.txt without line break
+func (info *SeqInfo) statsTxt() string {
 	// Get the statistics from SeqInfo
 	stats := info.Stats
 
 	// Format the statistics into a string
-	statsString := fmt.Sprintf(
-		"%s\t%s\t%st\t%s\t%d\t%d\t%d\t%d\t%d\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\n",
-		info.Name, info.IndexSeq, info.Seq, info.PostSeq, len(info.Seq),
-		info.AllReadsNum, info.IndexReadsNum, stats["AnalyzedReadsNum"], info.RightReadsNum,
+	return fmt.Sprintf(
+		"%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f\t%f",
+		info.Name, info.IndexSeq, info.Seq, len(info.Seq),
+		stats["AllReadsNum"], stats["IndexReadsNum"], stats["AnalyzedReadsNum"], stats["RightReadsNum"],
 		info.YieldCoefficient, info.AverageYieldAccuracy,
 		math2.DivisionInt(stats["ErrorReadsNum"], stats["AnalyzedReadsNum"]),
 		math2.DivisionInt(stats["Deletion"], stats["AnalyzedReadsNum"]),
@@ -1316,9 +1504,6 @@ func (info *SeqInfo) WriteStatsTxt(file *os.File) {
 		math2.DivisionInt(stats["ErrorMutReadsNum"], stats["AnalyzedReadsNum"]),
 		math2.DivisionInt(stats["ErrorOtherReadsNum"], stats["AnalyzedReadsNum"]),
 	)
-
-	// Write the statistics string to the file
-	fmtUtil.Fprintf(file, statsString)
 }
 
 func (info *SeqInfo) SummaryRow() []any {
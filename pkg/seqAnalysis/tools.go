@@ -2,14 +2,19 @@ package seqAnalysis
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -147,16 +152,19 @@ func WriteUpperDownNIL(out *os.File, indexSeq, refSeq string, offset int) {
 
 var gz = regexp.MustCompile(`\.gz$`)
 
-func ReadFastq(fastq string, chanList []chan string) {
+// ReadFastq send seq line of fastq to each chan of chanList and unmatched, return checksum of fastq read through
+func ReadFastq(fastq string, chanList []chan string, unmatched *UnmatchedCollector) *FileChecksum {
 	var (
 		file    = osUtil.Open(fastq)
+		hash    = sha256.New()
+		tee     = io.TeeReader(file, hash)
 		scanner *bufio.Scanner
 		i       = -1
 	)
 	if gz.MatchString(fastq) {
-		scanner = bufio.NewScanner(simpleUtil.HandleError(gzip.NewReader(file)))
+		scanner = bufio.NewScanner(simpleUtil.HandleError(gzip.NewReader(tee)))
 	} else {
-		scanner = bufio.NewScanner(file)
+		scanner = bufio.NewScanner(tee)
 	}
 
 	for scanner.Scan() {
@@ -168,14 +176,24 @@ func ReadFastq(fastq string, chanList []chan string) {
 		for _, ch := range chanList {
 			ch <- s
 		}
+		unmatched.Add(s)
 	}
+	// hash the rest bytes
+	simpleUtil.HandleError(io.Copy(io.Discard, tee))
+	var info = simpleUtil.HandleError(file.Stat())
 
 	simpleUtil.CheckErr(file.Close())
 	slog.Info("ReadFastq Done", "fq", fastq)
+	return &FileChecksum{Path: fastq, Size: info.Size(), SHA256: hex.EncodeToString(hash.Sum(nil))}
 }
 
-func ReadAllFastq(fqSet map[string][]*SeqInfo) {
-	var wg sync.WaitGroup
+// ReadAllFastq read fastqs to SeqChan of SeqInfos and unmatched collector of each fastq, return checksums of fastqs
+func ReadAllFastq(fqSet map[string][]*SeqInfo, unmatched map[string]*UnmatchedCollector) map[string]*FileChecksum {
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		checksums = make(map[string]*FileChecksum)
+	)
 
 	// read fastqs 多对多 到各个 SeqChan
 	wg.Add(len(fqSet))
@@ -194,7 +212,10 @@ func ReadAllFastq(fqSet map[string][]*SeqInfo) {
 			for _, seqInfo := range seqInfos {
 				chanList = append(chanList, seqInfo.SeqChan)
 			}
-			ReadFastq(fastq, chanList)
+			var checksum = ReadFastq(fastq, chanList, unmatched[fastq])
+			mutex.Lock()
+			checksums[fastq] = checksum
+			mutex.Unlock()
 			for _, seqInfo := range seqInfos {
 				seqInfo.SeqChanWG.Done()
 			}
@@ -204,21 +225,51 @@ func ReadAllFastq(fqSet map[string][]*SeqInfo) {
 	// wait readDone
 	wg.Wait()
 	slog.Info("ReadAllFastq Done")
+	return checksums
 }
 
 func SummaryTxt(resultDir string, TitleSummary []string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo) {
 	var summary = osUtil.Create(filepath.Join(resultDir, "summary.txt"))
 
-	fmtUtil.FprintStringArray(summary, TitleSummary, "\t")
+	var withQC = hasQC(SeqInfoMap)
+	fmtUtil.FprintStringArray(summary, extraTitle(TitleSummary, withQC), "\t")
 
 	for i := range inputInfo {
-		SeqInfoMap[inputInfo[i]["id"]].WriteStatsTxt(summary)
+		var info = SeqInfoMap[inputInfo[i]["id"]]
+		// 区间、QC 列对齐表头之后
+		var row = strings.Split(info.statsTxt(), "\t")
+		for len(row) < len(TitleSummary) {
+			row = append(row, "")
+		}
+		for _, v := range info.CIRow() {
+			row = append(row, fmt.Sprintf("%f", v))
+		}
+		for _, v := range info.LengthRow() {
+			if f, ok := v.(float64); ok {
+				row = append(row, fmt.Sprintf("%f", f))
+			} else {
+				row = append(row, fmt.Sprint(v))
+			}
+		}
+		if withQC {
+			row = append(row, info.QC.Level, info.QC.Reason())
+		}
+		fmtUtil.FprintStringArray(summary, row, "\t")
 	}
 	// close file handle before Compress-Archive
 	simpleUtil.CheckErr(summary.Close())
 }
 
-func SummaryXlsx(resultDir, baseName string, TitleSummary []string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo) {
+// extraTitle TitleSummary with titles of CIRow, LengthRow and QC
+func extraTitle(TitleSummary []string, withQC bool) []string {
+	var title = append(append(slices.Clone(TitleSummary), ciTitle()...), lengthTitle()...)
+	if withQC {
+		title = append(title, qcTitle()...)
+	}
+	return title
+}
+
+func SummaryXlsx(resultDir, baseName string, TitleSummary []string, inputInfo []map[string]string, SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest, comparisons []*Comparison, coupling *CouplingModel, runInfo *RunInfo) {
 
 	// write summary.xlsx
 	var (
@@ -229,7 +280,14 @@ func SummaryXlsx(resultDir, baseName string, TitleSummary []string, inputInfo []
 	// Summary Sheet
 	simpleUtil.CheckErr(excel.SetSheetName("Sheet1", "Summary"))
 	// write Title
-	for i, s := range TitleSummary {
+	var (
+		withQC    = hasQC(SeqInfoMap)
+		title     = extraTitle(TitleSummary, withQC)
+		ciCol     = len(TitleSummary) + 1
+		lengthCol = ciCol + len(ciTitle())
+		qcCol     = lengthCol + len(lengthTitle())
+	)
+	for i, s := range title {
 		SetCellStr(excel, "Summary", 1+i, 1, s)
 	}
 
@@ -242,6 +300,14 @@ func SummaryXlsx(resultDir, baseName string, TitleSummary []string, inputInfo []
 		)
 		sampleList = append(sampleList, id)
 		SetRow(excel, "Summary", 1, 2+i, rows)
+		SetRow(excel, "Summary", ciCol, 2+i, info.CIRow())
+		SetRow(excel, "Summary", lengthCol, 2+i, info.LengthRow())
+		if withQC {
+			SetRow(excel, "Summary", qcCol, 2+i, []any{info.QC.Level, info.QC.Reason()})
+		}
+	}
+	if withQC {
+		AddQCFormat(excel, "Summary", qcCol, 2, len(inputInfo)+1)
 	}
 
 	// get cwd
@@ -250,7 +316,17 @@ func SummaryXlsx(resultDir, baseName string, TitleSummary []string, inputInfo []
 	// change to resultDir
 	simpleUtil.CheckErr(os.Chdir(resultDir))
 
+	// 收率、收率平均准确率 色阶，按表头查找列
+	for _, t := range []string{L("收率"), L("收率平均准确率")} {
+		AddColorScale(excel, "Summary", slices.Index(title, t)+1, 2, len(inputInfo)+1)
+	}
+
 	AddSteps2Sheet(excel, sampleList)
+	AddCharts2Sheet(excel, sampleList, SeqInfoMap)
+	AddCompare2Sheet(excel, comparisons)
+	AddPooled2Sheet(excel, ParallelStatsMap)
+	AddCoupling2Sheet(excel, coupling)
+	AddRunInfo2Sheet(excel, runInfo)
 
 	// save summary.xlsx
 	log.Println("SaveAs ", summaryPath)
@@ -259,7 +335,7 @@ func SummaryXlsx(resultDir, baseName string, TitleSummary []string, inputInfo []
 	simpleUtil.CheckErr(os.Chdir(cwd))
 }
 
-func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, StatisticalField []map[string]string, SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest) {
+func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, StatisticalField []map[string]string, SeqInfoMap map[string]*SeqInfo, ParallelStatsMap map[string]*ParallelTest, comparisons []*Comparison, coupling *CouplingModel, runInfo *RunInfo) {
 	var excel, err = excelize.OpenFile(input)
 	simpleUtil.CheckErr(err)
 	rows, err := excel.GetRows("Summary")
@@ -274,7 +350,7 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 	for i := range rows {
 		if i == 0 {
 			for j, v := range rows[i] {
-				titleIndex[v] = j + 1
+				titleIndex[CanonicalColumn(v)] = j + 1
 			}
 			for _, v := range StatisticalField {
 				var title = v["summary_title"]
@@ -283,7 +359,7 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 					var cellName = GetCellName(1, title, titleIndex)
 					excel.SetCellStr("Summary", cellName, title)
 				}
-				title += "/个数"
+				title += L("/个数")
 				_, ok = titleIndex[title]
 				if !ok {
 					var cellName = GetCellName(1, title, titleIndex)
@@ -299,7 +375,7 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 			id = rows[i][titleIndex["样品名称"]-1]
 		)
 		if suffixCol != "" {
-			id = id + "." + rows[i][titleIndex[suffixCol]-1]
+			id = id + "." + rows[i][titleIndex[CanonicalColumn(suffixCol)]-1]
 		}
 		var (
 			info         = SeqInfoMap[id]
@@ -313,24 +389,24 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 		cellName = GetCellName(nrow, "样品名称", titleIndex)
 		excel.SetCellHyperLink("Summary", cellName, id+".xlsx", "External")
 
-		cellName = GetCellName(nrow, "分析reads", titleIndex)
-		excel.SetCellInt("Summary", cellName, stats["AnalyzedReadsNum"])
+		cellName = GetCellName(nrow, L("分析reads"), titleIndex)
+		excel.SetCellInt("Summary", cellName, int64(stats["AnalyzedReadsNum"]))
 
-		cellName = GetCellName(nrow, "正确reads", titleIndex)
-		excel.SetCellInt("Summary", cellName, info.RightReadsNum)
+		cellName = GetCellName(nrow, L("正确reads"), titleIndex)
+		excel.SetCellInt("Summary", cellName, int64(info.RightReadsNum))
 
-		cellName = GetCellName(nrow, "收率", titleIndex)
+		cellName = GetCellName(nrow, L("收率"), titleIndex)
 		excel.SetCellFloat("Summary", cellName, info.YieldCoefficient, 4, 64)
-		cellName = GetCellName(nrow, "平均收率", titleIndex)
+		cellName = GetCellName(nrow, L("平均收率"), titleIndex)
 		excel.SetCellFloat("Summary", cellName, parallelTest.YieldCoefficientMean, 4, 64)
-		cellName = GetCellName(nrow, "收率误差", titleIndex)
+		cellName = GetCellName(nrow, L("收率误差"), titleIndex)
 		excel.SetCellFloat("Summary", cellName, parallelTest.YieldCoefficientSD, 4, 64)
 
-		cellName = GetCellName(nrow, "单步准确率", titleIndex)
+		cellName = GetCellName(nrow, L("单步准确率"), titleIndex)
 		excel.SetCellFloat("Summary", cellName, info.AverageYieldAccuracy, 4, 64)
-		cellName = GetCellName(nrow, "平均准确率", titleIndex)
+		cellName = GetCellName(nrow, L("平均准确率"), titleIndex)
 		excel.SetCellFloat("Summary", cellName, parallelTest.AverageYieldAccuracyMean, 4, 64)
-		cellName = GetCellName(nrow, "准确率误差", titleIndex)
+		cellName = GetCellName(nrow, L("准确率误差"), titleIndex)
 		excel.SetCellFloat("Summary", cellName, parallelTest.AverageYieldAccuracySD, 4, 64)
 
 		// 写入统计
@@ -345,8 +421,24 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 				math2.DivisionInt(stats[key], stats["AnalyzedReadsNum"]),
 				4, 64,
 			)
-			cellName = GetCellName(nrow, title+"/个数", titleIndex)
-			excel.SetCellInt("Summary", cellName, stats[key])
+			cellName = GetCellName(nrow, title+L("/个数"), titleIndex)
+			excel.SetCellInt("Summary", cellName, int64(stats[key]))
+		}
+		var ciRow = info.CIRow()
+		for j, title := range ciTitle() {
+			cellName = GetCellName(nrow, title, titleIndex)
+			excel.SetCellFloat("Summary", cellName, ciRow[j].(float64), 4, 64)
+		}
+		var lengthRow = info.LengthRow()
+		for j, title := range lengthTitle() {
+			cellName = GetCellName(nrow, title, titleIndex)
+			simpleUtil.CheckErr(excel.SetCellValue("Summary", cellName, lengthRow[j]))
+		}
+		if info.QC != nil {
+			for j, title := range qcTitle() {
+				cellName = GetCellName(nrow, title, titleIndex)
+				excel.SetCellStr("Summary", cellName, []string{info.QC.Level, info.QC.Reason()}[j])
+			}
 		}
 		// cellName = GetCellName(nrow, "高频序列", titleIndex)
 		// excel.SetCellStr("Summary", cellName, info.HighFreqSeq)
@@ -361,7 +453,25 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 	// change to resultDir
 	simpleUtil.CheckErr(os.Chdir(resultDir))
 
+	for _, title := range []string{L("收率"), L("平均收率"), L("单步准确率"), L("平均准确率")} {
+		AddColorScale(excel, "Summary", titleIndex[title], 2, len(rows))
+	}
+	for _, title := range append(ciTitle(), lengthTitle()...) {
+		SetCellStr(excel, "Summary", titleIndex[title], 1, title)
+	}
+	if hasQC(SeqInfoMap) {
+		for _, title := range qcTitle() {
+			SetCellStr(excel, "Summary", titleIndex[title], 1, title)
+		}
+		AddQCFormat(excel, "Summary", titleIndex["QC"], 2, len(rows))
+	}
+
 	AddSteps2Sheet(excel, sampleList)
+	AddCharts2Sheet(excel, sampleList, SeqInfoMap)
+	AddCompare2Sheet(excel, comparisons)
+	AddPooled2Sheet(excel, ParallelStatsMap)
+	AddCoupling2Sheet(excel, coupling)
+	AddRunInfo2Sheet(excel, runInfo)
 
 	var summaryPath = fmt.Sprintf("summary-%s-%s.xlsx", baseName, time.Now().Format("20060102"))
 	// save summary.xlsx
@@ -371,10 +481,11 @@ func Input2summaryXlsx(input, resultDir, baseName, suffixCol string, Statistical
 	simpleUtil.CheckErr(os.Chdir(cwd))
 }
 
-// Zip use powershell to run Compress-Archive -Path [basePrefix]/*.xlsx,[basePrefix]/*.pdf -DestinationPath [outputPrefix].result.zip -Force
+// Zip use powershell to run Compress-Archive -Path [basePrefix]/*.xlsx,[basePrefix]/*.pdf,[basePrefix]/run.json -DestinationPath [outputPrefix].result.zip -Force
 func Zip(basePrefix, outputPrefix string) {
 	compress.ZipDir(outputPrefix+".result.zip", basePrefix, func(s string) bool {
-		return strings.HasSuffix(s, ".xlsx") || strings.HasSuffix(s, ".pdf")
+		return strings.HasSuffix(s, ".xlsx") || strings.HasSuffix(s, ".pdf") || strings.HasSuffix(s, ".result.json") ||
+			filepath.Base(s) == "run.json" || filepath.Base(s) == "batch.json" || filepath.Base(s) == "report.html"
 	})
 	if runtime.GOOS == "windows" {
 		absDir, err := filepath.Abs(outputPrefix)
@@ -394,55 +505,144 @@ func Rows2Map(rows [][]string) (result []map[string]string) {
 		}
 		var data = make(map[string]string)
 		for i, v := range row {
-			data[title[i]] = v
+			if i < len(title) {
+				data[title[i]] = v
+			}
 		}
 		result = append(result, data)
 	}
 	return
 }
 
-func ParseInput(input, fqDir, suffixCol string) (info []map[string]string, fqSet map[string][]*SeqInfo) {
-	fqSet = make(map[string][]*SeqInfo)
-	if isXlsx.MatchString(input) {
-		xlsx, err := excelize.OpenFile(input)
-		simpleUtil.CheckErr(err)
-		rows, err := xlsx.GetRows("Summary")
-		if err != nil {
-			rows, err = xlsx.GetRows("Sheet1")
-		}
-		simpleUtil.CheckErr(err)
-		info = Rows2Map(rows)
+// SampleOptionColumns optional columns of input.xlsx to override batch options per sample
+var SampleOptionColumns = []string{"rc", "rev", "noTail", "long", "short", "kmer"}
+
+// ParseOptionBool parse bool cell of option column, ok is false for empty cell
+func ParseOptionBool(s string) (v, ok bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return false, false, nil
+	case "1", "t", "true", "y", "yes", "是":
+		return true, true, nil
+	case "0", "f", "false", "n", "no", "否":
+		return false, true, nil
+	}
+	return false, false, fmt.Errorf("invalid bool: %q", s)
+}
 
-		for _, data := range info {
-			data["id"] = data["样品名称"]
-			data["index"] = data["靶标序列"]
-			data["postBase"] = data["后靶标"]
-			data["seq"] = data["合成序列"]
-			if suffixCol != "" {
-				data["id"] = data["id"] + "." + data[suffixCol]
-			}
-			if fqDir != "" {
-				if data["路径-R1"] != "" {
-					data["路径-R1"] = filepath.Join(fqDir, data["路径-R1"])
+// ParseOptionInt parse int cell of option column, ok is false for empty cell
+func ParseOptionInt(s string) (v int, ok bool, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false, nil
+	}
+	v, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, false, err
+	}
+	return v, true, nil
+}
 
-					fqSet[data["路径-R1"]] = []*SeqInfo{}
+// ParseSampleTable fill id/index/postBase/seq/fq from columns of input.xlsx
+func ParseSampleTable(info []map[string]string, fqDir, suffixCol string, fqSet map[string][]*SeqInfo) {
+	LocateFastq(info, fqDir)
+	for _, data := range info {
+		data["id"] = data["样品名称"]
+		data["index"] = data["靶标序列"]
+		data["postBase"] = data["后靶标"]
+		data["seq"] = data["合成序列"]
+		if suffixCol != "" {
+			data["id"] = data["id"] + "." + data[CanonicalColumn(suffixCol)]
+		}
+		if fqDir != "" {
+			// 路径 可为逗号分隔的多个 fastq，如多 lane
+			for _, col := range []string{"路径-R1", "路径-R2"} {
+				if data[col] == "" {
+					continue
 				}
-				if data["路径-R2"] != "" {
-					data["路径-R2"] = filepath.Join(fqDir, data["路径-R2"])
+				var fqs = strings.Split(data[col], ",")
+				for i := range fqs {
+					if !filepath.IsAbs(fqs[i]) {
+						fqs[i] = filepath.Join(fqDir, fqs[i])
+					}
 
-					fqSet[data["路径-R2"]] = []*SeqInfo{}
+					fqSet[fqs[i]] = []*SeqInfo{}
 				}
+				data[col] = strings.Join(fqs, ",")
 			}
-			data["fq"] = data["路径-R1"] + "," + data["路径-R2"]
 		}
+		data["fq"] = data["路径-R1"] + "," + data["路径-R2"]
+	}
+}
+
+// ParseSamples parse inline sample table of run config, columns same as input.xlsx
+func ParseSamples(samples []map[string]string, fqDir, suffixCol string) (info []map[string]string, fqSet map[string][]*SeqInfo) {
+	fqSet = make(map[string][]*SeqInfo)
+	for i, sample := range samples {
+		var data = make(map[string]string)
+		for k, v := range sample {
+			data[CanonicalColumn(k)] = v
+		}
+		data["row"] = strconv.Itoa(i + 1)
+		info = append(info, data)
+	}
+	ParseSampleTable(info, fqDir, suffixCol, fqSet)
+	return
+}
+
+// ParseInput parse input.xlsx, CSV/TSV with header, Illumina SampleSheet, MGI barcode sheet
+// or headerless txt of id index seq [fq...]
+func ParseInput(input, fqDir, suffixCol string) (info []map[string]string, fqSet map[string][]*SeqInfo, err error) {
+	fqSet = make(map[string][]*SeqInfo)
+	if isXlsx.MatchString(input) {
+		var xlsx *excelize.File
+		xlsx, err = excelize.OpenFile(input)
+		if err != nil {
+			return
+		}
+		defer simpleUtil.DeferClose(xlsx)
+		var rows [][]string
+		rows, err = xlsx.GetRows("Summary")
+		if err != nil {
+			rows, err = xlsx.GetRows("Sheet1")
+		}
+		if err != nil {
+			return
+		}
+		if len(rows) == 0 {
+			return nil, fqSet, fmt.Errorf("empty input: %s", input)
+		}
+		rows[0] = CanonicalColumns(rows[0])
+		info = Rows2Map(rows)
+		for i, data := range info {
+			data["row"] = strconv.Itoa(i + 2)
+		}
+		ParseSampleTable(info, fqDir, suffixCol, fqSet)
+		return
+	}
+	var rows [][]string
+	rows, err = ReadTable(input)
+	if err != nil {
+		return nil, fqSet, fmt.Errorf("read %s: %w", input, err)
+	}
+	if TableFormat(rows) != FormatLegacy {
+		info, fqSet = ParseTable(rows, TableFormat(rows), fqDir, suffixCol)
 	} else {
-		var seqList = textUtil.File2Array(input)
-		for _, s := range seqList {
+		var (
+			seqList = textUtil.File2Array(input)
+			located []map[string]string
+		)
+		for i, s := range seqList {
 			var data = make(map[string]string)
 			var stra = strings.Split(strings.TrimSuffix(s, "\r"), "\t")
+			data["row"] = strconv.Itoa(i + 1)
 			data["id"] = stra[0]
-			data["index"] = stra[1]
-			data["seq"] = stra[2]
+			if len(stra) > 1 {
+				data["index"] = stra[1]
+			}
+			if len(stra) > 2 {
+				data["seq"] = stra[2]
+			}
 			if len(stra) > 3 {
 				var fqList = stra[3:]
 				if fqDir != "" {
@@ -455,9 +655,11 @@ func ParseInput(input, fqDir, suffixCol string) (info []map[string]string, fqSet
 				for _, v := range fqList {
 					fqSet[v] = []*SeqInfo{}
 				}
+			} else if FastqLocate != nil {
+				located = append(located, data)
 			} else {
-				fq1 := filepath.Join(fqDir, "00.CleanData", stra[0], stra[0]+"_1.clean.fq.gz")
-				fq2 := filepath.Join(fqDir, "00.CleanData", stra[0], stra[0]+"_2.clean.fq.gz")
+				fq1 := filepath.Join(fqDir, ExpandTemplate(DefaultFastqTemplate, map[string]string{"id": stra[0], "read": "1"}))
+				fq2 := filepath.Join(fqDir, ExpandTemplate(DefaultFastqTemplate, map[string]string{"id": stra[0], "read": "2"}))
 				data["fq"] = fq1 + "," + fq2
 
 				fqSet[fq1] = []*SeqInfo{}
@@ -465,6 +667,22 @@ func ParseInput(input, fqDir, suffixCol string) (info []map[string]string, fqSet
 			}
 			info = append(info, data)
 		}
+		// 无 fq 列时按模板或自动查找
+		LocateFastq(located, fqDir)
+		for _, data := range located {
+			var fqList []string
+			for _, fq := range strings.Split(data["路径-R1"]+","+data["路径-R2"], ",") {
+				if fq == "" {
+					continue
+				}
+				if !filepath.IsAbs(fq) {
+					fq = filepath.Join(fqDir, fq)
+				}
+				fqList = append(fqList, fq)
+				fqSet[fq] = []*SeqInfo{}
+			}
+			data["fq"] = strings.Join(fqList, ",")
+		}
 	}
 	return
 }
@@ -0,0 +1,182 @@
+package seqAnalysis
+
+import (
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strconv"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+// TruncationK max k of n-k products in truncation.txt
+var TruncationK = 10
+
+// TruncationProfile n-1 ... n-K products of one sample,
+// 3'-truncation is prefix of 合成序列 (synthesis stopped, capped),
+// internal deletion is other subsequence (failed step not capped, synthesis continued)
+type TruncationProfile struct {
+	K        int
+	Analyzed int
+	// [k-1] reads of n-k products
+	Truncated []int
+	Deleted   []int
+	Other     []int
+	// 全部短于合成序列的截短及内部缺失 reads，不限 K
+	TruncatedAll int
+	DeletedAll   int
+}
+
+// isPrefix s is prefix of design t, IUPAC codes of t allowed
+func isPrefix(s, t string) bool {
+	if len(s) > len(t) {
+		return false
+	}
+	for i := range len(s) {
+		if !iupacMatch(t[i], s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSubsequence s is subsequence of design t, IUPAC codes of t allowed
+func isSubsequence(s, t string) bool {
+	var i int
+	for j := 0; i < len(s) && j < len(t); j++ {
+		if iupacMatch(t[j], s[i]) {
+			i++
+		}
+	}
+	return i == len(s)
+}
+
+// NewTruncationProfile TruncationProfile of HitSeqCount, call before WriteStatsSheet frees HitSeqCount,
+// deletion of last bases is indistinguishable from truncation and counted as truncation
+func NewTruncationProfile(seq string, HitSeqCount map[string]int, analyzed, k int) *TruncationProfile {
+	var profile = &TruncationProfile{
+		K:         k,
+		Analyzed:  analyzed,
+		Truncated: make([]int, k),
+		Deleted:   make([]int, k),
+		Other:     make([]int, k),
+	}
+	for s, count := range HitSeqCount {
+		var d = len(seq) - len(s)
+		if d <= 0 || s == "X" {
+			continue
+		}
+		var class = &profile.Other
+		if isPrefix(s, seq) {
+			class = &profile.Truncated
+			profile.TruncatedAll += count
+		} else if isSubsequence(s, seq) {
+			class = &profile.Deleted
+			profile.DeletedAll += count
+		}
+		if d <= k {
+			(*class)[d-1] += count
+		}
+	}
+	return profile
+}
+
+// Add counts of other profile of same K
+func (profile *TruncationProfile) Add(other *TruncationProfile) {
+	profile.Analyzed += other.Analyzed
+	profile.TruncatedAll += other.TruncatedAll
+	profile.DeletedAll += other.DeletedAll
+	for i := range profile.K {
+		profile.Truncated[i] += other.Truncated[i]
+		profile.Deleted[i] += other.Deleted[i]
+		profile.Other[i] += other.Other[i]
+	}
+}
+
+// Capping efficiency of capping, truncated / (truncated + internal deleted) of all failure products, NaN if none
+func (profile *TruncationProfile) Capping() (efficiency float64, ci Interval) {
+	var n = profile.TruncatedAll + profile.DeletedAll
+	if n == 0 {
+		return math.NaN(), Interval{math.NaN(), math.NaN()}
+	}
+	return float64(profile.TruncatedAll) / float64(n), ProportionCI(profile.TruncatedAll, n)
+}
+
+// truncationTitle titles of truncation.txt
+func truncationTitle() []string {
+	return []string{
+		L("样品名称"), L("产物"), L("截短reads"), L("截短比例"), L("内部缺失reads"), L("内部缺失比例"), L("其他reads"), L("其他比例"),
+	}
+}
+
+// cappingTitle titles of capping.txt
+func cappingTitle() []string {
+	return []string{
+		L("样品名称"), L("分析reads"), L("截短reads"), L("内部缺失reads"), L("加帽效率"), L("CI下限"), L("CI上限"),
+	}
+}
+
+// Rows rows of truncation.txt of profile
+func (profile *TruncationProfile) Rows(name string) (rows [][]string) {
+	var ratio = func(x int) string {
+		return formatFloat(float64(x) / float64(profile.Analyzed))
+	}
+	for i := range profile.K {
+		rows = append(rows, []string{
+			name, "n-" + strconv.Itoa(i+1),
+			strconv.Itoa(profile.Truncated[i]), ratio(profile.Truncated[i]),
+			strconv.Itoa(profile.Deleted[i]), ratio(profile.Deleted[i]),
+			strconv.Itoa(profile.Other[i]), ratio(profile.Other[i]),
+		})
+	}
+	return
+}
+
+// CappingRow row of capping.txt of profile
+func (profile *TruncationProfile) CappingRow(name string) []string {
+	var efficiency, ci = profile.Capping()
+	return []string{
+		name, strconv.Itoa(profile.Analyzed), strconv.Itoa(profile.TruncatedAll), strconv.Itoa(profile.DeletedAll),
+		formatFloat(efficiency), formatFloat(ci.Low), formatFloat(ci.High),
+	}
+}
+
+// WriteTruncationTxt write truncation.txt and capping.txt of samples in ids, with batch total as last rows
+func WriteTruncationTxt(resultDir string, ids []string, SeqInfoMap map[string]*SeqInfo) {
+	var (
+		truncation = osUtil.Create(filepath.Join(resultDir, "truncation.txt"))
+		capping    = osUtil.Create(filepath.Join(resultDir, "capping.txt"))
+		total      *TruncationProfile
+	)
+	defer simpleUtil.DeferClose(truncation)
+	defer simpleUtil.DeferClose(capping)
+
+	fmtUtil.FprintStringArray(truncation, truncationTitle(), "\t")
+	fmtUtil.FprintStringArray(capping, cappingTitle(), "\t")
+	for _, id := range ids {
+		var info = SeqInfoMap[id]
+		if info == nil || info.Truncation == nil {
+			continue
+		}
+		for _, row := range info.Truncation.Rows(id) {
+			fmtUtil.FprintStringArray(truncation, row, "\t")
+		}
+		fmtUtil.FprintStringArray(capping, info.Truncation.CappingRow(id), "\t")
+		if total == nil {
+			total = NewTruncationProfile("", nil, 0, info.Truncation.K)
+		}
+		total.Add(info.Truncation)
+	}
+	if total == nil {
+		return
+	}
+	var name = L("批次")
+	for _, row := range total.Rows(name) {
+		fmtUtil.FprintStringArray(truncation, row, "\t")
+	}
+	fmtUtil.FprintStringArray(capping, total.CappingRow(name), "\t")
+	var efficiency, ci = total.Capping()
+	slog.Info("Capping", "efficiency", efficiency, "low", ci.Low, "high", ci.High)
+}
@@ -0,0 +1,56 @@
+package seqAnalysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTruncationProfile(t *testing.T) {
+	var HitSeqCount = map[string]int{
+		"ACGTAC": 100, // 正确
+		"ACGTA":  6,   // n-1 截短（末位缺失同截短）
+		"ACTAC":  10,  // n-1 内部缺失
+		"ACGT":   4,   // n-2 截短
+		"AGTC":   2,   // n-2 内部缺失
+		"ACGAAC": 3,   // 突变，长度相同
+		"TTTTT":  5,   // n-1 其他
+		"A":      1,   // n-5 截短，超出 K
+		"X":      9,
+	}
+	var profile = NewTruncationProfile("ACGTAC", HitSeqCount, 140, 3)
+	if profile.Truncated[0] != 6 || profile.Deleted[0] != 10 || profile.Other[0] != 5 ||
+		profile.Truncated[1] != 4 || profile.Deleted[1] != 2 || profile.TruncatedAll != 11 || profile.DeletedAll != 12 {
+		t.Errorf("NewTruncationProfile = %+v", profile)
+	}
+	var efficiency, ci = profile.Capping()
+	if math.Abs(efficiency-11.0/23) > 1e-12 || ci.Low >= efficiency || ci.High <= efficiency {
+		t.Errorf("Capping = %v %+v", efficiency, ci)
+	}
+
+	var total = NewTruncationProfile("", nil, 0, 3)
+	total.Add(profile)
+	total.Add(profile)
+	if total.Analyzed != 280 || total.Deleted[0] != 20 || total.TruncatedAll != 22 {
+		t.Errorf("Add = %+v", total)
+	}
+	if rows := total.Rows("s"); len(rows) != 3 || rows[0][1] != "n-1" || rows[0][3] != formatFloat(12.0/280) {
+		t.Errorf("Rows = %v", rows)
+	}
+	if efficiency, _ = NewTruncationProfile("ACG", nil, 0, 3).Capping(); !math.IsNaN(efficiency) {
+		t.Errorf("Capping without failure = %v", efficiency)
+	}
+}
+
+func TestTruncationProfileIUPAC(t *testing.T) {
+	var HitSeqCount = map[string]int{
+		"ACGTAC": 100, // 正确
+		"ATGTA":  6,   // n-1 截短，N 位置任意碱基
+		"AGTAC":  10,  // n-1 内部缺失
+		"ACGRA":  3,   // n-1 其他，R 不是碱基
+		"AGGT":   4,   // n-2 截短
+	}
+	var profile = NewTruncationProfile("ANGTAC", HitSeqCount, 123, 3)
+	if profile.Truncated[0] != 6 || profile.Deleted[0] != 10 || profile.Other[0] != 3 || profile.Truncated[1] != 4 {
+		t.Errorf("NewTruncationProfile with N = %+v", profile)
+	}
+}
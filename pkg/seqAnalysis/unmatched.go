@@ -0,0 +1,277 @@
+package seqAnalysis
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+)
+
+// UnmatchedTop top N unmatched read prefixes of each fastq in unmatched.txt, 0 for off
+var UnmatchedTop = 0
+
+// UnmatchedPrefix nt of read prefix counted
+var UnmatchedPrefix = 30
+
+// maxUnmatchedKeys 前缀种类上限，超出后新前缀只计入 Overflow
+const maxUnmatchedKeys = 1 << 20
+
+// AdapterSignature common adapter sequence
+type AdapterSignature struct {
+	Name string
+	Seq  string
+}
+
+// AdapterSignatures adapters annotated in unmatched.txt
+var AdapterSignatures = []AdapterSignature{
+	{"TruSeq-R1", "AGATCGGAAGAGCACACGTCTGAACTCCAGTCAC"},
+	{"TruSeq-R2", "AGATCGGAAGAGCGTCGTGTAGGGAAAGAGTGT"},
+	{"Nextera", "CTGTCTCTTATACACATCT"},
+	{"SmallRNA", "TGGAATTCTCGGGTGCCAAGG"},
+	{"MGI-R1", "AAGTCGGAGGCCAAGCGGTCTTAGGAAGACAA"},
+	{"MGI-R2", "AAGTCGGATCGTAGCCATGTCGTTCTGTGAGCCAAGGAGTTG"},
+}
+
+// adapterSeed nt of adapter start searched in prefix, or prefix start searched in adapter
+const adapterSeed = 12
+
+// UnmatchedCollector reads of one fastq not matching 靶标序列 of any sample reading it
+type UnmatchedCollector struct {
+	Fastq     string
+	Reads     int
+	Unmatched int
+	// 超出 maxUnmatchedKeys 未计入 Prefixes 的 reads
+	Overflow int
+	Prefixes map[string]int
+
+	reg   *regexp.Regexp
+	regRC *regexp.Regexp
+}
+
+// NewUnmatchedCollector collector of fastq read by seqInfos, nil if any of seqInfos has no 靶标序列
+func NewUnmatchedCollector(fastq string, seqInfos []*SeqInfo) *UnmatchedCollector {
+	var (
+		forward, rc []string
+		seen        = make(map[string]bool)
+	)
+	for _, info := range seqInfos {
+		if info.IndexSeq == "" {
+			return nil
+		}
+		var reg = indexRegexp(info.IndexSeq)
+		if seen[reg] {
+			continue
+		}
+		seen[reg] = true
+		forward = append(forward, reg)
+		if info.UseReverseComplement {
+			rc = append(rc, reg)
+		}
+	}
+	if len(forward) == 0 {
+		return nil
+	}
+	var c = &UnmatchedCollector{
+		Fastq:    fastq,
+		Prefixes: make(map[string]int),
+		reg:      regexp.MustCompile(strings.Join(forward, "|")),
+	}
+	if len(rc) > 0 {
+		c.regRC = regexp.MustCompile(strings.Join(rc, "|"))
+	}
+	return c
+}
+
+// Add count read if not matching any 靶标序列, nil collector ignored, called by the only reader of fastq
+func (c *UnmatchedCollector) Add(read string) {
+	if c == nil {
+		return
+	}
+	c.Reads++
+	if c.reg.MatchString(read) || (c.regRC != nil && c.regRC.MatchString(ReverseComplement(read))) {
+		return
+	}
+	c.Unmatched++
+	var prefix = read[:min(len(read), UnmatchedPrefix)]
+	if _, ok := c.Prefixes[prefix]; ok || len(c.Prefixes) < maxUnmatchedKeys {
+		c.Prefixes[prefix]++
+	} else {
+		c.Overflow++
+	}
+}
+
+// IndexRef 靶标序列 of samples of batch
+type IndexRef struct {
+	Seq     string
+	Samples []string
+}
+
+// BatchIndexes distinct 靶标序列 of samples in ids, in order of first appearance
+func BatchIndexes(ids []string, SeqInfoMap map[string]*SeqInfo) (indexes []*IndexRef) {
+	var byIndex = make(map[string]*IndexRef)
+	for _, id := range ids {
+		var info = SeqInfoMap[id]
+		if info == nil || info.IndexSeq == "" {
+			continue
+		}
+		var ref = byIndex[info.IndexSeq]
+		if ref == nil {
+			ref = &IndexRef{Seq: info.IndexSeq}
+			byIndex[info.IndexSeq] = ref
+			indexes = append(indexes, ref)
+		}
+		ref.Samples = append(ref.Samples, id)
+	}
+	return
+}
+
+// iupacMatch base b of read matches code of 靶标序列
+func iupacMatch(code, b byte) bool {
+	if code == b {
+		return true
+	}
+	var s, ok = iupac[code]
+	return ok && (s == "." || len(s) > 1 && strings.IndexByte(s, b) >= 0)
+}
+
+// FitDistance min edit distance of pattern to any substring of text, IUPAC codes of pattern allowed
+func FitDistance(pattern, text string) int {
+	var prev, cur = make([]int, len(text)+1), make([]int, len(text)+1)
+	for i := 1; i <= len(pattern); i++ {
+		cur[0] = i
+		for j := 1; j <= len(text); j++ {
+			var sub = prev[j-1]
+			if !iupacMatch(pattern[i-1], text[j-1]) {
+				sub++
+			}
+			cur[j] = min(sub, prev[j]+1, cur[j-1]+1)
+		}
+		prev, cur = cur, prev
+	}
+	var best = len(pattern)
+	for _, d := range prev {
+		best = min(best, d)
+	}
+	return best
+}
+
+// AdapterHits adapters of prefix: adapter start in prefix, or prefix start in adapter (adapter dimer),
+// and polyN if one base ≥ 80% of prefix
+func AdapterHits(prefix string) (hits []string) {
+	for _, adapter := range AdapterSignatures {
+		var seed = adapter.Seq[:min(adapterSeed, len(adapter.Seq))]
+		if strings.Contains(prefix, seed) ||
+			(len(prefix) >= adapterSeed && strings.Contains(adapter.Seq, prefix[:adapterSeed])) {
+			hits = append(hits, adapter.Name)
+		}
+	}
+	for _, b := range "ACGT" {
+		if len(prefix) > 0 && strings.Count(prefix, string(b))*5 >= len(prefix)*4 {
+			hits = append(hits, "poly"+string(b))
+		}
+	}
+	return
+}
+
+// UnmatchedPrefixReport annotated unmatched read prefix
+type UnmatchedPrefixReport struct {
+	Prefix string
+	Count  int
+	// 最近靶标，距离相同时全部列出
+	Nearest  []*IndexRef
+	Distance int
+	// 反向互补最近距离
+	RCDistance int
+	RC         bool
+	Adapters   []string
+}
+
+// Top n most frequent prefixes annotated by nearest 靶标序列 of indexes and adapters
+func (c *UnmatchedCollector) Top(n int, indexes []*IndexRef) (reports []*UnmatchedPrefixReport) {
+	var prefixes []string
+	for prefix := range c.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if c.Prefixes[prefixes[i]] != c.Prefixes[prefixes[j]] {
+			return c.Prefixes[prefixes[i]] > c.Prefixes[prefixes[j]]
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	for _, prefix := range prefixes[:min(n, len(prefixes))] {
+		var (
+			report = &UnmatchedPrefixReport{Prefix: prefix, Count: c.Prefixes[prefix], Distance: -1, RCDistance: -1}
+			rc     = ReverseComplement(prefix)
+		)
+		var rcLength int
+		for _, ref := range indexes {
+			var d = FitDistance(ref.Seq, prefix)
+			if report.Distance < 0 || d < report.Distance {
+				report.Distance = d
+				report.Nearest = []*IndexRef{ref}
+			} else if d == report.Distance {
+				report.Nearest = append(report.Nearest, ref)
+			}
+			if d = FitDistance(ref.Seq, rc); report.RCDistance < 0 || d < report.RCDistance {
+				report.RCDistance = d
+				rcLength = len(ref.Seq)
+			}
+		}
+		// 反向互补更近且与靶标差异不超过 1/4
+		report.RC = report.RCDistance >= 0 && report.RCDistance < report.Distance && report.RCDistance*4 <= rcLength
+		report.Adapters = AdapterHits(prefix)
+		reports = append(reports, report)
+	}
+	return
+}
+
+// unmatchedTitle titles of unmatched.txt
+func unmatchedTitle() []string {
+	return []string{
+		L("路径"), L("总reads"), L("未匹配reads"), L("排名"), L("前缀"), "reads", L("占未匹配比例"),
+		L("最近靶标"), L("靶标序列"), L("编辑距离"), L("反向互补距离"), L("反向互补"), L("接头"),
+	}
+}
+
+// Row values of report of collector with rank
+func (report *UnmatchedPrefixReport) Row(c *UnmatchedCollector, rank int) []string {
+	var samples, seqs []string
+	for _, ref := range report.Nearest {
+		samples = append(samples, strings.Join(ref.Samples, ","))
+		seqs = append(seqs, ref.Seq)
+	}
+	var rc = ""
+	if report.RC {
+		rc = "RC"
+	}
+	return []string{
+		c.Fastq, strconv.Itoa(c.Reads), strconv.Itoa(c.Unmatched), strconv.Itoa(rank), report.Prefix, strconv.Itoa(report.Count),
+		formatFloat(float64(report.Count) / float64(c.Unmatched)),
+		strings.Join(samples, ";"), strings.Join(seqs, ";"), strconv.Itoa(report.Distance), strconv.Itoa(report.RCDistance), rc,
+		strings.Join(report.Adapters, ","),
+	}
+}
+
+// WriteUnmatchedTxt write top UnmatchedTop prefixes of each collector to unmatched.txt, fastqs sorted
+func WriteUnmatchedTxt(resultDir string, collectors map[string]*UnmatchedCollector, indexes []*IndexRef) {
+	var out = osUtil.Create(filepath.Join(resultDir, "unmatched.txt"))
+	defer simpleUtil.DeferClose(out)
+
+	fmtUtil.FprintStringArray(out, unmatchedTitle(), "\t")
+	var fastqs []string
+	for fq := range collectors {
+		fastqs = append(fastqs, fq)
+	}
+	sort.Strings(fastqs)
+	for _, fq := range fastqs {
+		var c = collectors[fq]
+		for i, report := range c.Top(UnmatchedTop, indexes) {
+			fmtUtil.FprintStringArray(out, report.Row(c, i+1), "\t")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package seqAnalysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFitDistance(t *testing.T) {
+	for _, c := range []struct {
+		pattern, text string
+		want          int
+	}{
+		{"ACGT", "TTACGTTT", 0},
+		{"ACGT", "TTACTTT", 1},
+		{"ACNT", "GGACCTGG", 0},
+		{"ACGT", "", 4},
+		{"ACGTAC", "ACG", 3},
+	} {
+		if d := FitDistance(c.pattern, c.text); d != c.want {
+			t.Errorf("FitDistance(%s, %s) = %d, want %d", c.pattern, c.text, d, c.want)
+		}
+	}
+}
+
+func TestUnmatchedCollector(t *testing.T) {
+	var (
+		a = &SeqInfo{Name: "a", IndexSeq: "CTGACTAG"}
+		b = &SeqInfo{Name: "b", IndexSeq: "GGTTCCAA", UseReverseComplement: true}
+	)
+	if NewUnmatchedCollector("x.fq", []*SeqInfo{a, {Name: "c"}}) != nil {
+		t.Errorf("collector of sample without 靶标序列 should be nil")
+	}
+	var c = NewUnmatchedCollector("x.fq", []*SeqInfo{a, b})
+	for _, read := range []string{
+		"NNCTGACTAGACGT", // a
+		"TTGGAACCTT",     // b 反向互补
+		"CTAGTCAGAAAA",   // a 反向互补，a 不考虑 RC
+		"CTAGTCAGAAAA",
+		"AGATCGGAAGAGCACACGTCTGAACTCCAGTCACGGGGGGG", // 接头二聚体
+	} {
+		c.Add(read)
+	}
+	if c.Reads != 5 || c.Unmatched != 3 || len(c.Prefixes) != 2 {
+		t.Errorf("collector = %+v", c)
+	}
+
+	var indexes = BatchIndexes([]string{"a", "b", "d"}, map[string]*SeqInfo{"a": a, "b": b, "d": {Name: "d", IndexSeq: "CTGACTAG"}})
+	if len(indexes) != 2 || !slices.Equal(indexes[0].Samples, []string{"a", "d"}) {
+		t.Errorf("BatchIndexes = %+v", indexes)
+	}
+	var reports = c.Top(5, indexes)
+	if len(reports) != 2 || reports[0].Prefix != "CTAGTCAGAAAA" || reports[0].Count != 2 ||
+		!reports[0].RC || reports[0].RCDistance != 0 || reports[0].Nearest[0] != indexes[0] {
+		t.Errorf("Top[0] = %+v", reports[0])
+	}
+	if !slices.Equal(reports[1].Adapters, []string{"TruSeq-R1", "TruSeq-R2"}) || reports[1].RC {
+		t.Errorf("Top[1] = %+v", reports[1])
+	}
+	if !slices.Equal(AdapterHits("GGGGGGGGGGGGGGGGGGGGGGGGGGAGGG"), []string{"polyG"}) {
+		t.Errorf("AdapterHits polyG = %v", AdapterHits("GGGGGGGGGGGGGGGGGGGGGGGGGGAGGG"))
+	}
+}
@@ -0,0 +1,391 @@
+package seqAnalysis
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	gzip "github.com/klauspost/pgzip"
+
+	"github.com/liserjrqlxue/goUtil/fmtUtil"
+	"github.com/liserjrqlxue/goUtil/osUtil"
+	"github.com/liserjrqlxue/goUtil/simpleUtil"
+	"github.com/liserjrqlxue/goUtil/stringsUtil"
+	"github.com/xuri/excelize/v2"
+)
+
+// problem level
+const (
+	LevelError = "ERROR"
+	LevelWarn  = "WARN"
+)
+
+// InputRequiredColumns required columns of input.xlsx
+var InputRequiredColumns = []string{"样品名称", "靶标序列", "合成序列", "路径-R1"}
+
+// iupac IUPAC nucleotide code to regexp
+var iupac = map[byte]string{
+	'A': "A",
+	'C': "C",
+	'G': "G",
+	'T': "T",
+	'U': "T",
+	'R': "[AG]",
+	'Y': "[CT]",
+	'S': "[CG]",
+	'W': "[AT]",
+	'K': "[GT]",
+	'M': "[AC]",
+	'B': "[CGT]",
+	'D': "[AGT]",
+	'H': "[ACT]",
+	'V': "[ACG]",
+	'N': ".",
+	'.': ".",
+}
+
+// IUPACIndex match 靶标序列 and 后靶标 with IUPAC codes, N as any base, else as literal regexp of older versions
+var IUPACIndex = false
+
+// IUPAC2Regexp convert IUPAC sequence to regexp, N and . as any base, other chars quoted
+func IUPAC2Regexp(seq string) string {
+	var reg strings.Builder
+	for i := 0; i < len(seq); i++ {
+		var s, ok = iupac[seq[i]]
+		if !ok {
+			s = regexp.QuoteMeta(string(seq[i]))
+		}
+		reg.WriteString(s)
+	}
+	return reg.String()
+}
+
+// indexRegexp regexp of 靶标序列 or 后靶标, IUPAC2Regexp if IUPACIndex
+func indexRegexp(seq string) string {
+	if IUPACIndex {
+		return IUPAC2Regexp(seq)
+	}
+	return seq
+}
+
+// InvalidBases return invalid chars of seq, allow A/C/G/T and IUPAC code
+func InvalidBases(seq string) (invalid []string) {
+	var seen = make(map[byte]bool)
+	for i := 0; i < len(seq); i++ {
+		var c = seq[i]
+		if _, ok := iupac[c]; ok && c != '.' {
+			continue
+		}
+		if !seen[c] {
+			seen[c] = true
+			invalid = append(invalid, strconv.Quote(string(c)))
+		}
+	}
+	return
+}
+
+// InputProblem one problem of input
+type InputProblem struct {
+	Row    int // 行号, 0 表示整个文件
+	Column string
+	Level  string
+	Msg    string
+}
+
+func (p *InputProblem) String() string {
+	var row = "-"
+	if p.Row > 0 {
+		row = strconv.Itoa(p.Row)
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s", p.Level, row, p.Column, p.Msg)
+}
+
+// HasInputError return true if any problem is LevelError
+func HasInputError(problems []*InputProblem) bool {
+	for _, p := range problems {
+		if p.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteInputProblems write all problems to w, sort by row
+func WriteInputProblems(w io.Writer, problems []*InputProblem) {
+	if len(problems) == 0 {
+		fmtUtil.Fprintln(w, L("输入检查通过"))
+		return
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Row < problems[j].Row
+	})
+	fmtUtil.Fprintln(w, L("级别\t行号\t列\t问题"))
+	for _, p := range problems {
+		fmtUtil.Fprintln(w, p.String())
+	}
+}
+
+// readableFastq check fastq exists and readable, check gzip header for .gz
+func readableFastq(fastq string) error {
+	info, err := os.Stat(fastq)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("is a directory")
+	}
+	file, err := os.Open(fastq)
+	if err != nil {
+		return err
+	}
+	defer simpleUtil.DeferClose(file)
+	if gz.MatchString(fastq) {
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("bad gzip: %w", err)
+		}
+		simpleUtil.CheckErr(gr.Close())
+	}
+	return nil
+}
+
+// validateHeader check required columns of input.xlsx
+func validateHeader(input, suffixCol string) (problems []*InputProblem) {
+	xlsx, err := excelize.OpenFile(input)
+	if err != nil {
+		return append(problems, &InputProblem{Level: LevelError, Msg: L("无法打开: ") + err.Error()})
+	}
+	defer simpleUtil.DeferClose(xlsx)
+	rows, err := xlsx.GetRows("Summary")
+	if err != nil {
+		rows, err = xlsx.GetRows("Sheet1")
+	}
+	if err != nil {
+		return append(problems, &InputProblem{Level: LevelError, Msg: L("缺少 Summary 或 Sheet1 工作表")})
+	}
+	if len(rows) < 2 {
+		return append(problems, &InputProblem{Row: 1, Level: LevelError, Msg: L("没有样品行")})
+	}
+
+	return validateColumns(CanonicalColumns(rows[0]), suffixCol)
+}
+
+// validateColumns check required columns in title
+func validateColumns(title []string, suffixCol string) (problems []*InputProblem) {
+	var titleMap = make(map[string]bool)
+	for _, v := range title {
+		titleMap[CanonicalColumn(v)] = true
+	}
+	var required = InputRequiredColumns
+	if suffixCol != "" {
+		required = append(required[:len(required):len(required)], CanonicalColumn(suffixCol))
+	}
+	for _, col := range required {
+		if !titleMap[col] {
+			problems = append(problems, &InputProblem{Row: 1, Column: L(col), Level: LevelError, Msg: L("缺少必需列")})
+		}
+	}
+	return
+}
+
+// ValidateInput check input before analysis, return all problems with row number,
+// and parsed samples to reuse, nil if header invalid
+func ValidateInput(input, fqDir, suffixCol string) (problems []*InputProblem, info []map[string]string, fqSet map[string][]*SeqInfo) {
+	if !osUtil.FileExists(input) {
+		problems = append(problems, &InputProblem{Level: LevelError, Msg: L("输入文件不存在: ") + input})
+		return
+	}
+	var byName = true
+	if isXlsx.MatchString(input) {
+		problems = validateHeader(input, suffixCol)
+		if len(problems) > 0 {
+			return
+		}
+	} else {
+		rows, err := ReadTable(input)
+		if err != nil {
+			problems = append(problems, &InputProblem{Level: LevelError, Msg: L("无法打开: ") + err.Error()})
+			return
+		}
+		var format = TableFormat(rows)
+		switch format {
+		case FormatLegacy:
+			byName = false
+		case FormatTable:
+			problems = validateColumns(CanonicalColumns(rows[0]), suffixCol)
+			if len(problems) > 0 {
+				return
+			}
+		}
+		if format != FormatLegacy {
+			info, fqSet = ParseTable(rows, format, fqDir, suffixCol)
+			return validateInfo(info, byName), info, fqSet
+		}
+	}
+
+	var err error
+	info, fqSet, err = ParseInput(input, fqDir, suffixCol)
+	if err != nil {
+		problems = append(problems, &InputProblem{Level: LevelError, Msg: L("无法打开: ") + err.Error()})
+		return problems, nil, nil
+	}
+	return validateInfo(info, byName), info, fqSet
+}
+
+// ValidateSamples check inline sample table of run config, row is the sample order,
+// and parsed samples to reuse, nil if columns invalid
+func ValidateSamples(samples []map[string]string, fqDir, suffixCol string) (problems []*InputProblem, info []map[string]string, fqSet map[string][]*SeqInfo) {
+	var title []string
+	for _, sample := range samples {
+		for k := range sample {
+			title = append(title, k)
+		}
+	}
+	if len(samples) == 0 {
+		problems = []*InputProblem{{Level: LevelError, Msg: L("没有样品")}}
+		return
+	}
+	if problems = validateColumns(title, suffixCol); len(problems) > 0 {
+		for _, p := range problems {
+			p.Row = 0
+		}
+		return
+	}
+	info, fqSet = ParseSamples(samples, fqDir, suffixCol)
+	return validateInfo(info, true), info, fqSet
+}
+
+// validateInfo check parsed samples, byName for sample table with 样品名称 column
+func validateInfo(info []map[string]string, byName bool) (problems []*InputProblem) {
+	var (
+		idRow    = make(map[string]int)
+		fqRows   = make(map[string][]int)
+		fqIndex  = make(map[string]map[string]bool)
+		fqStatus = make(map[string]error)
+	)
+	for _, data := range info {
+		var (
+			row = stringsUtil.Atoi(data["row"])
+			add = func(col, level, format string, a ...any) {
+				problems = append(problems, &InputProblem{Row: row, Column: L(col), Level: level, Msg: fmt.Sprintf(L(format), a...)})
+			}
+		)
+
+		// sample id
+		var id, name = data["id"], data["id"]
+		if byName {
+			name = data["样品名称"]
+		}
+		if strings.TrimSpace(name) == "" {
+			add("样品名称", LevelError, "样品名称为空")
+		} else if r, ok := idRow[id]; ok {
+			add("样品名称", LevelError, "样品名称重复: %s (与第%d行)", id, r)
+		} else {
+			idRow[id] = row
+		}
+		if strings.ContainsAny(id, `/\:*?"<>|`) {
+			add("样品名称", LevelError, "样品名称含文件名非法字符: %s", id)
+		}
+
+		// sequences
+		for _, col := range []struct{ key, title string }{
+			{"index", "靶标序列"},
+			{"seq", "合成序列"},
+			{"postBase", "后靶标"},
+		} {
+			var seq = strings.ToUpper(data[col.key])
+			if seq == "" {
+				if col.key == "seq" {
+					add(col.title, LevelError, "序列为空")
+				}
+				continue
+			}
+			if invalid := InvalidBases(seq); len(invalid) > 0 {
+				add(col.title, LevelError, "含非法字符 %s, 只允许A/C/G/T及IUPAC简并碱基", strings.Join(invalid, ","))
+			} else if col.key != "seq" && !IUPACIndex && strings.Trim(seq, "ACGT") != "" {
+				add(col.title, LevelWarn, "含简并碱基, 未开启 -iupac 时按原样匹配")
+			}
+		}
+
+		// option columns
+		for _, col := range SampleOptionColumns {
+			var err error
+			if col == "short" {
+				_, _, err = ParseOptionInt(data[col])
+			} else {
+				_, _, err = ParseOptionBool(data[col])
+			}
+			if err != nil {
+				add(col, LevelError, "参数值非法: %v", err)
+			}
+		}
+
+		// fastq
+		var (
+			fqs  = 0
+			seen = make(map[string]bool)
+		)
+		for _, fq := range strings.Split(data["fq"], ",") {
+			if fq == "" {
+				continue
+			}
+			fqs++
+			if seen[fq] {
+				add("路径", LevelError, "同一样品重复使用fastq: %s", fq)
+				continue
+			}
+			seen[fq] = true
+			fqRows[fq] = append(fqRows[fq], row)
+			if fqIndex[fq] == nil {
+				fqIndex[fq] = make(map[string]bool)
+			}
+			if fqIndex[fq][data["index"]] {
+				add("路径", LevelWarn, "与其他样品共用fastq且靶标序列相同: %s", fq)
+			}
+			fqIndex[fq][data["index"]] = true
+
+			var err, ok = fqStatus[fq]
+			if !ok {
+				err = readableFastq(fq)
+				fqStatus[fq] = err
+			}
+			if err != nil {
+				add("路径", LevelError, "fastq不可读: %v", err)
+			}
+		}
+		// 按模板或自动查找的 fastq
+		for _, read := range []string{"R1", "R2"} {
+			if n, _ := strconv.Atoi(data["fqMatch-"+read]); n > 1 {
+				add("路径-"+read, LevelWarn, "匹配到%d个fastq, 全部使用: %s", n, data["路径-"+read])
+			}
+		}
+		if data["fqMatch-R1"] == "0" {
+			add("路径-R1", LevelError, "未找到匹配的fastq")
+		} else if fqs == 0 {
+			add("路径-R1", LevelError, "未提供fastq")
+		}
+	}
+
+	// fastq shared by samples, 按 fastq 排序保证输出稳定
+	for _, fq := range slices.Sorted(maps.Keys(fqRows)) {
+		if rows := fqRows[fq]; len(rows) > 1 {
+			var rowStr []string
+			for _, r := range rows {
+				rowStr = append(rowStr, strconv.Itoa(r))
+			}
+			problems = append(problems, &InputProblem{
+				Row:    rows[0],
+				Column: L("路径"),
+				Level:  LevelWarn,
+				Msg:    fmt.Sprintf(L("fastq被%d个样品共用(行 %s): %s"), len(rows), strings.Join(rowStr, ","), fq),
+			})
+		}
+	}
+	return
+}
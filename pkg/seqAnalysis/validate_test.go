@@ -0,0 +1,133 @@
+package seqAnalysis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIUPAC2Regexp(t *testing.T) {
+	var cases = map[string]string{
+		"ACGT": "ACGT",
+		"ANT":  "A.T",
+		"ARY":  "A[AG][CT]",
+		"A(T":  `A\(T`,
+		"":     "",
+	}
+	for seq, expected := range cases {
+		if got := IUPAC2Regexp(seq); got != expected {
+			t.Errorf("IUPAC2Regexp(%q) = %q; want %q", seq, got, expected)
+		}
+	}
+}
+
+func TestIndexRegexp(t *testing.T) {
+	defer func(v bool) { IUPACIndex = v }(IUPACIndex)
+	IUPACIndex = false
+	if got := indexRegexp("ANT"); got != "ANT" {
+		t.Errorf("indexRegexp without IUPACIndex = %q", got)
+	}
+	IUPACIndex = true
+	if got := indexRegexp("ANT"); got != "A.T" {
+		t.Errorf("indexRegexp with IUPACIndex = %q", got)
+	}
+}
+
+func TestInvalidBases(t *testing.T) {
+	if got := InvalidBases("ACGTNRYSWKMBDHV"); got != nil {
+		t.Errorf("Expected no invalid bases, but got %v", got)
+	}
+	var expected = []string{`"("`, `" "`}
+	if got := InvalidBases("AC(GT T("); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		fq    = filepath.Join(dir, "a.fq")
+		input = filepath.Join(dir, "input.txt")
+	)
+	if err := os.WriteFile(fq, []byte("@r\nACGT\n+\nIIII\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var content = "S1\tACGT\tACGT\t" + fq + "\n" +
+		"S1\tAC(T\tACGT\t" + fq + "\n" +
+		"S3\tACGT\n"
+	if err := os.WriteFile(input, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var problems, info, _ = ValidateInput(input, "", "")
+	if !HasInputError(problems) {
+		t.Fatalf("Expected errors, but got none")
+	}
+	if len(info) != 3 {
+		t.Errorf("Expected 3 parsed samples, but got %d", len(info))
+	}
+
+	var rows = make(map[int][]string)
+	for _, p := range problems {
+		if p.Level == LevelError {
+			rows[p.Row] = append(rows[p.Row], p.Column)
+		}
+	}
+	if len(rows[1]) != 0 {
+		t.Errorf("Expected row 1 ok, but got %v", rows[1])
+	}
+	if !reflect.DeepEqual(rows[2], []string{"样品名称", "靶标序列"}) {
+		t.Errorf("Unexpected problems of row 2: %v", rows[2])
+	}
+	if !reflect.DeepEqual(rows[3], []string{"合成序列", "路径", "路径"}) {
+		t.Errorf("Unexpected problems of row 3: %v", rows[3])
+	}
+}
+
+func TestValidateInputSharedFastq(t *testing.T) {
+	var dir = t.TempDir()
+	var fqs []string
+	for _, name := range []string{"d.fq", "c.fq", "b.fq", "a.fq"} {
+		var fq = filepath.Join(dir, name)
+		if err := os.WriteFile(fq, []byte("@r\nACGT\n+\nIIII\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fqs = append(fqs, fq)
+	}
+	var (
+		input   = filepath.Join(dir, "input.txt")
+		content = "S1\tACGT\tACGT\t" + strings.Join(fqs, ",") + "\n" +
+			"S2\tACGT\tACGT\t" + strings.Join(fqs, ",") + "\n"
+	)
+	if err := os.WriteFile(input, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 多次检查输出一致，且共用 fastq 按路径排序
+	var want string
+	for i := 0; i < 10; i++ {
+		var problems, _, _ = ValidateInput(input, "", "")
+		var buf bytes.Buffer
+		WriteInputProblems(&buf, problems)
+		if i == 0 {
+			want = buf.String()
+			var last = -1
+			for _, fq := range slices.Backward(fqs) {
+				var idx = strings.Index(want, fq+"\n")
+				if idx < last {
+					t.Errorf("shared fastq not sorted:\n%s", want)
+				}
+				last = idx
+			}
+			if strings.Count(want, "行 1,2") != 4 {
+				t.Errorf("expect 4 shared fastq warnings:\n%s", want)
+			}
+		} else if buf.String() != want {
+			t.Fatalf("WriteInputProblems not deterministic:\n%s\n%s", want, buf.String())
+		}
+	}
+}
@@ -76,7 +76,7 @@ func MergeCells(xlsx *excelize.File, sheet string, col1, row1, col2, row2 int) {
 
 // AddSteps2Sheet Add one.step.error.rate.txt to 单步错误率 sheet
 func AddSteps2Sheet(excel *excelize.File, list []string) {
-	var sheetName = "单步错误率-横排"
+	var sheetName = L("单步错误率-横排")
 	simpleUtil.HandleError(excel.NewSheet(sheetName))
 	for i := range list {
 		var rIdx = 1
@@ -84,7 +84,7 @@ func AddSteps2Sheet(excel *excelize.File, list []string) {
 		cellName, err := excelize.CoordinatesToCellName(1+i*5, rIdx)
 		simpleUtil.CheckErr(err)
 		// write title
-		excel.SetSheetRow(sheetName, cellName, &[]string{"名字", "合成前4nt-" + id, "合成碱基-" + id, "合成位置-" + id, "单步错误率-" + id})
+		excel.SetSheetRow(sheetName, cellName, &[]string{L("名字"), L("合成前4nt-") + id, L("合成碱基-") + id, L("合成位置-") + id, L("单步错误率-") + id})
 		rIdx++
 		for _, row := range textUtil.File2Slice(id+".one.step.error.rate.txt", "\t") {
 			row = row[:5]